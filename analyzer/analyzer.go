@@ -0,0 +1,287 @@
+// Package analyzer reports the interfaces with gointerfacegen source markers that are
+// out of date with the types they are generated from, as the sync subcommand's -check
+// does, with a suggested fix regenerating them. It runs under go vet
+//
+//	go vet -vettool=$(which gointerfacegen-vet) ./...
+//
+// and in any driver of golang.org/x/tools/go/analysis, such as gopls built with it.
+// Only the interfaces generated from a type of their own package are analyzed, the
+// others are left to gointerfacegen sync -check
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer reports the interfaces out of date with the types their source markers record
+var Analyzer = &analysis.Analyzer{
+	Name: "gointerfacegen",
+	Doc:  "report interfaces out of date with the types their gointerfacegen:source markers record",
+	URL:  "https://github.com/hankjacobs/gointerfacegen",
+	Run:  run,
+}
+
+// supported are the options of a source marker the analyzer regenerates an interface with.
+// The others, such as pkg and usedBy, need packages the analysis pass doesn't have
+var supported = map[string]bool{
+	"type": true, "file": true, "exported": true, "include": true, "exclude": true, "ignoreTag": true,
+	"skipDeprecated": true, "onlyReturning": true, "firstParam": true, "maxParams": true,
+	"doc": true, "sort": true, "prune": true, "types": true, "promoted": true, "common": true, "assert": true,
+	"declGroup": true, "keepResultNames": true, "paramNames": true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, file := range pass.Files {
+		filename := pass.Fset.Position(file.Package).Filename
+		for _, marker := range generator.InterfaceMarkers(file) {
+			decl, err := generator.FindInterface(file, marker.InterfaceName)
+			if err != nil {
+				continue
+			}
+
+			src, newSrc, err := regenerate(pass, filename, marker)
+			if err != nil {
+				pass.Reportf(decl.Pos(), "%s can't be regenerated: %v", marker.InterfaceName, err)
+				continue
+			}
+
+			if newSrc == nil || bytes.Equal(src, newSrc) {
+				continue
+			}
+
+			pass.Report(analysis.Diagnostic{
+				Pos:     decl.Pos(),
+				End:     decl.End(),
+				Message: fmt.Sprintf("%s is out of date with %s", marker.InterfaceName, marker.Options["type"]),
+				SuggestedFixes: []analysis.SuggestedFix{{
+					Message:   "Regenerate interface",
+					TextEdits: []analysis.TextEdit{edit(pass.Fset.File(file.Package), src, newSrc)},
+				}},
+			})
+		}
+	}
+
+	return nil, nil
+}
+
+// regenerate returns the source of the file and the file with the interface of the marker
+// regenerated, or a nil file when the analyzer doesn't regenerate the interface
+func regenerate(pass *analysis.Pass, filename string, marker generator.InterfaceMarker) ([]byte, []byte, error) {
+	options := marker.Options
+	for key := range options {
+		if !supported[key] {
+			return nil, nil, nil
+		}
+	}
+
+	if options["type"] == "" || options["file"] == "" {
+		return nil, nil, fmt.Errorf("the marker records no type and file")
+	}
+
+	// the type's file must be one of the package's
+	srcFilename := filepath.Join(filepath.Dir(filename), filepath.FromSlash(options["file"]))
+
+	// the files are parsed anew as the generator expects them
+	// formatted and changes those it merges into
+	fset := token.NewFileSet()
+	var file, srcFile *ast.File
+	var src []byte
+	files := []*ast.File{}
+	for _, f := range pass.Files {
+		name := pass.Fset.Position(f.Package).Filename
+		data, err := pass.ReadFile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		parsed, err := generator.ParseFile(fset, name, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, parsed)
+
+		if name == filename {
+			file, src = parsed, data
+		}
+		if name == srcFilename {
+			srcFile = parsed
+		}
+	}
+
+	if file == nil || srcFile == nil {
+		return nil, nil, nil
+	}
+
+	typeNames := strings.Split(options["type"], ",")
+	methods, err := typeMethods(fset, files, typeNames, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filterOpts, err := filterOptions(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	methods, err = generator.FilterMethods(methods, filterOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order := generator.OrderNone
+	if options["sort"] != "" {
+		if order, err = generator.ParseOrder(options["sort"]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	switch options["paramNames"] {
+	case "", "keep", "strip":
+	default:
+		return nil, nil, fmt.Errorf("invalid paramNames %q: must be keep or strip", options["paramNames"])
+	}
+
+	var sections map[string]*ast.CommentGroup
+	if order != generator.OrderAlpha {
+		sections = generator.Sections(fset, files, methods)
+	}
+
+	var typeParams *ast.FieldList
+	if typeSpec := generator.FindType(files, typeNames[0]); typeSpec != nil {
+		typeParams = typeSpec.TypeParams
+	}
+
+	iface, err := generator.BuildInterface(marker.InterfaceName, methods, typeParams, generator.Options{
+		Doc:                generator.InterfaceDoc(marker.InterfaceName, typeNames),
+		Docs:               options["doc"] != "false",
+		DeprecationNotices: true,
+		ResultNames:        options["keepResultNames"] == "true",
+		StripParamNames:    options["paramNames"] == "strip",
+		Sections:           sections,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, imp := range generator.MethodImports(fset, files, methods) {
+		file, err = generator.AddNamedImport(fset, file, imp.Name, imp.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	file, err = generator.MergeInto(fset, file, iface, typeNames[0], generator.MergeOptions{
+		Prune: options["prune"] == "true",
+		Order: order,
+		Group: options["declGroup"] == "true",
+		Docs:  options["doc"] != "false",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if options["assert"] == "true" {
+		for _, name := range typeNames {
+			file, err = generator.AddAssertion(fset, file, marker.InterfaceName, name)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, nil, err
+	}
+
+	newSrc, err := generator.PreserveFormatting(src, buf.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return src, newSrc, nil
+}
+
+// typeMethods returns the methods of the types, all of them or, with common, those they share
+func typeMethods(fset *token.FileSet, files []*ast.File, typeNames []string, options map[string]string) ([]*ast.FuncDecl, error) {
+	methodSets := [][]*ast.FuncDecl{}
+	for _, name := range typeNames {
+		var methods []*ast.FuncDecl
+		var err error
+		if options["types"] == "true" || options["promoted"] == "true" {
+			methods, err = generator.ResolveMethods(fset, files, name, generator.ResolveOptions{
+				Promoted: options["promoted"] == "true",
+			})
+		} else {
+			methods, err = generator.Dedupe(fset, generator.ExtractMethods(files, name))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		methodSets = append(methodSets, methods)
+	}
+
+	if options["common"] == "true" {
+		return generator.Intersect(methodSets...), nil
+	}
+
+	return generator.Union(methodSets...)
+}
+
+// filterOptions returns the options of the marker selecting the interface's methods
+func filterOptions(options map[string]string) (generator.FilterOptions, error) {
+	opts := generator.FilterOptions{
+		IgnoreTag:      generator.DefaultIgnoreTag,
+		SkipDeprecated: options["skipDeprecated"] == "true",
+		Exported:       options["exported"] == "true",
+		Include:        options["include"],
+		Exclude:        options["exclude"],
+		Returning:      options["onlyReturning"],
+		FirstParam:     options["firstParam"],
+	}
+
+	if ignoreTag, ok := options["ignoreTag"]; ok {
+		opts.IgnoreTag = ignoreTag
+	}
+
+	if options["maxParams"] != "" {
+		n, err := strconv.Atoi(options["maxParams"])
+		if err != nil {
+			return generator.FilterOptions{}, fmt.Errorf("invalid maxParams %q: %v", options["maxParams"], err)
+		}
+		opts.MaxParams = &n
+	}
+
+	return opts, nil
+}
+
+// edit returns the edit of the file turning src into newSrc, replacing only what differs
+func edit(tokFile *token.File, src, newSrc []byte) analysis.TextEdit {
+	start := 0
+	for start < len(src) && start < len(newSrc) && src[start] == newSrc[start] {
+		start++
+	}
+
+	end, newEnd := len(src), len(newSrc)
+	for end > start && newEnd > start && src[end-1] == newSrc[newEnd-1] {
+		end--
+		newEnd--
+	}
+
+	return analysis.TextEdit{
+		Pos:     tokFile.Pos(start),
+		End:     tokFile.Pos(end),
+		NewText: newSrc[start:newEnd],
+	}
+}
@@ -0,0 +1,167 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// runAnalyzer runs the analyzer over the files of a package, keyed by name, and returns what it reports
+func runAnalyzer(t *testing.T, sources map[string]string) (*token.FileSet, []analysis.Diagnostic) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	files := []*ast.File{}
+	for _, name := range sortedNames(sources) {
+		file, err := parser.ParseFile(fset, name, sources[name], parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	diagnostics := []analysis.Diagnostic{}
+	pass := &analysis.Pass{
+		Analyzer: Analyzer,
+		Fset:     fset,
+		Files:    files,
+		Report:   func(d analysis.Diagnostic) { diagnostics = append(diagnostics, d) },
+		ReadFile: func(filename string) ([]byte, error) {
+			if src, ok := sources[filename]; ok {
+				return []byte(src), nil
+			}
+			return nil, os.ErrNotExist
+		},
+	}
+
+	if _, err := Analyzer.Run(pass); err != nil {
+		t.Fatal(err)
+	}
+
+	return fset, diagnostics
+}
+
+// sortedNames returns the names of the files in order
+func sortedNames(sources map[string]string) []string {
+	names := []string{}
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestAnalyzer(t *testing.T) {
+	sources := map[string]string{
+		"store.go": `package store
+
+type Store struct{}
+
+func (s *Store) Get(id string) string { return id }
+
+func (s *Store) Put(id string) {}
+`,
+		"iface.go": `package store
+
+// Iface is the interface implemented by Store.
+//
+//gointerfacegen:source file=store.go type=Store
+type Iface interface {
+	Get(id string) string
+}
+
+// Elsewhere is the interface implemented by other.Store.
+//
+//gointerfacegen:source pkg=example.com/other type=Store
+type Elsewhere interface {
+	Get(id string) string
+}
+`,
+	}
+
+	fset, diagnostics := runAnalyzer(t, sources)
+
+	// the interface of another package's type is left to sync -check
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %+v", diagnostics)
+	}
+
+	d := diagnostics[0]
+	if pos := fset.Position(d.Pos); pos.Filename != "iface.go" || pos.Line != 6 || d.Message != "Iface is out of date with Store" {
+		t.Errorf("expected Iface reported out of date at iface.go:6, got %s at %s", d.Message, pos)
+	}
+
+	if len(d.SuggestedFixes) != 1 || len(d.SuggestedFixes[0].TextEdits) != 1 {
+		t.Fatalf("expected a single edit, got %+v", d.SuggestedFixes)
+	}
+
+	edit := d.SuggestedFixes[0].TextEdits[0]
+	src := sources["iface.go"]
+	fixed := src[:fset.Position(edit.Pos).Offset] + string(edit.NewText) + src[fset.Position(edit.End).Offset:]
+	if !strings.Contains(fixed, "\tGet(id string) string\n\tPut(id string)\n}\n\n// Elsewhere") {
+		t.Errorf("expected the fix to add Put to Iface only, got\n%s", fixed)
+	}
+}
+
+func TestAnalyzerFilterOptions(t *testing.T) {
+	sources := map[string]string{
+		"store.go": `package store
+
+import "context"
+
+type Store struct{}
+
+func (s *Store) Get(ctx context.Context, id string) (string, error) { return id, nil }
+
+// Deprecated: use Get.
+func (s *Store) Fetch(ctx context.Context, id string) (string, error) { return id, nil }
+
+//gointerfacegen:ignore
+func (s *Store) Put(ctx context.Context, id string) error { return nil }
+
+func (s *Store) Len() int { return 0 }
+
+func (s *Store) Close() error { return nil }
+`,
+		"iface.go": `package store
+
+// Iface is the interface implemented by Store.
+//
+//gointerfacegen:source file=store.go type=Store skipDeprecated=true firstParam=context.Context
+type Iface interface {
+}
+
+// Closer is the interface implemented by Store.
+//
+//gointerfacegen:source file=store.go type=Store ignoreTag= onlyReturning=error exclude=Get|Fetch maxParams=2
+type Closer interface {
+}
+`,
+	}
+
+	fset, diagnostics := runAnalyzer(t, sources)
+	want := map[string]string{
+		"Iface":  "type Iface interface {\n\tGet(ctx context.Context, id string) (string, error)\n}",
+		"Closer": "type Closer interface {\n\tPut(ctx context.Context, id string) error\n\tClose() error\n}",
+	}
+	if len(diagnostics) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %+v", len(want), diagnostics)
+	}
+
+	for _, d := range diagnostics {
+		name := strings.Fields(d.Message)[0]
+		edit := d.SuggestedFixes[0].TextEdits[0]
+		src := sources["iface.go"]
+		fixed := src[:fset.Position(edit.Pos).Offset] + string(edit.NewText) + src[fset.Position(edit.End).Offset:]
+		if !strings.Contains(fixed, want[name]) {
+			t.Errorf("expected the fix of %s to declare\n%s\ngot\n%s", name, want[name], fixed)
+		}
+	}
+}
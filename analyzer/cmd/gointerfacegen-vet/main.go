@@ -0,0 +1,13 @@
+// Command gointerfacegen-vet runs the gointerfacegen analyzer under go vet
+//
+//	go vet -vettool=$(which gointerfacegen-vet) ./...
+package main
+
+import (
+	"github.com/hankjacobs/gointerfacegen/analyzer"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(analyzer.Analyzer)
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// batchConfigName is the name of the file listing the interfaces to generate
+const batchConfigName = ".gointerfacegen.json"
+
+// batchConfig lists the interfaces generated by the generate subcommand.
+// Files are relative to the directory of the config
+//
+//	{
+//		"interfaces": [
+//			{"type": "UserStore", "interface": "Store", "file": "store/user.go", "output": "store/iface.go"},
+//			{"type": "Client", "interface": "API", "file": "client.go", "exported": true, "assert": true}
+//		]
+//	}
+type batchConfig struct {
+	Interfaces []batchEntry `json:"interfaces"`
+}
+
+// batchEntry describes one interface. Its options mirror the command's flags
+type batchEntry struct {
+	Type       string  `json:"type"` // comma separated for several types
+	Interface  string  `json:"interface"`
+	File       string  `json:"file"`
+	Output     string  `json:"output"`
+	Exported   bool    `json:"exported"`
+	Include    string  `json:"include"`
+	Exclude    string  `json:"exclude"`
+	Returning  string  `json:"onlyReturning"`
+	FirstParam string  `json:"firstParam"`
+	MaxParams  *int    `json:"maxParams"`
+	IgnoreTag  *string `json:"ignoreTag"` // defaults to gointerfacegen:ignore
+	Doc        *bool   `json:"doc"`       // defaults to true
+	Assert     bool    `json:"assert"`
+	Prune      bool    `json:"prune"`
+	Types      bool    `json:"types"`
+	Promoted   bool    `json:"promoted"`
+	Common     bool    `json:"common"`
+	Groups     bool    `json:"groups"`
+	Sort       string  `json:"sort"`
+	DeclGroup  bool    `json:"declGroup"`
+
+	MethodSet       string `json:"methodset"`  // pointer or value, defaults to pointer
+	ParamNames      string `json:"paramNames"` // keep or strip, defaults to keep
+	KeepResultNames bool   `json:"keepResultNames"`
+	SkipDeprecated  bool   `json:"skipDeprecated"`
+	EmbedStd        bool   `json:"embedStd"`
+	SkipUnexported  bool   `json:"skipUnexported"`
+	ImportAlias     string `json:"importAlias"`
+	UsedBy          string `json:"usedBy"` // directory of the consuming package, relative to the config
+	UsedIn          string `json:"usedIn"`
+
+	Postprocess string `json:"postprocess"`
+	Header      string `json:"header"` // file of the -header template, relative to the config
+}
+
+// runGenerate runs the generate subcommand, generating every interface listed in the config
+func runGenerate(args []string) error {
+	flags := newFlagSet("generate")
+	configFlag := flags.String("config", "", "Path of the config. Defaults to the nearest "+batchConfigName+" in the current directory or its parents")
+	checkFlag := flags.Bool("check", false, "Check that the interfaces on disk are up to date and exit non-zero if any is not. Nothing is written")
+	dryRunFlag := flags.Bool("dry-run", false, "Print whether each interface would be created, updated, with the methods added, changed and removed, or left unchanged. Nothing is written")
+	allFlag := flags.Bool("all", false, "Instead of reading the config, generate an interface for every exported type with exported methods in the package in the directory given as argument")
+	suffixFlag := flags.String("suffix", "Iface", "With -all, the suffix appended to a type's name to name its interface")
+	outputFlag := flags.String("o", "", "With -all, write the interfaces to this file, relative to the package, instead of alongside their types")
+	jobsFlag := flags.Int("j", runtime.NumCPU(), "Number of interfaces to generate at once. Interfaces written to the same file are generated one at a time")
+	reportFlag := flags.String("report", "text", "With -check, how to report the interfaces out of date: text|json|sarif. json and sarif are printed to standard out for CI systems and code review bots")
+	colorFlag := flags.String("color", "auto", "When to color -check output: auto|always|never. auto colors a terminal unless NO_COLOR is set")
+	verboseFlag := flags.Bool("v", false, "Log what's decided for each interface to standard error")
+	veryVerboseFlag := flags.Bool("vv", false, "Log how it's decided too")
+	parseFlags(flags, args)
+	setVerbosity(*verboseFlag, *veryVerboseFlag)
+
+	// the packages of several interfaces are parsed and type checked once
+	cached = newFileCache(true)
+
+	if err := validReport(*reportFlag); err != nil {
+		return err
+	}
+
+	if err := validColor(*colorFlag); err != nil {
+		return err
+	}
+	colorMode = *colorFlag
+
+	if *allFlag {
+		if flags.NArg() == 0 {
+			return fmt.Errorf("usage: gointerfacegen generate -all [-suffix suffix] [-o file] [-check] [-dry-run] [-j n] <packages>")
+		}
+
+		dirs, err := listPackages(flags.Args())
+		if err != nil {
+			return err
+		}
+
+		configs := []config{}
+		for _, path := range sortedKeys(dirs) {
+			dirConfigs, err := allConfigs(dirs[path], *suffixFlag, *outputFlag)
+			if err != nil {
+				return err
+			}
+
+			configs = append(configs, dirConfigs...)
+		}
+
+		return runConfigs(strings.Join(flags.Args(), " "), configs, false, *checkFlag, *dryRunFlag, *jobsFlag, *reportFlag)
+	}
+
+	path := *configFlag
+	if path == "" {
+		var err error
+		path, err = findBatchConfig()
+		if err != nil {
+			return err
+		}
+	}
+
+	batch, err := loadBatchConfig(path)
+	if err != nil {
+		return err
+	}
+
+	// Given packages, only the interfaces of the types in them are generated
+	var inPackages map[string]bool
+	if flags.NArg() > 0 {
+		dirs, err := listPackages(flags.Args())
+		if err != nil {
+			return err
+		}
+
+		inPackages = make(map[string]bool)
+		for _, dir := range dirs {
+			inPackages[dir] = true
+		}
+	}
+
+	// keep going so every invalid entry is reported
+	failed := false
+	configs := []config{}
+	dir := filepath.Dir(path)
+	for _, entry := range batch.Interfaces {
+		c, err := entry.config(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			failed = true
+			continue
+		}
+
+		if inPackages != nil {
+			typeDir, err := filepath.Abs(filepath.Dir(c.filename))
+			if err != nil {
+				return err
+			}
+
+			if !inPackages[typeDir] {
+				continue
+			}
+		}
+
+		configs = append(configs, c)
+	}
+
+	return runConfigs(path, configs, failed, *checkFlag, *dryRunFlag, *jobsFlag, *reportFlag)
+}
+
+// runConfigs generates or, when checking, checks every configured interface, up to jobs
+// at a time. Failures, including earlier ones, are reported against source, the config
+// or packages they came from. The failed checks are printed as a report in the format,
+// json or sarif, unless it is text. A dry run prints what generating would change instead
+func runConfigs(source string, configs []config, failed, check, dryRun bool, jobs int, report string) error {
+	if check && dryRun {
+		return fmt.Errorf("-dry-run cannot be used with -check")
+	}
+
+	var changes *dryRunReport
+	if dryRun {
+		changes = &dryRunReport{}
+	}
+
+	// interfaces generated into the same file, the file of the package already
+	// declaring them included, are generated one after another
+	byTarget := make(map[string][]config)
+	targets := []string{}
+	for _, c := range configs {
+		target := targetFilename(c)
+		if abs, err := filepath.Abs(target); err == nil {
+			target = abs
+		}
+
+		if _, ok := byTarget[target]; !ok {
+			targets = append(targets, target)
+		}
+		byTarget[target] = append(byTarget[target], c)
+	}
+
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// keep going so every stale interface is reported
+	var checkErrs []error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, jobs)
+	for _, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(configs []config) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			for _, c := range configs {
+				c.check = check
+				c.dryRun = changes
+				if _, err := run(c); err != nil {
+					mu.Lock()
+					if check && report != "text" {
+						checkErrs = append(checkErrs, err)
+					} else {
+						fmt.Fprintln(os.Stderr, errorText(err))
+					}
+					failed = true
+					mu.Unlock()
+				}
+			}
+		}(byTarget[target])
+	}
+	wg.Wait()
+
+	if changes != nil {
+		changes.print(os.Stdout)
+	}
+
+	if check && report != "text" {
+		if err := printReport(report, checkErrs); err != nil {
+			return err
+		}
+	}
+
+	if failed && check {
+		return fmt.Errorf("%s: not all interfaces are up to date", source)
+	} else if failed {
+		return fmt.Errorf("%s: not all interfaces were generated", source)
+	}
+
+	return nil
+}
+
+// allConfigs returns the configurations generating an interface named after the type
+// with the suffix for every exported type with exported methods of the package in dir
+func allConfigs(dir, suffix, output string) ([]config, error) {
+	fset := cached.fileSet()
+	files, err := parseDir(fset, dir, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	configs := []config{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				name := typeSpec.Name.Name
+				if !typeSpec.Name.IsExported() || typeSpec.Assign.IsValid() {
+					continue
+				}
+
+				if len(generator.Filter(generator.ExtractMethods(files, name), generator.Exported)) == 0 {
+					continue
+				}
+
+				c := config{
+					typeNames:     []string{name},
+					interfaceName: name + suffix,
+					filename:      fset.Position(file.Package).Filename,
+					exportedOnly:  true,
+					ignoreTag:     generator.DefaultIgnoreTag,
+					docs:          true,
+					writeToFile:   true,
+				}
+
+				if output != "" {
+					c.outputFilename = filepath.Join(dir, output)
+				}
+
+				configs = append(configs, c)
+			}
+		}
+	}
+
+	return configs, nil
+}
+
+// findBatchConfig returns the path of the nearest config in the current directory or its parents
+func findBatchConfig() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, batchConfigName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found", batchConfigName)
+		}
+		dir = parent
+	}
+}
+
+// loadBatchConfig reads the config from the json file
+func loadBatchConfig(path string) (*batchConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &batchConfig{}
+	if err := json.Unmarshal(data, batch); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+
+	return batch, nil
+}
+
+// config returns the configuration generating the entry's interface
+// into its output file or back into its source file
+func (e batchEntry) config(dir string) (config, error) {
+	if e.Type == "" || e.Interface == "" || e.File == "" {
+		return config{}, fmt.Errorf("config entry %+v: type, interface and file are required", e)
+	}
+
+	c := config{
+		typeNames:     strings.Split(e.Type, ","),
+		interfaceName: e.Interface,
+		filename:      filepath.Join(dir, e.File),
+		exportedOnly:  e.Exported,
+		include:       e.Include,
+		exclude:       e.Exclude,
+		returning:     e.Returning,
+		firstParam:    e.FirstParam,
+		maxParams:     e.MaxParams,
+		ignoreTag:     generator.DefaultIgnoreTag,
+		docs:          e.Doc == nil || *e.Doc,
+		assert:        e.Assert,
+		prune:         e.Prune,
+		typeCheck:     e.Types || e.Promoted,
+		promoted:      e.Promoted,
+		common:        e.Common,
+		groups:        e.Groups,
+		declGroup:     e.DeclGroup,
+		postprocess:   e.Postprocess,
+		writeToFile:   true,
+
+		keepResultNames: e.KeepResultNames,
+		skipDeprecated:  e.SkipDeprecated,
+		embedStd:        e.EmbedStd,
+		skipUnexported:  e.SkipUnexported,
+		importAlias:     e.ImportAlias,
+		usedIn:          e.UsedIn,
+	}
+
+	if e.UsedBy != "" {
+		c.usedBy = filepath.Join(dir, e.UsedBy)
+	}
+
+	switch e.MethodSet {
+	case "", "pointer":
+	case "value":
+		c.valueMethodSet = true
+		c.typeCheck = true
+	default:
+		return config{}, fmt.Errorf("%s: invalid methodset %q: must be pointer or value", e.Interface, e.MethodSet)
+	}
+
+	switch e.ParamNames {
+	case "", "keep":
+	case "strip":
+		c.stripParamNames = true
+	default:
+		return config{}, fmt.Errorf("%s: invalid paramNames %q: must be keep or strip", e.Interface, e.ParamNames)
+	}
+
+	if e.IgnoreTag != nil {
+		c.ignoreTag = *e.IgnoreTag
+	}
+
+	if e.Header != "" {
+		header, err := ioutil.ReadFile(filepath.Join(dir, e.Header))
+		if err != nil {
+			return config{}, fmt.Errorf("%s: %v", e.Interface, err)
+		}
+		c.headerTemplate = string(header)
+	}
+
+	if e.Output != "" {
+		c.outputFilename = filepath.Join(dir, e.Output)
+	}
+
+	if e.Sort != "" {
+		order, err := generator.ParseOrder(e.Sort)
+		if err != nil {
+			return config{}, fmt.Errorf("%s: %v", e.Interface, err)
+		}
+		c.order = order
+	}
+
+	return c, nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// batchFiles is a package with several types to generate interfaces of in a batch
+var batchFiles = map[string]string{
+	"go.mod":   "module example.com/p\n",
+	"store.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n\ntype Cache struct{}\n\nfunc (c *Cache) Put(v int) {}\n",
+	"queue.go": "package p\n\ntype Queue struct{}\n\nfunc (q *Queue) Push(v int) {}\n",
+	"log.go":   "package p\n\ntype Log struct{}\n\nfunc (l *Log) Write(p []byte) (int, error) { return 0, nil }\n",
+}
+
+func TestRunConfigs(t *testing.T) {
+	tests := []struct {
+		name     string
+		configs  func(dir string) []config
+		contains map[string][]string // text the files contain after the run
+	}{
+		{
+			name: "same target",
+			configs: func(dir string) []config {
+				return []config{
+					writeConfig("Store", "Storer", filepath.Join(dir, "store.go")),
+					writeConfig("Cache", "Cacher", filepath.Join(dir, "store.go")),
+				}
+			},
+			contains: map[string][]string{
+				"store.go": {"type Storer interface {\n\tGet() int\n}", "type Cacher interface {\n\tPut(v int)\n}"},
+			},
+		},
+		{
+			name: "separate targets",
+			configs: func(dir string) []config {
+				return []config{
+					writeConfig("Store", "Storer", filepath.Join(dir, "store.go")),
+					writeConfig("Queue", "Queuer", filepath.Join(dir, "queue.go")),
+					writeConfig("Log", "Logger", filepath.Join(dir, "log.go")),
+				}
+			},
+			contains: map[string][]string{
+				"store.go": {"type Storer interface {\n\tGet() int\n}"},
+				"queue.go": {"type Queuer interface {\n\tPush(v int)\n}"},
+				"log.go":   {"type Logger interface {\n\tWrite(p []byte) (int, error)\n}"},
+			},
+		},
+		{
+			name: "same output file",
+			configs: func(dir string) []config {
+				configs := []config{
+					writeConfig("Store", "Storer", filepath.Join(dir, "store.go")),
+					writeConfig("Cache", "Cacher", filepath.Join(dir, "store.go")),
+					writeConfig("Queue", "Queuer", filepath.Join(dir, "queue.go")),
+					writeConfig("Log", "Logger", filepath.Join(dir, "log.go")),
+				}
+				for i := range configs {
+					configs[i].outputFilename = filepath.Join(dir, "ifaces.go")
+				}
+				configs[3].outputFilename = filepath.Join(dir, "logger.go")
+
+				return configs
+			},
+			contains: map[string][]string{
+				"ifaces.go": {"type Storer interface", "type Cacher interface", "type Queuer interface"},
+				"logger.go": {"type Logger interface"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, batchFiles)
+			t.Chdir(dir)
+
+			cached = newFileCache(true)
+			defer func() { cached = nil }()
+
+			configs := test.configs(dir)
+			if err := runConfigs("test", configs, false, false, false, 4, "text"); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, wants := range test.contains {
+				got := readFile(t, filepath.Join(dir, name))
+				for _, want := range wants {
+					if !strings.Contains(got, want) {
+						t.Errorf("%s:\n%s\nwant it to contain:\n%s", name, got, want)
+					}
+				}
+			}
+
+			cached.newRun()
+			if err := runConfigs("test", configs, false, true, false, 4, "text"); err != nil {
+				t.Errorf("check after generating: %v", err)
+			}
+		})
+	}
+}
+
+func TestRunConfigsReportsEveryFailure(t *testing.T) {
+	dir := writeFiles(t, batchFiles)
+	t.Chdir(dir)
+
+	configs := []config{
+		writeConfig("Store", "Storer", filepath.Join(dir, "store.go")),
+		writeConfig("Store", "Missinger", filepath.Join(dir, "missing.go")),
+		writeConfig("Queue", "Queuer", filepath.Join(dir, "queue.go")),
+		writeConfig("Log", "Absenter", filepath.Join(dir, "absent.go")),
+	}
+
+	stderr, err := capture(t, &os.Stderr, func() error {
+		return runConfigs("test", configs, false, false, false, 4, "text")
+	})
+	if err == nil || !strings.Contains(err.Error(), "not all interfaces were generated") {
+		t.Fatalf("error %v, want not all interfaces were generated", err)
+	}
+
+	for _, want := range []string{"missing.go", "absent.go"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr\n%s\nwant it to report %s", stderr, want)
+		}
+	}
+
+	if got := readFile(t, filepath.Join(dir, "queue.go")); !strings.Contains(got, "type Queuer interface") {
+		t.Errorf("queue.go:\n%s\nwant the interfaces after a failure still generated", got)
+	}
+}
+
+// generateFiles is a module with a config of interfaces to generate in two packages
+var generateFiles = map[string]string{
+	"go.mod": "module example.com/p\n",
+	batchConfigName: `{
+	"interfaces": [
+		{"type": "Store", "interface": "Storer", "file": "store/store.go"},
+		{"type": "Cache", "interface": "Cacher", "file": "store/store.go", "output": "store/iface.go"},
+		{"type": "Queue", "interface": "Queuer", "file": "queue/queue.go", "exported": true}
+	]
+}
+`,
+	"store/store.go": "package store\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n\ntype Cache struct{}\n\nfunc (c *Cache) Put(v int) {}\n",
+	"queue/queue.go": "package queue\n\ntype Queue struct{}\n\nfunc (q *Queue) Push(v int) {}\n\nfunc (q *Queue) grow() {}\n",
+}
+
+func TestRunGenerate(t *testing.T) {
+	tests := []struct {
+		name     string
+		subdir   string // the run's directory, the config's by default
+		args     []string
+		contains map[string]string // text the files contain after the run
+		absent   map[string]string // or text they don't
+	}{
+		{
+			name: "config",
+			args: []string{"-j", "2"},
+			contains: map[string]string{
+				"store/store.go": "type Storer interface {\n\tGet() int\n}",
+				"store/iface.go": "type Cacher interface {\n\tPut(v int)\n}",
+				"queue/queue.go": "type Queuer interface {\n\tPush(v int)\n}",
+			},
+		},
+		{
+			name:     "config in a parent directory",
+			subdir:   "queue",
+			contains: map[string]string{"queue/queue.go": "type Queuer interface"},
+		},
+		{
+			name:     "config flag",
+			subdir:   "queue",
+			args:     []string{"-config", filepath.Join("..", batchConfigName)},
+			contains: map[string]string{"store/iface.go": "type Cacher interface"},
+		},
+		{
+			name:     "packages of the config",
+			args:     []string{"./queue"},
+			contains: map[string]string{"queue/queue.go": "type Queuer interface"},
+			absent:   map[string]string{"store/store.go": "type Storer interface"},
+		},
+		{
+			name: "all",
+			args: []string{"-all", "./..."},
+			contains: map[string]string{
+				"store/store.go": "type StoreIface interface {\n\tGet() int\n}",
+				"queue/queue.go": "type QueueIface interface {\n\tPush(v int)\n}",
+			},
+			absent: map[string]string{"queue/queue.go": "\tgrow()"},
+		},
+		{
+			name: "all with a suffix into a file",
+			args: []string{"-all", "-suffix", "er", "-o", "ifaces.go", "./store"},
+			contains: map[string]string{
+				"store/ifaces.go": "type Storeer interface",
+			},
+			absent: map[string]string{"store/store.go": "interface"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, generateFiles)
+			t.Chdir(filepath.Join(dir, test.subdir))
+			t.Cleanup(func() { cached = nil })
+
+			if err := runGenerate(test.args); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range test.contains {
+				if got := readFile(t, filepath.Join(dir, name)); !strings.Contains(got, want) {
+					t.Errorf("%s:\n%s\nwant it to contain:\n%s", name, got, want)
+				}
+			}
+
+			for name, unwanted := range test.absent {
+				if got := readFile(t, filepath.Join(dir, name)); strings.Contains(got, unwanted) {
+					t.Errorf("%s:\n%s\nwant it not to contain:\n%s", name, got, unwanted)
+				}
+			}
+
+			if err := runGenerate(append([]string{"-check"}, test.args...)); err != nil {
+				t.Errorf("check after generating: %v", err)
+			}
+		})
+	}
+}
+
+func TestRunGenerateInvalidEntry(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		batchConfigName: `{"interfaces": [{"type": "Store", "file": "store.go"}, {"type": "Store", "interface": "Storer", "file": "store.go"}]}`,
+		"store.go":      "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+	})
+	t.Chdir(dir)
+	t.Cleanup(func() { cached = nil })
+
+	stderr, err := capture(t, &os.Stderr, func() error { return runGenerate(nil) })
+	if err == nil || !strings.Contains(err.Error(), "not all interfaces were generated") {
+		t.Errorf("error %v, want not all interfaces were generated", err)
+	}
+
+	if !strings.Contains(stderr, "type, interface and file are required") {
+		t.Errorf("stderr\n%s\nwant it to report the entry without an interface", stderr)
+	}
+
+	if got := readFile(t, filepath.Join(dir, "store.go")); !strings.Contains(got, "type Storer interface") {
+		t.Errorf("store.go:\n%s\nwant the valid entry generated", got)
+	}
+}
+
+func TestRunConfigsIntoSharedFile(t *testing.T) {
+	files := map[string]string{"go.mod": "module example.com/p\n"}
+	ifaces := "package p\n"
+	configs := []config{}
+	for i := 1; i <= 8; i++ {
+		n := strconv.Itoa(i)
+		files["t"+n+".go"] = "package p\n\ntype T" + n + " struct{}\n\nfunc (t *T" + n + ") M" + n + "() {}\n"
+		ifaces += "\n// I" + n + " is the interface implemented by T" + n + ".\ntype I" + n + " interface {\n}\n"
+	}
+	files["ifaces.go"] = ifaces
+
+	dir := writeFiles(t, files)
+	t.Chdir(dir)
+	cached = newFileCache(true)
+	t.Cleanup(func() { cached = nil })
+
+	for i := 1; i <= 8; i++ {
+		n := strconv.Itoa(i)
+		configs = append(configs, writeConfig("T"+n, "I"+n, filepath.Join(dir, "t"+n+".go")))
+	}
+
+	if err := runConfigs("test", configs, false, false, false, 8, "text"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readFile(t, filepath.Join(dir, "ifaces.go"))
+	for i := 1; i <= 8; i++ {
+		n := strconv.Itoa(i)
+		if want := "type I" + n + " interface {\n\tM" + n + "()\n}"; !strings.Contains(got, want) {
+			t.Errorf("ifaces.go:\n%s\nwant it to contain:\n%s", got, want)
+		}
+	}
+}
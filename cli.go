@@ -0,0 +1,342 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// completing is set when the command line is being completed by a shell, as run by the
+// completion scripts. The flags are parsed by parseFlags into candidates instead
+var completing bool
+
+// positionals are the kinds of the arguments following the flags of each subcommand, "" being
+// the command generating an interface. The last kind of a subcommand taking several packages repeats
+var positionals = map[string][]string{
+	"":             {"type", "newInterface", "file"},
+	"generate":     {"package"},
+	"sync":         {"package"},
+	"list":         {"dir"},
+	"mock":         {"interface", "file"},
+	"stub":         {"interface", "type", "file"},
+	"spy":          {"interface", "file"},
+	"decorator":    {"interface", "type", "file"},
+	"implementers": {"interface", "file", "package"},
+	"diff":         {"interface", "package", "interface", "package"},
+	"rename":       {"interface", "", "file"},
+	"serve":        {},
+	"completion":   {"shell"},
+	"help":         {"subcommand"},
+}
+
+// subcommandNames are the names of the subcommands, watch included
+func subcommandNames() []string {
+	names := []string{"watch"}
+	for name := range positionals {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// newFlagSet returns the flag set of the subcommand, whose usage is its section of the usage text
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "%s\n\n", subcommandUsage(name))
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// subcommandUsage returns the section of the usage text describing the subcommand, from
+// its first synopsis up to the synopsis of the next subcommand
+func subcommandUsage(name string) string {
+	prefix := "gointefacegen " + name + " "
+	section := []string{}
+	for _, paragraph := range strings.Split(usage, "\n\n") {
+		switch {
+		case strings.HasPrefix(paragraph, prefix):
+			section = append(section, paragraph)
+		case len(section) > 0 && strings.HasPrefix(paragraph, "gointefacegen "):
+			return strings.Join(section, "\n\n")
+		case len(section) > 0:
+			section = append(section, paragraph)
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(section, "\n\n"))
+}
+
+// parseFlags parses the arguments of the flag set or, when completing, prints the candidates
+// completing the last argument and exits
+func parseFlags(flags *flag.FlagSet, args []string) {
+	if completing {
+		for _, candidate := range complete(flags, args) {
+			fmt.Println(candidate)
+		}
+		os.Exit(0)
+	}
+
+	flags.Parse(args)
+}
+
+// complete returns the candidates for the last of the arguments: the flags of the flag
+// set, or what the argument at its position names such as the types of the package.
+// Nothing is returned for a flag's value, for the shell to complete it as a file
+func complete(flags *flag.FlagSet, args []string) []string {
+	partial := ""
+	if len(args) > 0 {
+		partial, args = args[len(args)-1], args[:len(args)-1]
+	}
+
+	// the flags end at the first argument that isn't one, as they do for flag.Parse
+	inFlags := true
+	positional := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !inFlags || arg == "-" || !strings.HasPrefix(arg, "-") {
+			inFlags = false
+			positional = append(positional, arg)
+			continue
+		}
+
+		if arg == "--" {
+			inFlags = false
+			continue
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+
+		if f := flags.Lookup(name); f != nil && !isBoolFlag(f) {
+			if i == len(args)-1 {
+				return nil
+			}
+			i++
+		}
+	}
+
+	if inFlags && strings.HasPrefix(partial, "-") {
+		candidates := []string{}
+		flags.VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "-"+f.Name)
+		})
+
+		return matching(candidates, partial)
+	}
+
+	name := flags.Name()
+	if flags == flag.CommandLine {
+		name = ""
+	}
+
+	kinds := positionals[name]
+	if len(kinds) == 0 {
+		return nil
+	}
+
+	kind := kinds[len(kinds)-1]
+	if len(positional) < len(kinds) {
+		kind = kinds[len(positional)]
+	} else if kind != "package" {
+		return nil
+	}
+
+	candidates := []string{}
+	if name == "" && len(positional) == 0 {
+		candidates = append(candidates, subcommandNames()...)
+	}
+
+	switch kind {
+	case "type", "interface", "newInterface":
+		typeNames, interfaceNames := declaredTypes(positional)
+		switch kind {
+		case "type":
+			candidates = append(candidates, typeNames...)
+		case "interface":
+			candidates = append(candidates, interfaceNames...)
+		case "newInterface":
+			for _, typeName := range strings.Split(positional[0], ",") {
+				candidates = append(candidates, typeName+"Iface")
+			}
+			candidates = append(candidates, interfaceNames...)
+		}
+	case "file":
+		candidates = append(candidates, paths(partial, false)...)
+	case "dir", "package":
+		candidates = append(candidates, paths(partial, true)...)
+		if kind == "package" && strings.HasPrefix("./...", partial) {
+			candidates = append(candidates, "./...")
+		}
+	case "shell":
+		candidates = append(candidates, "bash", "fish", "zsh")
+	case "subcommand":
+		candidates = append(candidates, subcommandNames()...)
+	}
+
+	return matching(candidates, partial)
+}
+
+// isBoolFlag reports whether the flag takes no value, like -w
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// matching returns the candidates starting with prefix, sorted and without duplicates
+func matching(candidates []string, prefix string) []string {
+	sort.Strings(candidates)
+
+	matches := []string{}
+	for i, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) && (i == 0 || candidate != candidates[i-1]) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	return matches
+}
+
+// declaredTypes returns the names of the types and of the interfaces declared in the
+// package of the go file among the arguments or, without one, the current directory
+func declaredTypes(args []string) ([]string, []string) {
+	dir := "."
+	for _, arg := range args {
+		if strings.HasSuffix(arg, ".go") {
+			dir = filepath.Dir(arg)
+		}
+	}
+
+	files, err := parseDir(token.NewFileSet(), dir, nil, "")
+	if err != nil {
+		return nil, nil
+	}
+
+	typeNames, interfaceNames := []string{}, []string{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range genDecl.Specs {
+				tSpec := spec.(*ast.TypeSpec)
+				if _, ok := tSpec.Type.(*ast.InterfaceType); ok {
+					interfaceNames = append(interfaceNames, tSpec.Name.Name)
+				} else {
+					typeNames = append(typeNames, tSpec.Name.Name)
+				}
+			}
+		}
+	}
+
+	return typeNames, interfaceNames
+}
+
+// paths returns the directories, with a trailing slash, and unless dirsOnly the go
+// files in the directory of the partial path that it prefixes
+func paths(partial string, dirsOnly bool) []string {
+	dir, base := filepath.Split(partial)
+	entries, err := os.ReadDir(filepath.Join(".", dir))
+	if err != nil {
+		return nil
+	}
+
+	candidates := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+
+		switch {
+		case entry.IsDir():
+			candidates = append(candidates, dir+name+"/")
+		case !dirsOnly && strings.HasSuffix(name, ".go"):
+			candidates = append(candidates, dir+name)
+		}
+	}
+
+	return candidates
+}
+
+// runHelp runs the help subcommand, printing the usage of a subcommand or of the tool
+func runHelp(args []string) error {
+	flags := newFlagSet("help")
+	parseFlags(flags, args)
+
+	if flags.NArg() == 0 {
+		fmt.Printf("%s\n", usage)
+		return nil
+	}
+
+	name := flags.Arg(0)
+	if _, ok := positionals[name]; (!ok || name == "") && name != "watch" {
+		return fmt.Errorf("unknown subcommand %q. Subcommands: %s", name, strings.Join(subcommandNames(), ", "))
+	}
+
+	fmt.Println(subcommandUsage(name))
+	return nil
+}
+
+// completionScripts are the scripts completing the command line of each shell by
+// running the tool with the words typed so far
+var completionScripts = map[string]string{
+	"bash": `_gointerfacegen() {
+	local IFS=$'\n'
+	COMPREPLY=($(gointerfacegen __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+	if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == */ ]]; then
+		compopt -o nospace
+	fi
+}
+complete -o default -F _gointerfacegen gointerfacegen
+`,
+	"zsh": `#compdef gointerfacegen
+
+_gointerfacegen() {
+	local -a candidates
+	candidates=("${(@f)$(gointerfacegen __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	if [[ -n "${candidates[1]}" ]]; then
+		compadd -a candidates
+	else
+		_files
+	fi
+}
+
+compdef _gointerfacegen gointerfacegen
+`,
+	"fish": `function __gointerfacegen_complete
+	set -l words (commandline -opc)
+	gointerfacegen __complete $words[2..-1] (commandline -ct) 2>/dev/null
+end
+
+complete -c gointerfacegen -a '(__gointerfacegen_complete)'
+`,
+}
+
+// runCompletion runs the completion subcommand, printing the completion script of a shell
+func runCompletion(args []string) error {
+	flags := newFlagSet("completion")
+	parseFlags(flags, args)
+
+	script, ok := completionScripts[flags.Arg(0)]
+	if flags.NArg() != 1 || !ok {
+		return fmt.Errorf("usage: gointerfacegen completion bash|zsh|fish")
+	}
+
+	fmt.Print(script)
+	return nil
+}
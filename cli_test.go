@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"store.go":       "package p\n\ntype Store struct{}\n\ntype Storer interface{}\n\ntype Stack struct{}\n",
+		"store/user.go":  "package store\n",
+		"static/main.go": "package main\n",
+	})
+	t.Chdir(dir)
+
+	listFlags := newFlagSet("list")
+	listFlags.Bool("json", false, "")
+
+	// the command generating an interface is told apart from the subcommands by its flag set
+	commandFlags := flag.CommandLine
+	t.Cleanup(func() { flag.CommandLine = commandFlags })
+	flag.CommandLine = flag.NewFlagSet("gointerfacegen", flag.ContinueOnError)
+	flag.CommandLine.Bool("w", false, "")
+	flag.CommandLine.String("o", "", "")
+
+	tests := []struct {
+		name  string
+		flags *flag.FlagSet
+		args  []string
+		want  []string
+	}{
+		{"flags", listFlags, []string{"-"}, []string{"-json"}},
+		{"dirs", listFlags, []string{"st"}, []string{"static/", "store/"}},
+		{"nothing after the last argument", listFlags, []string{"store", ""}, nil},
+		{"types", flag.CommandLine, []string{"-w", "St"}, []string{"Stack", "Store"}},
+		{"new interface", flag.CommandLine, []string{"Store", ""}, []string{"StoreIface", "Storer"}},
+		{"file", flag.CommandLine, []string{"Store", "Storer", "st"}, []string{"static/", "store.go", "store/"}},
+		{"a flag's value", flag.CommandLine, []string{"-o", ""}, nil},
+		{"after a flag's value", flag.CommandLine, []string{"-o", "iface.go", "Sto"}, []string{"Store"}},
+	}
+
+	for _, test := range tests {
+		got := complete(test.flags, test.args)
+		if len(got) == 0 && len(test.want) == 0 {
+			got = nil
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: complete(%q) = %q, want %q", test.name, test.args, got, test.want)
+		}
+	}
+}
+
+func TestRunHelp(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string // the text the output starts with
+	}{
+		{nil, "gointefacegen <type>"},
+		{[]string{"list"}, "gointefacegen list [-json] [dir]\n\nLists the named types"},
+		{[]string{"completion"}, "gointefacegen completion bash|zsh|fish"},
+	}
+
+	for _, test := range tests {
+		got, err := captureStdout(t, func() error { return runHelp(test.args) })
+		if err != nil {
+			t.Fatalf("help %q: %v", test.args, err)
+		}
+
+		if !strings.HasPrefix(got, test.want) {
+			t.Errorf("help %q printed:\n%s\nwant it to start with:\n%s", test.args, got, test.want)
+		}
+	}
+
+	// the section of a subcommand ends where the next one's begins
+	got, _ := captureStdout(t, func() error { return runHelp([]string{"list"}) })
+	if strings.Contains(got, "gointefacegen mock") {
+		t.Errorf("help list printed the next subcommand too:\n%s", got)
+	}
+
+	if _, err := captureStdout(t, func() error { return runHelp([]string{"nope"}) }); err == nil || !strings.Contains(err.Error(), "unknown subcommand") {
+		t.Errorf("help nope: error %v, want unknown subcommand", err)
+	}
+}
+
+func TestRunCompletion(t *testing.T) {
+	for shell, script := range completionScripts {
+		got, err := captureStdout(t, func() error { return runCompletion([]string{shell}) })
+		if err != nil {
+			t.Fatalf("completion %s: %v", shell, err)
+		}
+
+		if got != script || !strings.Contains(got, "gointerfacegen __complete") {
+			t.Errorf("completion %s printed:\n%s", shell, got)
+		}
+	}
+
+	if _, err := captureStdout(t, func() error { return runCompletion([]string{"tcsh"}) }); err == nil {
+		t.Error("completion tcsh succeeded, want an error")
+	}
+}
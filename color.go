@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hankjacobs/gointerfacegen/internal/diff"
+)
+
+// colorMode is when diffs and checks are colored, as set by -color: auto, always or never
+var colorMode = "auto"
+
+// validColor returns an error unless mode is a -color mode
+func validColor(mode string) error {
+	switch mode {
+	case "auto", "always", "never":
+		return nil
+	}
+
+	return fmt.Errorf("invalid -color %q: must be auto, always or never", mode)
+}
+
+// colored reports whether what's printed to f is colored. auto colors a terminal
+// unless NO_COLOR is set (see https://no-color.org) or the terminal is dumb
+func colored(f *os.File) bool {
+	switch colorMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// errorText returns the message of the error as printed to standard error,
+// where the methods of interfaces out of date are colored
+func errorText(err error) string {
+	if stale, ok := err.(*staleError); ok {
+		return stale.text(colored(os.Stderr))
+	}
+
+	return err.Error()
+}
+
+// printSummary prints to standard error what changes in the methods of each interface,
+// such as
+//
+//	Store: 1 added, 1 changed
+//		+ Close() error
+//		~ Get(id int) (*User, error), was Get(id string) (*User, error)
+func printSummary(interfaceNames []string, changes []staleMethod) {
+	color := colored(os.Stderr)
+	for _, interfaceName := range interfaceNames {
+		counts := map[string]int{}
+		lines := []string{}
+		for _, m := range changes {
+			if m.interfaceName != interfaceName {
+				continue
+			}
+			counts[m.problem]++
+
+			line := ""
+			switch m.problem {
+			case "missing":
+				line = "+ " + m.want
+			case "outdated":
+				line = "~ " + m.want + ", was " + m.have
+			case "extra":
+				line = "- " + m.have
+			}
+
+			if color {
+				line = diff.Color(m.kind(), line)
+			}
+			lines = append(lines, "\t"+line)
+		}
+
+		summary := []string{}
+		for _, change := range []struct{ problem, label string }{{"missing", "added"}, {"outdated", "changed"}, {"extra", "removed"}} {
+			if counts[change.problem] > 0 {
+				summary = append(summary, fmt.Sprintf("%d %s", counts[change.problem], change.label))
+			}
+		}
+
+		if len(summary) == 0 {
+			summary = []string{"no methods change"}
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: %s\n", interfaceName, strings.Join(summary, ", "))
+		for _, line := range lines {
+			fmt.Fprintln(os.Stderr, line)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// runDiff runs the diff subcommand, printing the methods added, removed and changed from
+// an interface, or a type's method set, in one package to another in the same or another package
+//
+//	changed Get(id string) *User
+//	     to Get(id string) (*User, error)
+//	added   Put(u *User) error
+func runDiff(args []string) error {
+	flags := newFlagSet("diff")
+	parseFlags(flags, args)
+
+	if flags.NArg() != 4 {
+		return fmt.Errorf("usage: gointerfacegen diff <interface> <package> <interface> <package>")
+	}
+
+	fset := token.NewFileSet()
+	pkgs := []generator.Package{}
+	for _, pattern := range []string{flags.Arg(1), flags.Arg(3)} {
+		dirs, err := listPackages([]string{pattern})
+		if err != nil {
+			return err
+		}
+
+		if len(dirs) != 1 {
+			return fmt.Errorf("%s matches %d packages, not one", pattern, len(dirs))
+		}
+
+		for path, dir := range dirs {
+			files, err := parseDir(fset, dir, nil, "")
+			if err != nil {
+				return err
+			}
+
+			pkgs = append(pkgs, generator.Package{Path: path, Files: files})
+		}
+	}
+
+	changes, err := generator.Compare(fset, pkgs[0], flags.Arg(0), pkgs[1], flags.Arg(2))
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		switch {
+		case change.Old == "":
+			fmt.Printf("added   %s\n", change.New)
+		case change.New == "":
+			fmt.Printf("removed %s\n", change.Old)
+		default:
+			fmt.Printf("changed %s\n     to %s\n", change.Old, change.New)
+		}
+	}
+
+	return nil
+}
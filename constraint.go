@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// constraintTerms returns the terms of the type set of the constraint of the types, named
+// as the interface refers to them, instantiated with the type parameters of the interface.
+// With -tilde the terms are ~ followed by the underlying types of the types instead,
+// such as ~float64 for type Celsius float64, which must be written out in their declarations
+func constraintTerms(c config, fset *token.FileSet, files []*ast.File, names []string, typeParams *ast.FieldList, qualify bool) ([]string, error) {
+	args := ""
+	if typeParams != nil {
+		params := []string{}
+		for _, field := range typeParams.List {
+			for _, name := range field.Names {
+				params = append(params, name.Name)
+			}
+		}
+		args = "[" + strings.Join(params, ", ") + "]"
+	}
+
+	terms := []string{}
+	for i, typeName := range c.typeNames {
+		if !c.tilde {
+			terms = append(terms, names[i]+args)
+			continue
+		}
+
+		typeSpec := generator.FindType(files, typeName)
+		switch {
+		case typeSpec == nil:
+			return nil, fmt.Errorf("-tilde: type %s is not declared in %s. Use -types to look it up in its package", typeName, sourceName(c.filename))
+		case typeSpec.TypeParams != nil:
+			return nil, fmt.Errorf("-tilde cannot be used with generic type %s", typeName)
+		case typeSpec.Assign.IsValid():
+			return nil, fmt.Errorf("-tilde cannot be used with alias %s", typeName)
+		}
+
+		// ~T requires T to be its own underlying type, which a type declared as another
+		// named type isn't, and the types a literal refers to must be named in the interface's package
+		_, named := typeSpec.Type.(*ast.Ident)
+		for _, name := range typeNamesReferenced(typeSpec.Type) {
+			if !predeclared(name) && (named || qualify) {
+				return nil, fmt.Errorf("-tilde: the underlying type of %s refers to %s, which can't be written as a term", typeName, name)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, typeSpec.Type); err != nil {
+			return nil, err
+		}
+		terms = append(terms, "~"+buf.String())
+	}
+
+	return terms, nil
+}
+
+// typeNamesReferenced returns the names of the types the type expression refers to, qualified
+// when they are declared in another package, leaving out the names of struct fields and methods
+func typeNamesReferenced(expr ast.Expr) []string {
+	names := []string{}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			ast.Inspect(n.Type, func(n ast.Node) bool {
+				return collectTypeName(n, &names)
+			})
+			return false
+		default:
+			return collectTypeName(n, &names)
+		}
+	})
+
+	return names
+}
+
+// collectTypeName appends the name of the type the node refers to, if it does, to names
+// and reports whether the node's children should be inspected
+func collectTypeName(n ast.Node, names *[]string) bool {
+	switch n := n.(type) {
+	case *ast.SelectorExpr:
+		*names = append(*names, types.ExprString(n))
+		return false
+	case *ast.Ident:
+		*names = append(*names, n.Name)
+	}
+
+	return true
+}
+
+// predeclared reports whether name is a predeclared type, such as int or error
+func predeclared(name string) bool {
+	_, ok := types.Universe.Lookup(name).(*types.TypeName)
+	return ok
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// description is the method set extracted for an interface as printed by -format json
+type description struct {
+	Package    string            `json:"package"` // of the types
+	Types      []string          `json:"types"`
+	Interface  string            `json:"interface"`
+	TypeParams []describedField  `json:"typeParams,omitempty"`
+	Methods    []describedMethod `json:"methods"`
+}
+
+// describedMethod is a method of the set
+type describedMethod struct {
+	Name string `json:"name"`
+	Doc  string `json:"doc,omitempty"`
+
+	// pointer or value, empty for a method synthesized by type checking
+	// such as a method promoted from a type of another package
+	Receiver string `json:"receiver,omitempty"`
+
+	Params   []describedField `json:"params"`
+	Results  []describedField `json:"results"`
+	Variadic bool             `json:"variadic"`
+}
+
+// describedField is a parameter, result or type parameter. Its name is empty when unnamed
+type describedField struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+}
+
+// describe returns the descriptions of the method sets of the interfaces to be generated,
+// one for each group of methods with -groups
+func describe(c config, pkgName string, typeParams *ast.FieldList, methods []*ast.FuncDecl) ([]description, error) {
+	groups := []generator.Group{{Methods: methods}}
+	if c.groups {
+		groups = generator.Groups(methods)
+		if len(groups) == 0 {
+			return nil, fmt.Errorf("no methods of %s have group directives", generator.JoinNames(c.typeNames))
+		}
+	}
+
+	descriptions := []description{}
+	for _, group := range groups {
+		d := description{
+			Package:    pkgName,
+			Types:      c.typeNames,
+			Interface:  c.interfaceName + group.Name,
+			TypeParams: describeFields(typeParams),
+			Methods:    []describedMethod{},
+		}
+
+		for _, method := range group.Methods {
+			m := describedMethod{
+				Name:    method.Name.Name,
+				Params:  describeFields(method.Type.Params),
+				Results: describeFields(method.Type.Results),
+			}
+
+			if method.Doc != nil {
+				m.Doc = method.Doc.Text()
+			}
+
+			if method.Recv != nil && len(method.Recv.List) > 0 {
+				m.Receiver = "value"
+				if _, ok := method.Recv.List[0].Type.(*ast.StarExpr); ok {
+					m.Receiver = "pointer"
+				}
+			}
+
+			if params := method.Type.Params; params != nil && len(params.List) > 0 {
+				_, m.Variadic = params.List[len(params.List)-1].Type.(*ast.Ellipsis)
+			}
+
+			d.Methods = append(d.Methods, m)
+		}
+
+		descriptions = append(descriptions, d)
+	}
+
+	return descriptions, nil
+}
+
+// describeFields returns the fields one per name, such as two for a, b int
+func describeFields(fields *ast.FieldList) []describedField {
+	described := []describedField{}
+	if fields == nil {
+		return described
+	}
+
+	for _, field := range fields.List {
+		typ := types.ExprString(field.Type)
+		if len(field.Names) == 0 {
+			described = append(described, describedField{Type: typ})
+		}
+
+		for _, name := range field.Names {
+			described = append(described, describedField{Name: name.Name, Type: typ})
+		}
+	}
+
+	return described
+}
+
+// printDescriptions prints the descriptions as json
+func printDescriptions(descriptions []description) error {
+	out, err := json.MarshalIndent(descriptions, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+// printMarkdown prints the descriptions as a summary of each interface in markdown,
+// a section with the signature and doc comment of each method
+func printMarkdown(descriptions []description) error {
+	var b strings.Builder
+	for i, d := range descriptions {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		typeNames := []string{}
+		for _, name := range d.Types {
+			typeNames = append(typeNames, "`"+name+"`")
+		}
+
+		fmt.Fprintf(&b, "## %s\n\n", d.Interface)
+		fmt.Fprintf(&b, "`%s%s` is the interface implemented by %s of package `%s`.\n", d.Interface, typeParamsText(d.TypeParams), generator.JoinNames(typeNames), d.Package)
+		for _, m := range d.Methods {
+			fmt.Fprintf(&b, "\n### %s\n\n```go\n%s\n```\n", m.Name, signatureText(m))
+			if doc := strings.TrimSpace(m.Doc); doc != "" {
+				fmt.Fprintf(&b, "\n%s\n", doc)
+			}
+		}
+	}
+
+	fmt.Print(b.String())
+	return nil
+}
+
+// typeParamsText returns the type parameter list, such as [K comparable, V any], if there are any
+func typeParamsText(typeParams []describedField) string {
+	if len(typeParams) == 0 {
+		return ""
+	}
+
+	return "[" + fieldsText(typeParams) + "]"
+}
+
+// signatureText returns the method as it is written in an interface, such as Get(id string) (User, error)
+func signatureText(m describedMethod) string {
+	signature := m.Name + "(" + fieldsText(m.Params) + ")"
+	switch {
+	case len(m.Results) == 1 && m.Results[0].Name == "":
+		signature += " " + m.Results[0].Type
+	case len(m.Results) > 0:
+		signature += " (" + fieldsText(m.Results) + ")"
+	}
+
+	return signature
+}
+
+// fieldsText returns the fields separated by commas, each preceded by its name if it has one
+func fieldsText(fields []describedField) string {
+	texts := []string{}
+	for _, field := range fields {
+		if field.Name != "" {
+			texts = append(texts, field.Name+" "+field.Type)
+		} else {
+			texts = append(texts, field.Type)
+		}
+	}
+
+	return strings.Join(texts, ", ")
+}
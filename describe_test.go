@@ -0,0 +1,117 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRunDescribe(t *testing.T) {
+	src := `package store
+
+type Store[K comparable] struct{}
+
+// Get returns the value of the key.
+func (s *Store[K]) Get(key K) (int, error) { return 0, nil }
+
+func (s Store[K]) Keys(prefix string, limit ...int) []K { return nil }
+`
+
+	tests := []struct {
+		name     string
+		markdown bool
+		want     string
+	}{
+		{
+			name: "json",
+			want: `[
+	{
+		"package": "store",
+		"types": [
+			"Store"
+		],
+		"interface": "Storer",
+		"typeParams": [
+			{
+				"name": "K",
+				"type": "comparable"
+			}
+		],
+		"methods": [
+			{
+				"name": "Get",
+				"doc": "Get returns the value of the key.\n",
+				"receiver": "pointer",
+				"params": [
+					{
+						"name": "key",
+						"type": "K"
+					}
+				],
+				"results": [
+					{
+						"type": "int"
+					},
+					{
+						"type": "error"
+					}
+				],
+				"variadic": false
+			},
+			{
+				"name": "Keys",
+				"receiver": "value",
+				"params": [
+					{
+						"name": "prefix",
+						"type": "string"
+					},
+					{
+						"name": "limit",
+						"type": "...int"
+					}
+				],
+				"results": [
+					{
+						"type": "[]K"
+					}
+				],
+				"variadic": true
+			}
+		]
+	}
+]
+`,
+		},
+		{
+			name:     "markdown",
+			markdown: true,
+			want: "## Storer\n\n" +
+				"`Storer[K comparable]` is the interface implemented by `Store` of package `store`.\n\n" +
+				"### Get\n\n```go\nGet(key K) (int, error)\n```\n\nGet returns the value of the key.\n\n" +
+				"### Keys\n\n```go\nKeys(prefix string, limit ...int) []K\n```\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"store.go": src})
+
+			c := writeConfig("Store", "Storer", filepath.Join(dir, "store.go"))
+			c.writeToFile = false
+			c.describe, c.markdown = true, test.markdown
+
+			got, err := captureStdout(t, func() error { _, err := run(c); return err })
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != test.want {
+				t.Errorf("printed:\n%s\nwant:\n%s", got, test.want)
+			}
+
+			if after := readFile(t, filepath.Join(dir, "store.go")); after != src {
+				t.Errorf("describing wrote the file:\n%s", after)
+			}
+		})
+	}
+}
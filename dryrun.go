@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// dryRunReport collects what a -dry-run would do to each interface, reported once
+// every interface has been generated, from any number of goroutines
+type dryRunReport struct {
+	mu      sync.Mutex
+	entries []dryRunEntry
+}
+
+// dryRunEntry is what would happen to an interface of a file
+type dryRunEntry struct {
+	filename      string
+	interfaceName string
+	status        string // created, updated or unchanged
+	methods       int    // of the interface generated
+	added         int
+	changed       int
+	removed       int
+}
+
+// add records what writing the generated file would do to its interfaces. srcBytes
+// is nil when the file doesn't exist. An interface of a file that would change is
+// updated even when its methods don't, such as when its assertion or imports change
+func (r *dryRunReport) add(fset *token.FileSet, file *ast.File, filename string, srcBytes []byte, interfaceNames []string) error {
+	newSrc, err := newSource(fset, file, srcBytes)
+	if err != nil {
+		return err
+	}
+
+	var diskFset *token.FileSet
+	var diskFile *ast.File
+	if srcBytes != nil {
+		diskFset = token.NewFileSet()
+		if diskFile, err = generator.ParseFile(diskFset, filename, srcBytes); err != nil {
+			return err
+		}
+	}
+
+	changes := methodChanges(fset, file, diskFset, diskFile, interfaceNames)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, interfaceName := range interfaceNames {
+		entry := dryRunEntry{
+			filename:      filename,
+			interfaceName: interfaceName,
+			status:        "updated",
+			methods:       len(interfaceMethods(fset, file, interfaceName)),
+		}
+
+		if diskFile == nil {
+			entry.status = "created"
+		} else if _, err := generator.FindInterface(diskFile, interfaceName); err != nil {
+			entry.status = "created"
+		} else if bytes.Equal(srcBytes, newSrc) {
+			entry.status = "unchanged"
+		}
+
+		for _, m := range changes {
+			if m.interfaceName != interfaceName {
+				continue
+			}
+
+			switch m.problem {
+			case "missing":
+				entry.added++
+			case "outdated":
+				entry.changed++
+			case "extra":
+				entry.removed++
+			}
+		}
+
+		r.entries = append(r.entries, entry)
+	}
+
+	return nil
+}
+
+// print prints an interface a line, by file, followed by the totals, such as
+//
+//	store/iface.go: Store would be created with 3 methods
+//	store/user.go: UserStore would be updated, 1 added, 1 removed
+//	client.go: API is unchanged
+//	3 interfaces: 1 created, 1 updated, 1 unchanged
+func (r *dryRunReport) print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.SliceStable(r.entries, func(i, j int) bool {
+		return r.entries[i].filename < r.entries[j].filename
+	})
+
+	totals := map[string]int{}
+	for _, entry := range r.entries {
+		totals[entry.status]++
+
+		switch entry.status {
+		case "created":
+			fmt.Fprintf(w, "%s: %s would be created with %s\n", entry.filename, entry.interfaceName, plural(entry.methods, "method"))
+		case "unchanged":
+			fmt.Fprintf(w, "%s: %s is unchanged\n", entry.filename, entry.interfaceName)
+		default:
+			counts := []string{}
+			for _, count := range []struct {
+				n     int
+				label string
+			}{{entry.added, "added"}, {entry.changed, "changed"}, {entry.removed, "removed"}} {
+				if count.n > 0 {
+					counts = append(counts, fmt.Sprintf("%d %s", count.n, count.label))
+				}
+			}
+
+			if len(counts) == 0 {
+				counts = []string{"no methods change"}
+			}
+
+			fmt.Fprintf(w, "%s: %s would be updated, %s\n", entry.filename, entry.interfaceName, strings.Join(counts, ", "))
+		}
+	}
+
+	summary := []string{}
+	for _, status := range []string{"created", "updated", "unchanged"} {
+		if totals[status] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", totals[status], status))
+		}
+	}
+
+	if len(summary) == 0 {
+		summary = []string{"nothing to do"}
+	}
+
+	fmt.Fprintf(w, "%s: %s\n", plural(len(r.entries), "interface"), strings.Join(summary, ", "))
+}
+
+// plural returns the count followed by the noun, made plural unless the count is one
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+
+	return fmt.Sprintf("%d %ss", n, noun)
+}
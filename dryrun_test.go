@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		configs   []config
+		generated bool // the interfaces are generated before the dry run
+		want      string
+	}{
+		{
+			name:    "created",
+			files:   map[string]string{"store.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\nfunc (s *Store) Put(v int) {}\n"},
+			configs: []config{writeConfig("Store", "Storer", "store.go")},
+			want:    "store.go: Storer would be created with 2 methods\n1 interface: 1 created\n",
+		},
+		{
+			name:  "created with its file",
+			files: map[string]string{"store.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n"},
+			configs: func() []config {
+				c := writeConfig("Store", "Storer", "store.go")
+				c.outputFilename = "iface/storer.go"
+				return []config{c}
+			}(),
+			want: "iface/storer.go: Storer would be created with 1 method\n1 interface: 1 created\n",
+		},
+		{
+			name: "updated",
+			files: map[string]string{"store.go": `package p
+
+type Store struct{}
+
+func (s *Store) Get(id string) (int, error) { return 0, nil }
+
+func (s *Store) Put(id string, v int) error { return nil }
+
+// Storer is the interface implemented by Store.
+type Storer interface {
+	Get(id string) int
+	Delete(id string) error
+}
+`},
+			configs: func() []config {
+				c := writeConfig("Store", "Storer", "store.go")
+				c.prune = true
+				return []config{c}
+			}(),
+			want: "store.go: Storer would be updated, 1 added, 1 changed, 1 removed\n1 interface: 1 updated\n",
+		},
+		{
+			name:      "unchanged",
+			files:     map[string]string{"store.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n"},
+			configs:   []config{writeConfig("Store", "Storer", "store.go")},
+			generated: true,
+			want:      "store.go: Storer is unchanged\n1 interface: 1 unchanged\n",
+		},
+		{
+			name: "every outcome",
+			files: map[string]string{
+				"store.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+				"queue.go": "package p\n\ntype Queue struct{}\n\nfunc (q *Queue) Push(v int) {}\n\n// Queuer is the interface implemented by Queue.\ntype Queuer interface {\n}\n",
+				"log.go":   "package p\n\ntype Log struct{}\n\nfunc (l *Log) Write(p []byte) (int, error) { return 0, nil }\n",
+			},
+			configs: []config{
+				writeConfig("Store", "Storer", "store.go"),
+				writeConfig("Queue", "Queuer", "queue.go"),
+				writeConfig("Log", "Logger", "log.go"),
+			},
+			want: "log.go: Logger would be created with 1 method\nqueue.go: Queuer would be updated, 1 added\nstore.go: Storer would be created with 1 method\n3 interfaces: 2 created, 1 updated\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, test.files)
+			t.Chdir(dir)
+
+			if test.generated {
+				for _, c := range test.configs {
+					if _, err := run(c); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			before := dirContents(t, dir)
+
+			got, err := captureStdout(t, func() error {
+				return runConfigs("test", test.configs, false, false, true, 2, "text")
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != test.want {
+				t.Errorf("printed:\n%s\nwant:\n%s", got, test.want)
+			}
+
+			if after := dirContents(t, dir); !reflect.DeepEqual(after, before) {
+				t.Errorf("the dry run wrote to the files, from\n%v\nto\n%v", before, after)
+			}
+		})
+	}
+}
+
+// dirContents returns the contents of every file under dir keyed by their paths
+func dirContents(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	contents := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		contents[path] = readFile(t, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return contents
+}
+
+func TestDryRunWithCheck(t *testing.T) {
+	err := runConfigs("test", nil, false, true, true, 1, "text")
+	if err == nil || err.Error() != "-dry-run cannot be used with -check" {
+		t.Errorf("error %v, want -dry-run cannot be used with -check", err)
+	}
+}
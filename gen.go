@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// pair is a type, or several comma separated types, and the interface generated from them
+type pair struct {
+	typeNames     []string
+	interfaceName string
+}
+
+// pairsFlag collects the pairs of repeated -gen Type:Interface flags
+type pairsFlag []pair
+
+func (f *pairsFlag) String() string {
+	pairs := []string{}
+	for _, p := range *f {
+		pairs = append(pairs, strings.Join(p.typeNames, ",")+":"+p.interfaceName)
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func (f *pairsFlag) Set(value string) error {
+	i := strings.LastIndex(value, ":")
+	if i <= 0 || i == len(value)-1 {
+		return fmt.Errorf("%q is not of the form Type:Interface", value)
+	}
+
+	*f = append(*f, pair{typeNames: strings.Split(value[:i], ","), interfaceName: value[i+1:]})
+	return nil
+}
+
+// generateAll generates the interface of each of the -gen pairs, or of the type given as
+// arguments, into the file. Each pair sees the file as the previous pair left it, and the
+// file is only written once with all of them
+func generateAll(c config) (*generated, error) {
+	if len(c.pairs) == 0 {
+		return generate(c)
+	}
+
+	// The file as generated so far stands in for the file on disk through the overlay,
+	// whose replacement contents are read from files
+	tmpDir, err := ioutil.TempDir("", "gointerfacegen")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	o := overlay{}
+	for path, replacement := range c.overlay {
+		o[path] = replacement
+	}
+
+	var all *generated
+	for i, p := range c.pairs {
+		pc := c
+		pc.typeNames, pc.interfaceName, pc.overlay = p.typeNames, p.interfaceName, o
+
+		g, err := generate(pc)
+		if err != nil {
+			return nil, err
+		}
+
+		// descriptions leave the file as it is
+		if c.describe {
+			if all == nil {
+				all = &generated{}
+			}
+			all.descriptions = append(all.descriptions, g.descriptions...)
+			continue
+		}
+
+		// what's on disk is what the first pair read
+		if all == nil {
+			all = g
+		} else {
+			all.fset, all.file = g.fset, g.file
+			all.interfaceNames = append(all.interfaceNames, g.interfaceNames...)
+		}
+
+		var buf bytes.Buffer
+		if err := format.Node(&buf, g.fset, g.file); err != nil {
+			return nil, err
+		}
+
+		replacement := filepath.Join(tmpDir, strconv.Itoa(i)+".go")
+		if err := ioutil.WriteFile(replacement, buf.Bytes(), 0644); err != nil {
+			return nil, err
+		}
+
+		abs, err := filepath.Abs(g.filename)
+		if err != nil {
+			return nil, err
+		}
+		o[abs] = replacement
+	}
+
+	return all, nil
+}
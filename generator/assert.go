@@ -0,0 +1,161 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// AddAssertion adds a compile-time assertion that typeName implements the interface
+//
+//	var _ MyIface = (*MyType)(nil)
+//
+// to the file and returns the resulting file parsed into fset. The assertion is placed below
+// the declaration of typeName or, when typeName is declared elsewhere, below the interface.
+// A file that already asserts that typeName implements the interface is returned as is
+func AddAssertion(fset *token.FileSet, file *ast.File, interfaceName, typeName string) (*ast.File, error) {
+	if hasAssertion(file, interfaceName, typeName) {
+		return file, nil
+	}
+
+	file, origSrc, err := source(fset, file)
+	if err != nil {
+		return nil, err
+	}
+
+	below, err := findTypeSpec(typeName, file)
+	if err != nil {
+		below, err = findTypeSpec(interfaceName, file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if below.TypeParams != nil {
+		return nil, diagnosticAt(fset, below.Pos(), CodeGeneric, below.Name.Name, "cannot assert that generic type %s implements %s", typeName, interfaceName)
+	}
+
+	genDecl := findTopLevelGenDeclForTypeSpec(below, file)
+	if genDecl == nil {
+		return nil, diagnosticAt(fset, below.Pos(), CodeNotTopLevel, below.Name.Name, "type %s is not declared at the top level", below.Name.Name)
+	}
+
+	// follow any assertions already below the declaration
+	end := genDecl.End()
+	for i, decl := range file.Decls {
+		if decl.Pos() < end || i == 0 || file.Decls[i-1].End() != end {
+			continue
+		}
+
+		if next, ok := decl.(*ast.GenDecl); ok && isAssertion(next) {
+			end = next.End()
+		}
+	}
+
+	at := fset.Position(end).Offset
+	assertion := fmt.Sprintf("\n\nvar _ %s = (*%s)(nil)", interfaceName, typeName)
+	newSrc := origSrc[:at] + assertion + origSrc[at:]
+
+	filename := fset.Position(file.Package).Filename
+	return parseFormatted(fset, filename, newSrc)
+}
+
+// isAssertion reports whether the declaration is a single blank variable such as
+//
+//	var _ MyIface = (*MyType)(nil)
+func isAssertion(decl *ast.GenDecl) bool {
+	if decl.Tok != token.VAR || len(decl.Specs) != 1 {
+		return false
+	}
+
+	vSpec := decl.Specs[0].(*ast.ValueSpec)
+	return len(vSpec.Names) == 1 && vSpec.Names[0].Name == "_" && vSpec.Type != nil
+}
+
+// hasAssertion reports whether the file declares a blank variable of the
+// interface's type whose value refers to typeName, which may be qualified
+func hasAssertion(file *ast.File, interfaceName, typeName string) bool {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.VAR {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			vSpec := spec.(*ast.ValueSpec)
+			if len(vSpec.Names) != 1 || vSpec.Names[0].Name != "_" {
+				continue
+			}
+
+			if ident, ok := vSpec.Type.(*ast.Ident); !ok || ident.Name != interfaceName || len(vSpec.Values) != 1 {
+				continue
+			}
+
+			refersToType := false
+			ast.Inspect(vSpec.Values[0], func(n ast.Node) bool {
+				if expr, ok := n.(ast.Expr); ok && types.ExprString(expr) == typeName {
+					refersToType = true
+				}
+
+				return !refersToType
+			})
+
+			if refersToType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// AssertionTest returns the source of a test file of package pkgName asserting that each of
+// the types implements each of the interfaces, both at compile time and in a test that go test
+// reports. Pointers to the types are asserted to implement the interfaces unless value is set,
+// when the values of the types must. A type may be qualified by a package of the imports
+//
+//	var _ MyIface = (*MyType)(nil)
+//
+//	func TestMyTypeImplementsMyIface(t *testing.T) {
+//		var v interface{} = (*MyType)(nil)
+//		if _, ok := v.(MyIface); !ok {
+//			t.Error("*MyType does not implement MyIface")
+//		}
+//	}
+func AssertionTest(header, pkgName string, imports []Import, interfaceNames, typeNames []string, value bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if header != "" {
+		buf.WriteString(header + "\n\n")
+	}
+
+	fmt.Fprintf(&buf, "package %s\n\nimport (\n\t\"testing\"\n", pkgName)
+	for _, imp := range imports {
+		fmt.Fprintf(&buf, "\t%s %q\n", imp.Name, imp.Path)
+	}
+	buf.WriteString(")\n")
+
+	for _, interfaceName := range interfaceNames {
+		for _, typeName := range typeNames {
+			implementer, expr := "*"+typeName, "(*"+typeName+")(nil)"
+			if value {
+				implementer, expr = typeName, "*new("+typeName+")"
+			}
+
+			// a qualified type is named without its package
+			name := typeName[strings.LastIndex(typeName, ".")+1:]
+
+			fmt.Fprintf(&buf, "\nvar _ %s = %s\n", interfaceName, expr)
+			fmt.Fprintf(&buf, "\nfunc Test%sImplements%s(t *testing.T) {\n", UpperFirst(name), UpperFirst(interfaceName))
+			fmt.Fprintf(&buf, "\tvar v interface{} = %s\n", expr)
+			fmt.Fprintf(&buf, "\tif _, ok := v.(%s); !ok {\n", interfaceName)
+			fmt.Fprintf(&buf, "\t\tt.Error(%q)\n", implementer+" does not implement "+interfaceName)
+			buf.WriteString("\t}\n}\n")
+		}
+	}
+
+	return format.Source(buf.Bytes())
+}
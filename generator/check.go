@@ -0,0 +1,102 @@
+package generator
+
+import (
+	"errors"
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"sync"
+)
+
+// Checker type checks packages for ResolveMethods and PackageMethods, importing the packages
+// they import from source once for all of them. A package is only checked again when it is
+// given other files, parsed anew. A Checker may be used by several goroutines at once
+type Checker struct {
+	mu      sync.Mutex
+	fset    *token.FileSet
+	imp     types.Importer
+	checked map[string]checkedPackage // keyed by the name of the package's first file
+}
+
+// checkedPackage is a package type checked from its files
+type checkedPackage struct {
+	files []*ast.File
+	pkg   *types.Package
+}
+
+// NewChecker returns a Checker of files parsed into fset
+func NewChecker(fset *token.FileSet) *Checker {
+	return &Checker{
+		fset:    fset,
+		imp:     importer.ForCompiler(fset, "source", nil),
+		checked: make(map[string]checkedPackage),
+	}
+}
+
+// check type checks files, the files of a single package, with the checker or on their
+// own when the checker is nil or the files are parsed into another file set
+func (c *Checker) check(fset *token.FileSet, files []*ast.File) *types.Package {
+	if c == nil || fset != c.fset || len(files) == 0 {
+		return checkFiles(fset, files, importer.ForCompiler(fset, "source", nil))
+	}
+
+	// the importer imports one package at a time
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := fset.Position(files[0].Package).Filename
+	if checked, ok := c.checked[key]; ok && sameFiles(checked.files, files) {
+		return checked.pkg
+	}
+
+	pkg := checkFiles(fset, files, c.imp)
+	c.checked[key] = checkedPackage{files: files, pkg: pkg}
+	return pkg
+}
+
+// checkFiles type checks files importing packages with imp
+func checkFiles(fset *token.FileSet, files []*ast.File, imp types.Importer) *types.Package {
+	conf := types.Config{
+		Importer:    imp,
+		Error:       func(error) {}, // keep going to resolve as much as possible
+		FakeImportC: true,           // accept the import "C" of cgo files
+	}
+
+	pkg, _ := conf.Check("", fset, files, nil)
+	return pkg
+}
+
+// sameFiles reports whether a and b are the same parsed files
+func sameFiles(a, b []*ast.File) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Validate type checks files, the files of a single package, and returns the errors found in
+// the file named filename joined into one, or nil if it compiles. Errors elsewhere in the package
+// are left out, as are those of imported packages
+func Validate(fset *token.FileSet, files []*ast.File, filename string) error {
+	errs := []error{}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			if typeErr, ok := err.(types.Error); ok && typeErr.Fset.Position(typeErr.Pos).Filename == filename {
+				errs = append(errs, err)
+			}
+		},
+		FakeImportC: true, // accept the import "C" of cgo files
+	}
+	conf.Check("", fset, files, nil)
+
+	return errors.Join(errs...)
+}
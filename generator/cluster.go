@@ -0,0 +1,250 @@
+package generator
+
+import (
+	"go/ast"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Cluster is a group of methods that use the same fields of their type
+type Cluster struct {
+	Group
+	Fields []string // the fields the methods use, sorted
+}
+
+// Clusters splits the methods of the named type declared in files into the clusters of methods that
+// use the same fields of the type, by way of the other methods of the type they call too. Methods
+// are in the same cluster when they share a field with each other or with another method of it.
+// A method using more than half of the fields, and more than two, such as Close or Reset, would
+// join every cluster into one, so it is put in the cluster it shares the most fields with instead.
+// A cluster is named after the field most of its methods use, exported, such as DB for db. The
+// methods that use no fields, or whose bodies aren't in files, make up a last cluster without a
+// name. The clusters are in the order of their first methods
+func Clusters(files []*ast.File, typeName string, methods []*ast.FuncDecl) []Cluster {
+	// the fields each method of the type uses itself and the methods it calls
+	declared := ExtractMethods(files, typeName)
+	isMethod := make(map[string]bool)
+	for _, method := range append(declared, methods...) {
+		isMethod[method.Name.Name] = true
+	}
+
+	uses := make(map[string]map[string]bool)
+	calls := make(map[string][]string)
+	for _, method := range declared {
+		name := method.Name.Name
+		uses[name] = make(map[string]bool)
+
+		recv := namedReceiver(method)
+		if recv == "" || method.Body == nil {
+			continue
+		}
+
+		ast.Inspect(method.Body, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == recv {
+				if isMethod[sel.Sel.Name] {
+					calls[name] = append(calls[name], sel.Sel.Name)
+				} else {
+					uses[name][sel.Sel.Name] = true
+				}
+			}
+			return true
+		})
+	}
+
+	// a method uses the fields of the methods it calls, and of those they call
+	fieldsOf := func(name string) map[string]bool {
+		fields := make(map[string]bool)
+		seen := map[string]bool{name: true}
+		pending := []string{name}
+		for len(pending) > 0 {
+			current := pending[0]
+			pending = pending[1:]
+			for field := range uses[current] {
+				fields[field] = true
+			}
+
+			for _, callee := range calls[current] {
+				if !seen[callee] {
+					seen[callee] = true
+					pending = append(pending, callee)
+				}
+			}
+		}
+
+		return fields
+	}
+
+	// the methods sharing a field are joined into one cluster
+	parent := make([]int, len(methods))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	methodFields := make([]map[string]bool, len(methods))
+	allFields := make(map[string]bool)
+	for i, method := range methods {
+		methodFields[i] = fieldsOf(method.Name.Name)
+		for field := range methodFields[i] {
+			allFields[field] = true
+		}
+	}
+
+	hub := make([]bool, len(methods))
+	for i := range methods {
+		hub[i] = len(methodFields[i]) > 2 && len(methodFields[i])*2 > len(allFields)
+	}
+
+	// union joins the methods sharing a field, either the hubs or the others
+	union := func(hubs bool) {
+		firstUser := make(map[string]int)
+		for i := range methods {
+			if hub[i] != hubs || hubs && find(i) != i {
+				continue
+			}
+
+			for field := range methodFields[i] {
+				if j, ok := firstUser[field]; ok {
+					parent[find(i)] = find(j)
+				} else {
+					firstUser[field] = i
+				}
+			}
+		}
+	}
+	union(false)
+
+	// the fields of each cluster, and the clusters in the order of their first methods
+	roots := []int{}
+	rootFields := make(map[int]map[string]bool)
+	for i := range methods {
+		if hub[i] || len(methodFields[i]) == 0 {
+			continue
+		}
+
+		root := find(i)
+		if rootFields[root] == nil {
+			rootFields[root] = make(map[string]bool)
+			roots = append(roots, root)
+		}
+		for field := range methodFields[i] {
+			rootFields[root][field] = true
+		}
+	}
+
+	// a hub goes to the first of the clusters it shares the most fields with
+	for i := range methods {
+		if !hub[i] {
+			continue
+		}
+
+		best, most := -1, 0
+		for _, root := range roots {
+			shared := 0
+			for field := range methodFields[i] {
+				if rootFields[root][field] {
+					shared++
+				}
+			}
+
+			if shared > most {
+				best, most = root, shared
+			}
+		}
+
+		if best >= 0 {
+			parent[i] = best
+		}
+	}
+
+	// and the hubs sharing no field with the other methods are joined among themselves
+	union(true)
+
+	clusters := []Cluster{}
+	index := make(map[int]int)
+	rest := Cluster{}
+	for i, method := range methods {
+		if len(methodFields[i]) == 0 {
+			rest.Methods = append(rest.Methods, method)
+			continue
+		}
+
+		root := find(i)
+		c, ok := index[root]
+		if !ok {
+			c = len(clusters)
+			index[root] = c
+			clusters = append(clusters, Cluster{})
+		}
+
+		clusters[c].Methods = append(clusters[c].Methods, method)
+	}
+
+	for i := range clusters {
+		counts := make(map[string]int)
+		for _, method := range clusters[i].Methods {
+			for field := range fieldsOf(method.Name.Name) {
+				counts[field]++
+			}
+		}
+
+		for field := range counts {
+			clusters[i].Fields = append(clusters[i].Fields, field)
+		}
+		sort.Strings(clusters[i].Fields)
+
+		// the first of the fields used the most names the cluster
+		best := clusters[i].Fields[0]
+		for _, field := range clusters[i].Fields {
+			if counts[field] > counts[best] {
+				best = field
+			}
+		}
+		clusters[i].Name = exportedName(best)
+	}
+
+	if len(rest.Methods) > 0 {
+		clusters = append(clusters, rest)
+	}
+
+	return clusters
+}
+
+// namedReceiver returns the name of the method's receiver, or "" if it is unnamed
+func namedReceiver(method *ast.FuncDecl) string {
+	if method.Recv == nil || len(method.Recv.List) == 0 || len(method.Recv.List[0].Names) == 0 {
+		return ""
+	}
+
+	if name := method.Recv.List[0].Names[0].Name; name != "_" {
+		return name
+	}
+
+	return ""
+}
+
+// exportedName returns the name with its first letter upper case, and all of it when it is an
+// initialism Go names in upper case, such as db, url or id
+func exportedName(name string) string {
+	switch strings.ToLower(name) {
+	case "api", "db", "dns", "html", "http", "id", "ip", "json", "rpc", "sql", "tcp", "tls", "ui", "url", "uri", "xml":
+		return strings.ToUpper(name)
+	}
+
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
@@ -0,0 +1,108 @@
+package generator
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// Union returns the methods of all of the method sets. A method found in several
+// of them is included once and must have the same signature in each
+func Union(methodSets ...[]*ast.FuncDecl) ([]*ast.FuncDecl, error) {
+	methods := []*ast.FuncDecl{}
+	seen := make(map[string]*ast.FuncDecl)
+	for _, methodSet := range methodSets {
+		for _, method := range methodSet {
+			name := method.Name.Name
+			if other, ok := seen[name]; ok {
+				if signature(other) != signature(method) {
+					return nil, newDiagnostic(CodeConflictingMethods, name, "conflicting signatures for method %s: %s and %s", name, name+signature(other), name+signature(method))
+				}
+
+				continue
+			}
+
+			seen[name] = method
+			methods = append(methods, method)
+		}
+	}
+
+	return methods, nil
+}
+
+// Intersect returns the methods found with the same signature in all of the method sets,
+// in the order of the first. It infers the contract shared by several implementations
+func Intersect(methodSets ...[]*ast.FuncDecl) []*ast.FuncDecl {
+	if len(methodSets) == 0 {
+		return []*ast.FuncDecl{}
+	}
+
+	return Filter(methodSets[0], func(method *ast.FuncDecl) bool {
+		for _, methodSet := range methodSets[1:] {
+			found := false
+			for _, other := range methodSet {
+				if other.Name.Name == method.Name.Name && signature(other) == signature(method) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+// Dedupe returns the methods with a method declared more than once, such as in files
+// for different platforms, included once. Declarations of a method with different
+// signatures are an error naming the files declaring them
+func Dedupe(fset *token.FileSet, methods []*ast.FuncDecl) ([]*ast.FuncDecl, error) {
+	deduped := []*ast.FuncDecl{}
+	seen := make(map[string]*ast.FuncDecl)
+	for _, method := range methods {
+		name := method.Name.Name
+		if other, ok := seen[name]; ok {
+			if signature(other) != signature(method) {
+				return nil, diagnosticAt(fset, method.Pos(), CodeConflictingMethods, name, "method %s is declared as %s in %s and as %s in %s", name,
+					name+signature(other), fset.Position(other.Pos()).Filename,
+					name+signature(method), fset.Position(method.Pos()).Filename)
+			}
+
+			continue
+		}
+
+		seen[name] = method
+		deduped = append(deduped, method)
+	}
+
+	return deduped, nil
+}
+
+// signature returns the method's parameter and result types without their names
+//
+// func (s *Store) Get(key string) (value []byte, err error)
+//
+// returns (string) ([]byte, error)
+func signature(method *ast.FuncDecl) string {
+	funcType := &ast.FuncType{Params: unnamed(method.Type.Params)}
+	if method.Type.Results != nil {
+		funcType.Results = unnamed(method.Type.Results)
+	}
+
+	return types.ExprString(funcType)[len("func"):]
+}
+
+// unnamed returns the fields without their names, sharing their types, for printing
+func unnamed(fields *ast.FieldList) *ast.FieldList {
+	new := &ast.FieldList{}
+	for _, field := range fields.List {
+		for i := 0; i < len(field.Names) || i == 0; i++ {
+			new.List = append(new.List, &ast.Field{Type: field.Type})
+		}
+	}
+
+	return new
+}
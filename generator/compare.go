@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"go/importer"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// MethodChange is a method by which two method sets differ
+type MethodChange struct {
+	Name string
+	Old  string // the method's signature in the first method set, empty when the method was added
+	New  string // the method's signature in the second method set, empty when the method was removed
+}
+
+// Compare type checks the packages and returns the methods by which the method set of the
+// type named bName declared in b differs from that of the type named aName declared in a,
+// sorted by name. The method set of an interface is its methods, those of embedded interfaces
+// included, and that of any other type is the method set of its pointer. Types are named
+// by their package's name, not its import path, so two versions of a package compare equal
+func Compare(fset *token.FileSet, a Package, aName string, b Package, bName string) ([]MethodChange, error) {
+	// the importer is shared so both packages see the same imported types
+	imp := importer.ForCompiler(fset, "source", nil)
+	check := func(pkg Package) *types.Package {
+		conf := types.Config{
+			Importer:    imp,
+			Error:       func(error) {}, // keep going to resolve as much as possible
+			FakeImportC: true,           // accept the import "C" of cgo files
+		}
+
+		checked, _ := conf.Check(pkg.Path, fset, pkg.Files, nil)
+		return checked
+	}
+
+	aPkg := check(a)
+	bPkg := aPkg
+	if b.Path != a.Path {
+		bPkg = check(b)
+	}
+
+	oldMethods, err := methodSet(aPkg, aName)
+	if err != nil {
+		return nil, err
+	}
+
+	newMethods, err := methodSet(bPkg, bName)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range oldMethods {
+		names = append(names, name)
+	}
+	for name := range newMethods {
+		if _, ok := oldMethods[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []MethodChange{}
+	for _, name := range names {
+		oldMethod, newMethod := oldMethods[name], newMethods[name]
+		switch {
+		case oldMethod == nil:
+			changes = append(changes, MethodChange{Name: name, New: name + methodSignature(newMethod)})
+		case newMethod == nil:
+			changes = append(changes, MethodChange{Name: name, Old: name + methodSignature(oldMethod)})
+		case unnamedSignature(oldMethod) != unnamedSignature(newMethod):
+			changes = append(changes, MethodChange{Name: name, Old: name + methodSignature(oldMethod), New: name + methodSignature(newMethod)})
+		}
+	}
+
+	return changes, nil
+}
+
+// methodSet returns the methods of the named type declared in the package keyed by name
+func methodSet(pkg *types.Package, typeName string) (map[string]*types.Func, error) {
+	obj, ok := pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil, newDiagnostic(CodeTypeNotFound, typeName, "type %s not found in %s", typeName, pkg.Path())
+	}
+
+	methods := make(map[string]*types.Func)
+	if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+		for i := 0; i < iface.NumMethods(); i++ {
+			methods[iface.Method(i).Name()] = iface.Method(i)
+		}
+
+		return methods, nil
+	}
+
+	methodSet := types.NewMethodSet(types.NewPointer(obj.Type()))
+	for i := 0; i < methodSet.Len(); i++ {
+		fn := methodSet.At(i).Obj().(*types.Func)
+		methods[fn.Name()] = fn
+	}
+
+	return methods, nil
+}
+
+// unnamedSignature returns the signature of the method as methodSignature does, without
+// the names of its parameters and results, which don't change the method
+func unnamedSignature(fn *types.Func) string {
+	sig := fn.Type().(*types.Signature)
+	unnamed := func(tuple *types.Tuple) *types.Tuple {
+		vars := []*types.Var{}
+		for i := 0; i < tuple.Len(); i++ {
+			vars = append(vars, types.NewParam(token.NoPos, nil, "", tuple.At(i).Type()))
+		}
+
+		return types.NewTuple(vars...)
+	}
+
+	qualifier := func(pkg *types.Package) string { return pkg.Name() }
+	return types.TypeString(types.NewSignatureType(nil, nil, nil, unnamed(sig.Params()), unnamed(sig.Results()), sig.Variadic()), qualifier)
+}
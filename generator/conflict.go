@@ -0,0 +1,93 @@
+package generator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// NameTaken reports whether name is declared at the top level of the file by something
+// other than an interface type, which an interface of that name can't be merged into
+func NameTaken(file *ast.File, name string) bool {
+	existing := file.Scope.Lookup(name)
+	if existing == nil {
+		return false
+	}
+
+	tSpec, ok := existing.Decl.(*ast.TypeSpec)
+	if !ok {
+		return true
+	}
+
+	_, isInterface := tSpec.Type.(*ast.InterfaceType)
+	return !isInterface
+}
+
+// FreeName returns the first name not taken in the file, as reported by NameTaken, out of
+// name followed by suffix and then name followed by suffix and 2, 3 and so on. Without a
+// suffix, name itself is skipped. A name declared in any of the other files of the package
+// is taken as well
+func FreeName(file *ast.File, name, suffix string, others ...*ast.File) string {
+	free := func(candidate string) bool {
+		for _, other := range others {
+			if other.Scope.Lookup(candidate) != nil {
+				return false
+			}
+		}
+
+		return !NameTaken(file, candidate)
+	}
+
+	if suffix != "" && free(name+suffix) {
+		return name + suffix
+	}
+
+	for n := 2; ; n++ {
+		candidate := name + suffix + strconv.Itoa(n)
+		if free(candidate) {
+			return candidate
+		}
+	}
+}
+
+// RemoveType removes the declaration of the named type, including its doc comment,
+// from the file and returns the resulting file parsed into fset. A type declared in
+// a group is removed from the group
+func RemoveType(fset *token.FileSet, file *ast.File, typeName string) (*ast.File, error) {
+	file, origSrc, err := source(fset, file)
+	if err != nil {
+		return nil, err
+	}
+
+	tSpec, err := findTypeSpec(typeName, file)
+	if err != nil {
+		return nil, err
+	}
+
+	genDecl := findTopLevelGenDeclForTypeSpec(tSpec, file)
+	if genDecl == nil {
+		return nil, diagnosticAt(fset, tSpec.Pos(), CodeNotTopLevel, typeName, "type %s is not declared at the top level", typeName)
+	}
+
+	var start, end token.Pos
+	if len(genDecl.Specs) == 1 {
+		start, end = genDecl.Pos(), genDecl.End()
+		if genDecl.Doc != nil {
+			start = genDecl.Doc.Pos()
+		}
+	} else {
+		start, end = tSpec.Pos(), tSpec.End()
+		if tSpec.Doc != nil {
+			start = tSpec.Doc.Pos()
+		}
+
+		if tSpec.Comment != nil {
+			end = tSpec.Comment.End()
+		}
+	}
+
+	newSrc := origSrc[:fset.Position(start).Offset] + origSrc[fset.Position(end).Offset:]
+
+	filename := fset.Position(file.Package).Filename
+	return ParseFile(fset, filename, []byte(newSrc))
+}
@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// Code identifies the kind of a Diagnostic. Codes are stable so tools can match on them
+type Code string
+
+const (
+	CodeTypeNotFound       Code = "GIG001" // the type isn't declared where it is looked up
+	CodeNameTaken          Code = "GIG002" // the interface's name is declared by something other than an interface
+	CodeNotTopLevel        Code = "GIG003" // a declaration isn't at the top level of its file
+	CodeInterfaceNotFound  Code = "GIG004" // the interface isn't declared where it is looked up
+	CodeConflictingMethods Code = "GIG005" // a method is declared with different signatures
+	CodeGeneric            Code = "GIG006" // a generic type or interface isn't supported there
+	CodeWrongKind          Code = "GIG007" // the identifier names a declaration of another kind, such as a variable
+	CodeUnexported         Code = "GIG008" // an unexported identifier can't be referenced from another package
+	CodeFuncNotFound       Code = "GIG009" // the function or method isn't declared in the package
+)
+
+// Diagnostic is an error about a declaration of the source. Its position is that of
+// the declaration involved or, when there is none, only the name of the file or
+// directory it was looked up in, or nothing
+type Diagnostic struct {
+	Pos   token.Position
+	Code  Code
+	Ident string // the identifier involved
+	Msg   string
+}
+
+func (d *Diagnostic) Error() string {
+	msg := fmt.Sprintf("%s (%s)", d.Msg, d.Code)
+	if d.Pos.Filename == "" && !d.Pos.IsValid() {
+		return msg
+	}
+
+	return d.Pos.String() + ": " + msg
+}
+
+// newDiagnostic returns a Diagnostic without a position, for a caller with a file set to place
+func newDiagnostic(code Code, ident, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{Code: code, Ident: ident, Msg: fmt.Sprintf(format, args...)}
+}
+
+// diagnosticAt returns a Diagnostic at pos, which may be token.NoPos
+func diagnosticAt(fset *token.FileSet, pos token.Pos, code Code, ident, format string, args ...interface{}) *Diagnostic {
+	d := newDiagnostic(code, ident, format, args...)
+	if pos.IsValid() {
+		d.Pos = fset.Position(pos)
+	}
+
+	return d
+}
+
+// diagnosticIn returns a Diagnostic in the file or directory without a position in it
+func diagnosticIn(filename string, code Code, ident, format string, args ...interface{}) *Diagnostic {
+	d := newDiagnostic(code, ident, format, args...)
+	d.Pos.Filename = filename
+	return d
+}
+
+// Place gives a Diagnostic without a position the position of its identifier in the file, which
+// may be nil, or failing that the file's name. Other errors are returned as they are
+func Place(err error, fset *token.FileSet, file *ast.File, filename string) error {
+	var d *Diagnostic
+	if !errors.As(err, &d) || d.Pos.Filename != "" {
+		return err
+	}
+
+	if file == nil {
+		d.Pos.Filename = filename
+	} else if obj := file.Scope.Lookup(d.Ident); obj != nil && obj.Pos().IsValid() {
+		d.Pos = fset.Position(obj.Pos())
+	} else {
+		d.Pos.Filename = filename
+	}
+
+	return err
+}
@@ -0,0 +1,267 @@
+package generator
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// directivePrefix starts the comments on methods that direct the generator, such as
+//
+//	//gointerfacegen:group Reader
+const directivePrefix = "//gointerfacegen:"
+
+// directives returns the arguments of each of the method's directives with the given name
+func directives(method *ast.FuncDecl, name string) [][]string {
+	if method.Doc == nil {
+		return nil
+	}
+
+	args := [][]string{}
+	for _, c := range method.Doc.List {
+		if !strings.HasPrefix(c.Text, directivePrefix) {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(c.Text, directivePrefix))
+		if len(fields) > 0 && fields[0] == name {
+			args = append(args, fields[1:])
+		}
+	}
+
+	return args
+}
+
+// withoutDirectives returns the comment group without directive comments,
+// or nil if nothing else is left
+func withoutDirectives(cg *ast.CommentGroup) *ast.CommentGroup {
+	if cg == nil {
+		return nil
+	}
+
+	new := &ast.CommentGroup{}
+	for _, c := range cg.List {
+		if !strings.HasPrefix(c.Text, directivePrefix) {
+			new.List = append(new.List, c)
+		}
+	}
+
+	// directives are separated from the rest of a doc comment by an empty line
+	for len(new.List) > 0 && strings.TrimSpace(new.List[len(new.List)-1].Text) == "//" {
+		new.List = new.List[:len(new.List)-1]
+	}
+
+	if len(new.List) == 0 {
+		return nil
+	}
+
+	return new
+}
+
+// Group is a group of methods named by group directives
+type Group struct {
+	Name    string
+	Methods []*ast.FuncDecl
+}
+
+// Groups returns the methods grouped by their group directives in the order the groups
+// are first named. A method may be in several groups, named by one directive or many
+//
+//	//gointerfacegen:group Reader Writer
+//
+// Methods without a group directive are left out
+func Groups(methods []*ast.FuncDecl) []Group {
+	groups := []Group{}
+	index := make(map[string]int)
+	for _, method := range methods {
+		for _, args := range directives(method, "group") {
+			for _, name := range args {
+				i, ok := index[name]
+				if !ok {
+					i = len(groups)
+					index[name] = i
+					groups = append(groups, Group{Name: name})
+				}
+
+				if n := len(groups[i].Methods); n == 0 || groups[i].Methods[n-1] != method {
+					groups[i].Methods = append(groups[i].Methods, method)
+				}
+			}
+		}
+	}
+
+	return groups
+}
+
+// TypeMarker is a directive in the doc comment of a type describing an interface to generate
+//
+//	//gointerfacegen:interface=UserStore output=store_iface.go
+type TypeMarker struct {
+	TypeName string
+	Options  map[string]string // the key=value pairs of the directive, interface always among them
+}
+
+// TypeMarkers returns the markers in the doc comments of the types declared in the file
+func TypeMarkers(file *ast.File) []TypeMarker {
+	markers := []TypeMarker{}
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+
+			doc := typeDoc(genDecl, typeSpec)
+			if doc == nil {
+				continue
+			}
+
+			for _, c := range doc.List {
+				if !strings.HasPrefix(c.Text, directivePrefix) {
+					continue
+				}
+
+				if options := markerOptions(c.Text); options["interface"] != "" {
+					markers = append(markers, TypeMarker{TypeName: typeSpec.Name.Name, Options: options})
+				}
+			}
+		}
+	}
+
+	return markers
+}
+
+// typeDoc returns the doc comment of the type, which belongs to the declaration of a type declared on its own
+func typeDoc(genDecl *ast.GenDecl, typeSpec *ast.TypeSpec) *ast.CommentGroup {
+	if typeSpec.Doc == nil && len(genDecl.Specs) == 1 {
+		return genDecl.Doc
+	}
+
+	return typeSpec.Doc
+}
+
+// markerOptions returns the key=value pairs of the marker
+func markerOptions(text string) map[string]string {
+	options := make(map[string]string)
+	for _, field := range strings.Fields(strings.TrimPrefix(text, directivePrefix)) {
+		if i := strings.Index(field, "="); i > 0 {
+			options[field[:i]] = field[i+1:]
+		}
+	}
+
+	return options
+}
+
+// sourceMarker starts the marker recording the source of a generated interface
+const sourceMarker = directivePrefix + "source"
+
+// InterfaceMarker is a directive in the doc comment of an interface recording the source it is generated from
+//
+//	//gointerfacegen:source file=store.go type=UserStore
+type InterfaceMarker struct {
+	InterfaceName string
+	Options       map[string]string // the key=value pairs of the directive
+}
+
+// InterfaceMarkers returns the markers in the doc comments of the interfaces declared in the file
+func InterfaceMarkers(file *ast.File) []InterfaceMarker {
+	markers := []InterfaceMarker{}
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			if _, ok := typeSpec.Type.(*ast.InterfaceType); !ok {
+				continue
+			}
+
+			if c := findSourceMarker(typeDoc(genDecl, typeSpec)); c != nil {
+				markers = append(markers, InterfaceMarker{InterfaceName: typeSpec.Name.Name, Options: markerOptions(strings.TrimPrefix(c.Text, sourceMarker))})
+			}
+		}
+	}
+
+	return markers
+}
+
+// findSourceMarker returns the source marker of the doc comment, or nil if it has none
+func findSourceMarker(doc *ast.CommentGroup) *ast.Comment {
+	if doc == nil {
+		return nil
+	}
+
+	for _, c := range doc.List {
+		if fields := strings.Fields(c.Text); len(fields) > 0 && fields[0] == sourceMarker {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Mark records the source of the named interface with a marker with the options, the key=value pairs
+// describing how it is generated, at the end of its doc comment, replacing any marker it already has,
+// and returns the resulting file parsed into fset
+func Mark(fset *token.FileSet, file *ast.File, interfaceName string, options map[string]string) (*ast.File, error) {
+	file, origSrc, err := source(fset, file)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := file.Scope.Lookup(interfaceName)
+	if obj == nil {
+		return nil, newDiagnostic(CodeInterfaceNotFound, interfaceName, "interface %s not found", interfaceName)
+	}
+
+	typeSpec, ok := obj.Decl.(*ast.TypeSpec)
+	if !ok {
+		return nil, diagnosticAt(fset, obj.Pos(), CodeWrongKind, interfaceName, "%s is not a type", interfaceName)
+	}
+
+	genDecl := findTopLevelGenDeclForTypeSpec(typeSpec, file)
+	if genDecl == nil {
+		return nil, diagnosticAt(fset, typeSpec.Pos(), CodeNotTopLevel, interfaceName, "interface %s is not declared at the top level", interfaceName)
+	}
+
+	keys := []string{}
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	marker := sourceMarker
+	for _, key := range keys {
+		marker += " " + key + "=" + options[key]
+	}
+
+	// the marker follows the doc comment, set off by an empty line as directives are
+	var newSrc string
+	doc := typeDoc(genDecl, typeSpec)
+	switch c := findSourceMarker(doc); {
+	case c != nil && c.Text == marker:
+		return file, nil
+	case c != nil:
+		start, end := fset.Position(c.Pos()).Offset, fset.Position(c.End()).Offset
+		newSrc = origSrc[:start] + marker + origSrc[end:]
+	case doc != nil:
+		at := fset.Position(doc.End()).Offset
+		newSrc = origSrc[:at] + "\n//\n" + marker + origSrc[at:]
+	default:
+		pos := genDecl.Pos()
+		if len(genDecl.Specs) > 1 {
+			pos = typeSpec.Pos()
+		}
+
+		at := fset.Position(pos).Offset
+		newSrc = origSrc[:at] + marker + "\n" + origSrc[at:]
+	}
+
+	filename := fset.Position(file.Package).Filename
+	return ParseFile(fset, filename, []byte(newSrc))
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+)
+
+// Drift is a method of an interface that no method of the type it was generated from matches
+type Drift struct {
+	Pos        token.Position
+	Name       string // the method's name
+	Method     string // the interface's method, such as Get(string) error
+	TypeMethod string // the type's method of the same name, such as Get(context.Context, string) error, if any
+}
+
+// Drifted returns the methods of the named interface in the file that methods, the methods of the
+// type, don't match, either by name or by signature. The package qualifiers of the types in the
+// signatures are left out of the comparison, so the interface may be declared in another package.
+// The methods of embedded interfaces aren't compared
+func Drifted(fset *token.FileSet, file *ast.File, interfaceName string, methods []*ast.FuncDecl) ([]Drift, error) {
+	decl, err := FindInterface(file, interfaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	iface, ok := decl.Specs[0].(*ast.TypeSpec).Type.(*ast.InterfaceType)
+	if !ok {
+		return nil, newDiagnostic(CodeWrongKind, interfaceName, "%s is not an interface", interfaceName)
+	}
+
+	byName := make(map[string]*ast.FuncDecl)
+	for _, method := range methods {
+		byName[method.Name.Name] = method
+	}
+
+	drifted := []Drift{}
+	for _, field := range iface.Methods.List {
+		funcType, ok := field.Type.(*ast.FuncType)
+		if len(field.Names) == 0 || !ok {
+			continue
+		}
+
+		name := field.Names[0].Name
+		d := Drift{Pos: fset.Position(field.Pos()), Name: name, Method: shortSignature(name, funcType)}
+		if method, ok := byName[name]; !ok {
+			drifted = append(drifted, d)
+		} else if d.TypeMethod = shortSignature(name, method.Type); unqualified(d.TypeMethod) != unqualified(d.Method) {
+			drifted = append(drifted, d)
+		}
+	}
+
+	return drifted, nil
+}
+
+// shortSignature returns the signature of the method without the names of its parameters and results
+//
+// Get(ctx context.Context, id string) (item Item, err error) becomes Get(context.Context, string) (Item, error)
+func shortSignature(name string, funcType *ast.FuncType) string {
+	list := func(fl *ast.FieldList) []string {
+		typs := []string{}
+		if fl == nil {
+			return typs
+		}
+
+		for _, field := range fl.List {
+			for i := 0; i < len(field.Names) || i == 0; i++ {
+				typs = append(typs, types.ExprString(field.Type))
+			}
+		}
+
+		return typs
+	}
+
+	signature := name + "(" + strings.Join(list(funcType.Params), ", ") + ")"
+	switch results := list(funcType.Results); len(results) {
+	case 0:
+	case 1:
+		signature += " " + results[0]
+	default:
+		signature += " (" + strings.Join(results, ", ") + ")"
+	}
+
+	return signature
+}
+
+// packageQualifier matches the package qualifier of a type, such as the context. of context.Context
+var packageQualifier = regexp.MustCompile(`\b[A-Za-z_]\w*\.([A-Za-z_])`)
+
+// unqualified returns the signature with the package qualifiers of its types removed
+func unqualified(signature string) string {
+	return packageQualifier.ReplaceAllString(signature, "$1")
+}
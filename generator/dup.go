@@ -0,0 +1,250 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+func dupFuncType(old *ast.FuncType) (*ast.FuncType, error) {
+	if old == nil {
+		return nil, nil
+	}
+
+	params, err := dupFieldList(old.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := dupFieldList(old.Results)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.FuncType{Params: params, Results: results}, nil
+}
+
+func dupFieldList(old *ast.FieldList) (*ast.FieldList, error) {
+	if old == nil {
+		return nil, nil
+	}
+
+	new := &ast.FieldList{}
+
+	for _, oldField := range old.List {
+		newField, err := dupField(oldField)
+		if err != nil {
+			return nil, err
+		}
+		new.List = append(new.List, newField)
+	}
+
+	return new, nil
+}
+
+// dupField duplicates an ast.Field ignoring position information.
+// this is written specifically for copying fields that are
+// a part of an ast.InterfaceType's Method list, an
+// ast.StructType's Fields or a ast.FuncType's Params and Results
+func dupField(old *ast.Field) (*ast.Field, error) {
+	if old == nil {
+		return nil, nil
+	}
+
+	typ, err := dupExpr(old.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	new := &ast.Field{Type: typ}
+
+	if old.Tag != nil { // struct field tag
+		new.Tag = &ast.BasicLit{Kind: old.Tag.Kind, Value: old.Tag.Value}
+	}
+
+	for _, oldName := range old.Names {
+		newName := dupIdent(oldName)
+		if newName.Obj != nil {
+			newName.Obj.Decl = new
+		}
+		new.Names = append(new.Names, newName)
+	}
+
+	return new, nil
+}
+
+// dupExprs duplicates each of the expressions, in order
+func dupExprs(old ...ast.Expr) ([]ast.Expr, error) {
+	new := make([]ast.Expr, len(old))
+	for i, expr := range old {
+		var err error
+		if new[i], err = dupExpr(expr); err != nil {
+			return nil, err
+		}
+	}
+
+	return new, nil
+}
+
+// dupExpr duplicates a type expression, including the constant expressions of its
+// array lengths, ignoring position information. An expression
+// it doesn't know how to duplicate is an error naming the expression's node type
+func dupExpr(old ast.Expr) (ast.Expr, error) {
+	if old == nil {
+		return nil, nil
+	}
+
+	switch t := old.(type) {
+	case *ast.Ident:
+		return dupIdent(t), nil
+	case *ast.FuncType:
+		return dupFuncType(t)
+	case *ast.Ellipsis:
+		e, err := dupExprs(t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.Ellipsis{Elt: e[0]}, nil
+	case *ast.StarExpr:
+		e, err := dupExprs(t.X)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.StarExpr{X: e[0]}, nil
+	case *ast.ArrayType:
+		e, err := dupExprs(t.Len, t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.ArrayType{Len: e[0], Elt: e[1]}, nil
+	case *ast.MapType:
+		e, err := dupExprs(t.Key, t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.MapType{Key: e[0], Value: e[1]}, nil
+	case *ast.ChanType:
+		e, err := dupExprs(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.ChanType{Dir: t.Dir, Value: e[0]}, nil
+	case *ast.SelectorExpr:
+		e, err := dupExprs(t.X)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.SelectorExpr{X: e[0], Sel: dupIdent(t.Sel)}, nil
+	case *ast.ParenExpr:
+		e, err := dupExprs(t.X)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.ParenExpr{X: e[0]}, nil
+	case *ast.IndexExpr: // generic instantiation
+		e, err := dupExprs(t.X, t.Index)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.IndexExpr{X: e[0], Index: e[1]}, nil
+	case *ast.IndexListExpr:
+		e, err := dupExprs(append([]ast.Expr{t.X}, t.Indices...)...)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.IndexListExpr{X: e[0], Indices: e[1:]}, nil
+	case *ast.UnaryExpr: // ~T constraint
+		e, err := dupExprs(t.X)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.UnaryExpr{Op: t.Op, X: e[0]}, nil
+	case *ast.BinaryExpr: // A | B constraint
+		e, err := dupExprs(t.X, t.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.BinaryExpr{X: e[0], Op: t.Op, Y: e[1]}, nil
+	case *ast.BasicLit: // array length
+		return &ast.BasicLit{Kind: t.Kind, Value: t.Value}, nil
+	case *ast.CallExpr: // array length, such as unsafe.Sizeof(x) or len("abc")
+		e, err := dupExprs(append([]ast.Expr{t.Fun}, t.Args...)...)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.CallExpr{Fun: e[0], Args: e[1:], Ellipsis: t.Ellipsis}, nil
+	case *ast.CompositeLit: // array length, such as len([2]int{})
+		e, err := dupExprs(append([]ast.Expr{t.Type}, t.Elts...)...)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.CompositeLit{Type: e[0], Elts: e[1:]}, nil
+	case *ast.KeyValueExpr:
+		e, err := dupExprs(t.Key, t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.KeyValueExpr{Key: e[0], Value: e[1]}, nil
+	case *ast.SliceExpr:
+		e, err := dupExprs(t.X, t.Low, t.High, t.Max)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.SliceExpr{X: e[0], Low: e[1], High: e[2], Max: e[3], Slice3: t.Slice3}, nil
+	case *ast.TypeAssertExpr:
+		e, err := dupExprs(t.X, t.Type)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.TypeAssertExpr{X: e[0], Type: e[1]}, nil
+	case *ast.InterfaceType:
+		methods, err := dupFieldList(t.Methods)
+		if err != nil {
+			return nil, err
+		}
+		methods.Opening, methods.Closing = t.Methods.Opening, t.Methods.Opening // keep short literals like interface{} on one line
+		return &ast.InterfaceType{Methods: methods}, nil
+	case *ast.StructType:
+		fields, err := dupFieldList(t.Fields)
+		if err != nil {
+			return nil, err
+		}
+		fields.Opening, fields.Closing = t.Fields.Opening, t.Fields.Opening // keep short literals like struct{} on one line
+		return &ast.StructType{Fields: fields}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported expression %T", old)
+}
+
+// dupIdent duplicates an ast.Ident ignoring position information
+func dupIdent(old *ast.Ident) *ast.Ident {
+	if old == nil {
+		return nil
+	}
+
+	new := ast.NewIdent(old.Name)
+	new.Obj = dupObject(old.Obj)
+
+	return new
+}
+
+func dupObject(old *ast.Object) *ast.Object {
+	if old == nil {
+		return nil
+	}
+
+	return ast.NewObj(old.Kind, old.Name)
+}
+
+// dupCommentGroup duplicates an ast.CommentGroup ignoring position information
+func dupCommentGroup(old *ast.CommentGroup) *ast.CommentGroup {
+	if old == nil {
+		return nil
+	}
+
+	new := &ast.CommentGroup{}
+	for _, c := range old.List {
+		new.List = append(new.List, &ast.Comment{Text: c.Text})
+	}
+
+	return new
+}
@@ -0,0 +1,172 @@
+package generator
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// EmbedInto embeds the interface named interfaceName into the interface named into, both declared
+// in the file, in place of the methods of into that it provides, and returns the resulting file parsed
+// into fset. It follows the interfaces into already embeds, and isn't embedded again when among them
+func EmbedInto(fset *token.FileSet, file *ast.File, interfaceName, into string) (*ast.File, error) {
+	file, origSrc, err := source(fset, file)
+	if err != nil {
+		return nil, err
+	}
+
+	embedded, err := FindInterface(file, interfaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	if tSpec := embedded.Specs[0].(*ast.TypeSpec); tSpec.TypeParams != nil {
+		return nil, diagnosticAt(fset, tSpec.Pos(), CodeGeneric, interfaceName, "generic interface %s cannot be embedded into %s", interfaceName, into)
+	}
+
+	obj := file.Scope.Lookup(into)
+	if obj == nil {
+		return nil, newDiagnostic(CodeInterfaceNotFound, into, "interface %s not found", into)
+	}
+
+	tSpec, ok := obj.Decl.(*ast.TypeSpec)
+	if !ok {
+		return nil, diagnosticAt(fset, obj.Pos(), CodeWrongKind, into, "%s is not a type", into)
+	}
+
+	iface, ok := tSpec.Type.(*ast.InterfaceType)
+	if !ok || into == interfaceName {
+		return nil, diagnosticAt(fset, tSpec.Pos(), CodeWrongKind, into, "%s is not an interface %s can be embedded into", into, interfaceName)
+	}
+
+	if findTopLevelGenDeclForTypeSpec(tSpec, file) == nil {
+		return nil, diagnosticAt(fset, tSpec.Pos(), CodeNotTopLevel, into, "interface %s is not declared at the top level", into)
+	}
+
+	methods := &ast.FieldList{}
+	at := 0
+	for i, field := range iface.Methods.List {
+		if len(field.Names) > 0 {
+			continue
+		}
+
+		if ident, ok := field.Type.(*ast.Ident); ok && ident.Name == interfaceName {
+			at = -1
+			break
+		}
+
+		if at == i {
+			at++
+		}
+	}
+
+	methods.List = append(methods.List, iface.Methods.List...)
+	if at >= 0 {
+		methods.List = append(methods.List[:at], append([]*ast.Field{{Type: ast.NewIdent(interfaceName)}}, methods.List[at:]...)...)
+	}
+	methods = removeEmbeddedMethods(methods, file)
+
+	newSrc, err := newSourceByReplacingInterfaceType(tSpec, methods, nil, origSrc, fset)
+	if err != nil {
+		return nil, err
+	}
+
+	filename := fset.Position(file.Package).Filename
+	return ParseFile(fset, filename, []byte(newSrc))
+}
+
+// removeEmbeddedMethods returns the methods without those provided by the interfaces embedded among them
+func removeEmbeddedMethods(methods *ast.FieldList, file *ast.File) *ast.FieldList {
+	provided := make(map[string]bool)
+	for _, field := range methods.List {
+		if len(field.Names) == 0 {
+			embeddedMethodNames(field.Type, file, provided)
+		}
+	}
+
+	if len(provided) == 0 {
+		return methods
+	}
+
+	new := &ast.FieldList{}
+	for _, field := range methods.List {
+		if len(field.Names) == 0 || !provided[field.Names[0].Name] {
+			new.List = append(new.List, field)
+		}
+	}
+
+	return new
+}
+
+// embeddedMethodNames adds the names of the methods of the embedded interface to names.
+// Interfaces declared in the file are resolved syntactically while those declared in
+// imported packages, like io.Closer, are resolved by importing the package.
+// Interfaces that can't be resolved are skipped
+func embeddedMethodNames(embedded ast.Expr, file *ast.File, names map[string]bool) {
+	switch t := embedded.(type) {
+	case *ast.Ident:
+		typeSpec, err := findTypeSpec(t.Name, file)
+		if err != nil {
+			return
+		}
+
+		iface, ok := typeSpec.Type.(*ast.InterfaceType)
+		if !ok {
+			return
+		}
+
+		for _, field := range iface.Methods.List {
+			if len(field.Names) == 0 {
+				embeddedMethodNames(field.Type, file, names)
+			} else {
+				names[field.Names[0].Name] = true
+			}
+		}
+	case *ast.SelectorExpr:
+		pkgIdent, ok := t.X.(*ast.Ident)
+		if !ok {
+			return
+		}
+
+		iface := importedInterface(pkgIdent.Name, t.Sel.Name, file)
+		if iface == nil {
+			return
+		}
+
+		for i := 0; i < iface.NumMethods(); i++ {
+			names[iface.Method(i).Name()] = true
+		}
+	}
+}
+
+// importedInterface returns the interface pkgName.name imported by the file or nil if it can't be found
+func importedInterface(pkgName, name string, file *ast.File) *types.Interface {
+	imp := importer.ForCompiler(token.NewFileSet(), "source", nil)
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		if spec.Name != nil && spec.Name.Name != pkgName {
+			continue
+		}
+
+		pkg, err := imp.Import(path)
+		if err != nil || pkg.Name() != pkgName && spec.Name == nil {
+			continue
+		}
+
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil
+		}
+
+		iface, _ := obj.Type().Underlying().(*types.Interface)
+		return iface
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+)
+
+// externalPkgName names the package of the file standing in for the package importing the type
+const externalPkgName = "gointerfacegen"
+
+// PackageMethods returns the exported methods of the named type declared in the package with
+// the import path, such as DB of database/sql, which is found from dir the way the go command
+// finds imports: in GOROOT, GOPATH, the vendor directory or the module cache. The types of
+// the methods are qualified by the names of their packages. The files returned alongside
+// import those packages, for MethodImports to look them up in. The methods declared on the type
+// itself have the doc comments of their declarations, for -doc and the directives in them
+func PackageMethods(fset *token.FileSet, path, dir, typeName string, opts ResolveOptions) ([]*ast.FuncDecl, []*ast.File, error) {
+	pkg, err := build.Import(path, dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if pkg.Name == externalPkgName {
+		return nil, nil, fmt.Errorf("package %s can't be named %s", path, externalPkgName)
+	}
+
+	// the packages the methods refer to are named as the package's own files import them
+	files := []*ast.File{}
+	for _, name := range pkg.GoFiles {
+		pkgFile, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		files = append(files, pkgFile)
+	}
+
+	if FindType(files, typeName) == nil {
+		return nil, nil, diagnosticIn(pkg.Dir, CodeTypeNotFound, typeName, "type %s not found in package %s", typeName, path)
+	}
+
+	// the type is resolved as an alias declared by a file of another package in dir,
+	// so its methods are synthesized with every type qualified by its package
+	src := fmt.Sprintf("package %s\n\nimport %s %q\n\ntype %s = %s.%s\n", externalPkgName, pkg.Name, path, typeName, pkg.Name, typeName)
+	file, err := parser.ParseFile(fset, filepath.Join(dir, externalPkgName+".go"), src, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	methods, err := ResolveMethods(fset, []*ast.File{file}, typeName, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s.%s: %w", path, typeName, err)
+	}
+
+	// the synthesized methods are documented as declared
+	docs := make(map[string]*ast.CommentGroup)
+	for _, decl := range ExtractMethods(files, typeName) {
+		docs[decl.Name.Name] = decl.Doc
+	}
+	for _, method := range methods {
+		if method.Doc == nil {
+			method.Doc = docs[method.Name.Name]
+		}
+	}
+
+	files = append([]*ast.File{file}, files...)
+	return methods, files, nil
+}
@@ -0,0 +1,228 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// DefaultIgnoreTag is the directive excluding a method from generated interfaces
+const DefaultIgnoreTag = "gointerfacegen:ignore"
+
+// FilterOptions select the methods of a type an interface declares. The zero value keeps all of them
+type FilterOptions struct {
+	IgnoreTag      string // leave out the methods with this directive, "" for none
+	SkipDeprecated bool   // leave out the deprecated methods
+	Exported       bool   // keep only the exported methods
+	Include        string // keep only the methods whose entire name matches this regular expression
+	Exclude        string // leave out the methods whose entire name matches this regular expression
+	Returning      string // keep only the methods whose last result is of this type, such as error
+	FirstParam     string // keep only the methods whose first parameter is of this type, such as context.Context
+	MaxParams      *int   // keep only the methods taking at most this many parameters
+
+	Log *slog.Logger // the methods each option leaves out are logged to it, nil for no logging
+}
+
+// FilterMethods returns the methods the options keep, in order.
+// An invalid name pattern or type is an error
+func FilterMethods(methods []*ast.FuncDecl, opts FilterOptions) ([]*ast.FuncDecl, error) {
+	type filter struct {
+		option string
+		keep   func(method *ast.FuncDecl) bool
+	}
+	filters := []filter{}
+
+	if opts.IgnoreTag != "" {
+		filters = append(filters, filter{"ignoreTag", Not(HasDirective(opts.IgnoreTag))})
+	}
+
+	if opts.SkipDeprecated {
+		filters = append(filters, filter{"skipDeprecated", Not(IsDeprecated)})
+	}
+
+	if opts.Exported {
+		filters = append(filters, filter{"exported", Exported})
+	}
+
+	if opts.Include != "" {
+		re, err := compileNamePattern(opts.Include)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter{"include", NameMatches(re)})
+	}
+
+	if opts.Exclude != "" {
+		re, err := compileNamePattern(opts.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter{"exclude", Not(NameMatches(re))})
+	}
+
+	if opts.Returning != "" {
+		typeExpr, err := NormalizeType(opts.Returning)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter{"onlyReturning", LastResultIs(typeExpr)})
+	}
+
+	if opts.FirstParam != "" {
+		typeExpr, err := NormalizeType(opts.FirstParam)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter{"firstParam", FirstParamIs(typeExpr)})
+	}
+
+	if opts.MaxParams != nil {
+		filters = append(filters, filter{"maxParams", MaxParams(*opts.MaxParams)})
+	}
+
+	for _, f := range filters {
+		kept := Filter(methods, f.keep)
+		if len(kept) < len(methods) && opts.Log != nil {
+			left := []string{}
+			for _, method := range Filter(methods, Not(f.keep)) {
+				left = append(left, method.Name.Name)
+			}
+			opts.Log.Debug("left out methods", "filter", f.option, "methods", left)
+		}
+		methods = kept
+	}
+
+	return methods, nil
+}
+
+// compileNamePattern compiles a regular expression that must match an entire method name
+func compileNamePattern(pattern string) (*regexp.Regexp, error) {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, fmt.Errorf("invalid method pattern %q: %v", pattern, err)
+	}
+
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// NormalizeType returns the type expression written the way the filters
+// compare the types of methods, such as []byte for [] byte
+func NormalizeType(typeExpr string) (string, error) {
+	expr, err := parser.ParseExpr(typeExpr)
+	if err != nil {
+		return "", fmt.Errorf("invalid type %q: %v", typeExpr, err)
+	}
+
+	return types.ExprString(expr), nil
+}
+
+// Filter returns the methods for which keep returns true
+func Filter(methods []*ast.FuncDecl, keep func(method *ast.FuncDecl) bool) []*ast.FuncDecl {
+	kept := []*ast.FuncDecl{}
+	for _, method := range methods {
+		if keep(method) {
+			kept = append(kept, method)
+		}
+	}
+
+	return kept
+}
+
+// Exported reports whether the method is exported
+func Exported(method *ast.FuncDecl) bool {
+	return method.Name.IsExported()
+}
+
+// NameMatches returns a filter reporting whether the
+// method's name matches the regular expression
+func NameMatches(re *regexp.Regexp) func(method *ast.FuncDecl) bool {
+	return func(method *ast.FuncDecl) bool {
+		return re.MatchString(method.Name.Name)
+	}
+}
+
+// LastResultIs returns a filter reporting whether the method's last result is of the
+// type written as typeExpr, such as error, the way types.ExprString writes it
+func LastResultIs(typeExpr string) func(method *ast.FuncDecl) bool {
+	return func(method *ast.FuncDecl) bool {
+		results := method.Type.Results
+		if results == nil || len(results.List) == 0 {
+			return false
+		}
+
+		return types.ExprString(results.List[len(results.List)-1].Type) == typeExpr
+	}
+}
+
+// FirstParamIs returns a filter reporting whether the method's first parameter is of
+// the type written as typeExpr, such as context.Context, the way types.ExprString writes it
+func FirstParamIs(typeExpr string) func(method *ast.FuncDecl) bool {
+	return func(method *ast.FuncDecl) bool {
+		params := method.Type.Params
+		if params == nil || len(params.List) == 0 {
+			return false
+		}
+
+		return types.ExprString(params.List[0].Type) == typeExpr
+	}
+}
+
+// MaxParams returns a filter reporting whether the method takes at most n parameters,
+// a variadic parameter counting as one
+func MaxParams(n int) func(method *ast.FuncDecl) bool {
+	return func(method *ast.FuncDecl) bool {
+		return method.Type.Params.NumFields() <= n
+	}
+}
+
+// Not returns a filter reporting the opposite of filter
+func Not(filter func(method *ast.FuncDecl) bool) func(method *ast.FuncDecl) bool {
+	return func(method *ast.FuncDecl) bool {
+		return !filter(method)
+	}
+}
+
+// HasDirective returns a filter reporting whether the method's doc comment
+// has the directive, a comment such as //gointerfacegen:ignore without the
+// slashes. Anything following the directive on its line is ignored
+func HasDirective(directive string) func(method *ast.FuncDecl) bool {
+	return func(method *ast.FuncDecl) bool {
+		if method.Doc == nil {
+			return false
+		}
+
+		for _, c := range method.Doc.List {
+			fields := strings.Fields(strings.TrimPrefix(c.Text, "//"))
+			if strings.HasPrefix(c.Text, "//") && len(fields) > 0 && fields[0] == directive {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// IsDeprecated reports whether the method's doc comment has a
+// paragraph starting with Deprecated: marking the method as deprecated
+func IsDeprecated(method *ast.FuncDecl) bool {
+	return deprecationNotice(method.Doc) != ""
+}
+
+// deprecationNotice returns the paragraph of the doc comment starting
+// with Deprecated: or an empty string if there is none
+func deprecationNotice(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+
+	for _, paragraph := range strings.Split(doc.Text(), "\n\n") {
+		if strings.HasPrefix(paragraph, "Deprecated: ") {
+			return strings.TrimSpace(paragraph)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,835 @@
+// Package generator generates Go interfaces from the methods of a type.
+//
+// The functions in this package operate on parsed source. A typical
+// pipeline extracts a type's methods, builds an interface from them
+// and merges the interface into a file:
+//
+//	methods := generator.ExtractMethods(files, "MyType")
+//	iface, err := generator.BuildInterface("MyIface", methods, nil, generator.Options{})
+//	file, err = generator.MergeInto(fset, file, iface, "MyType", generator.MergeOptions{})
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// ParseFile formats and parses the go source src. Formatting the
+// source first allows the rest of the package to make some assumptions
+// about its layout. The formatted source is remembered for the functions
+// merging into the file, which format and parse it anew once edited
+func ParseFile(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var formatted bytes.Buffer
+	if err := format.Node(&formatted, fset, file); err != nil {
+		return nil, err
+	}
+
+	// source that is already formatted, as spliced source is, is only parsed once
+	if !bytes.Equal(formatted.Bytes(), src) {
+		file, err = parser.ParseFile(fset, filename, formatted.Bytes(), parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sources.remember(fset, file, formatted.String())
+	return file, nil
+}
+
+// parseFormatted is ParseFile for formatted source, which it only parses
+func parseFormatted(fset *token.FileSet, filename string, src string) (*ast.File, error) {
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	sources.remember(fset, file, src)
+	return file, nil
+}
+
+// ExtractMethods returns all of the methods declared on the named type in files.
+// An alias of a type declared in files stands for that type, whose methods may
+// be declared on the type itself or on any of its aliases
+func ExtractMethods(files []*ast.File, typeName string) []*ast.FuncDecl {
+	names := typeAndAliases(files, typeName)
+
+	methods := []*ast.FuncDecl{}
+	for _, file := range files {
+		methods = append(methods, gatherTypeMethods(names, file)...)
+	}
+
+	return methods
+}
+
+// typeAndAliases returns the names the type typeName stands for goes by in files: the
+// name of the type itself, following aliases such as type Store = store, and its aliases
+func typeAndAliases(files []*ast.File, typeName string) map[string]bool {
+	aliasOf := make(map[string]string)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if ident, ok := typeSpec.Type.(*ast.Ident); ok && typeSpec.Assign.IsValid() {
+					aliasOf[typeSpec.Name.Name] = ident.Name
+				}
+			}
+		}
+	}
+
+	// an alias of an alias is followed too, up to a cycle, which doesn't compile anyway
+	resolve := func(name string) string {
+		for seen := make(map[string]bool); aliasOf[name] != "" && !seen[name]; {
+			seen[name] = true
+			name = aliasOf[name]
+		}
+
+		return name
+	}
+
+	target := resolve(typeName)
+	names := map[string]bool{target: true}
+	for alias := range aliasOf {
+		if resolve(alias) == target {
+			names[alias] = true
+		}
+	}
+
+	return names
+}
+
+// FindType returns the declaration of the named type in files or nil if it isn't declared in them
+func FindType(files []*ast.File, typeName string) *ast.TypeSpec {
+	for _, file := range files {
+		if typeSpec, err := findTypeSpec(typeName, file); err == nil {
+			return typeSpec
+		}
+	}
+
+	return nil
+}
+
+// Options control how BuildInterface generates an interface from methods
+type Options struct {
+	Doc  string // doc comment of the interface without the comment markers
+	Docs bool   // copy the methods' doc comments onto the interface methods
+
+	// copy the deprecation notices of the methods' doc comments
+	// onto the interface methods when not copying the whole comments
+	DeprecationNotices bool
+
+	ResultNames     bool // keep the names of named results
+	StripParamNames bool // leave parameters unnamed
+
+	Embeds []string // interfaces embedded ahead of the methods, such as io.Reader
+
+	// the terms of the type set of a constraint, such as Celsius, ~float64 or
+	// Set[K], united ahead of the embedded interfaces
+	Terms []string
+
+	// the methods starting a section of the interface, found by Sections,
+	// which is set off by a blank line and the section's comment
+	Sections map[string]*ast.CommentGroup
+}
+
+// BuildInterface builds the declaration of an interface named name from methods.
+// The type parameters of a generic type are carried over to the interface and
+// referenced by the interface methods in place of the receivers' type parameters
+func BuildInterface(name string, methods []*ast.FuncDecl, typeParams *ast.FieldList, opts Options) (*ast.GenDecl, error) {
+	interfaceMethods, err := generateInterfaceMethods(methods, typeParamNames(typeParams), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Embeds) > 0 || len(opts.Terms) > 0 {
+		embedded := []*ast.Field{}
+		if union := unionExpr(opts.Terms); union != nil {
+			embedded = append(embedded, &ast.Field{Type: union})
+		}
+		for _, embed := range opts.Embeds {
+			embedded = append(embedded, &ast.Field{Type: embedExpr(embed)})
+		}
+		interfaceMethods.List = append(embedded, interfaceMethods.List...)
+	}
+	decl, tSpec := newInterface(name, interfaceMethods)
+	if tSpec.TypeParams, err = dupFieldList(typeParams); err != nil {
+		return nil, err
+	}
+
+	if opts.Doc != "" {
+		decl.Doc = &ast.CommentGroup{}
+		for _, line := range strings.Split(opts.Doc, "\n") {
+			decl.Doc.List = append(decl.Doc.List, &ast.Comment{Text: strings.TrimSpace("// " + line)})
+		}
+	}
+
+	return decl, nil
+}
+
+// InterfaceDoc returns the doc comment of an interface generated from the types
+//
+// Store is the interface implemented by MemStore and SQLStore.
+func InterfaceDoc(interfaceName string, typeNames []string) string {
+	return fmt.Sprintf("%s is the interface implemented by %s.", interfaceName, JoinNames(typeNames))
+}
+
+// JoinNames joins names into a list for use in a sentence
+//
+// [A B C] becomes "A, B and C"
+func JoinNames(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// unionExpr returns the union of the type set terms, A | ~B, or nil without any.
+// A term that isn't a valid type set term is left out
+func unionExpr(terms []string) ast.Expr {
+	var union ast.Expr
+	for _, term := range terms {
+		expr, err := parser.ParseExpr(term)
+		if err != nil {
+			continue
+		}
+
+		expr, err = dupExpr(expr)
+		if err != nil {
+			continue
+		}
+
+		if union == nil {
+			union = expr
+		} else {
+			union = &ast.BinaryExpr{X: union, Op: token.OR, Y: expr}
+		}
+	}
+
+	return union
+}
+
+// MergeOptions control how MergeInto updates an existing interface
+type MergeOptions struct {
+	Prune bool  // remove methods of the existing interface that the merged interface doesn't have
+	Order Order // order of the methods of the resulting interface
+	Group bool  // insert a new interface into the type's declaration group, grouping a type declared on its own
+	Docs  bool  // update the doc comments of the existing interface's methods to those of the merged interface
+
+	Log *slog.Logger // where the interface is merged is logged to it, nil for no logging
+}
+
+// Order is the order of the methods of an interface
+type Order int
+
+const (
+	// OrderNone keeps the order of the existing interface, updating its methods
+	// in place, and appends the merged interface's new methods
+	OrderNone Order = iota
+
+	// OrderSource puts embedded interfaces first followed by the merged interface's
+	// methods in the order they are declared in the source and the remaining
+	// methods of the existing interface
+	OrderSource
+
+	// OrderAlpha puts embedded interfaces first followed by all methods sorted by name
+	OrderAlpha
+)
+
+// ParseOrder parses the name of an order: none, source or alpha
+func ParseOrder(name string) (Order, error) {
+	switch name {
+	case "none":
+		return OrderNone, nil
+	case "source":
+		return OrderSource, nil
+	case "alpha":
+		return OrderAlpha, nil
+	}
+
+	return OrderNone, fmt.Errorf("invalid sort %q: must be source, alpha or none", name)
+}
+
+// MergeInto merges the interface declaration built by BuildInterface into the
+// file and returns the resulting file parsed into fset. If the file already declares
+// the interface, it is updated in place. Otherwise, the interface is inserted above
+// the declaration of typeName or appended to the end of the file when typeName
+// is declared elsewhere.
+func MergeInto(fset *token.FileSet, file *ast.File, iface *ast.GenDecl, typeName string, opts MergeOptions) (*ast.File, error) {
+	ifaceSpec := iface.Specs[0].(*ast.TypeSpec)
+	interfaceName := ifaceSpec.Name.Name
+
+	// The source the interface is spliced into, whose offsets match the
+	// positions in fset of the file returned with it
+	file, origSrc, err := source(fset, file)
+	if err != nil {
+		return nil, err
+	}
+
+	var newSrc string
+	if existing := file.Scope.Lookup(interfaceName); existing != nil {
+		typ := existing.Decl
+		tSpec, ok := typ.(*ast.TypeSpec)
+		if !ok {
+			return nil, diagnosticAt(fset, existing.Pos(), CodeNameTaken, interfaceName, "%s is already declared and is not a type", interfaceName)
+		}
+
+		existingIface, ok := tSpec.Type.(*ast.InterfaceType)
+		if !ok {
+			return nil, diagnosticAt(fset, tSpec.Pos(), CodeNameTaken, interfaceName, "%s is already declared and is not an interface", interfaceName)
+		}
+
+		genDecl := findTopLevelGenDeclForTypeSpec(tSpec, file)
+		if genDecl == nil {
+			return nil, diagnosticAt(fset, tSpec.Pos(), CodeNotTopLevel, interfaceName, "interface %s is not declared at the top level", interfaceName)
+		}
+
+		opts.logger().Info("updating the existing interface", "interface", interfaceName, "pos", fset.Position(genDecl.Pos()).String())
+
+		// an existing generic interface keeps its own type parameter names
+		interfaceMethods := ifaceSpec.Type.(*ast.InterfaceType).Methods
+		var typeParams *ast.FieldList
+		if tSpec.TypeParams == nil {
+			typeParams = ifaceSpec.TypeParams
+		} else {
+			renames := make(map[string]string)
+			existingNames := typeParamNames(tSpec.TypeParams)
+			for i, name := range typeParamNames(ifaceSpec.TypeParams) {
+				if i < len(existingNames) && name != existingNames[i] {
+					renames[name] = existingNames[i]
+				}
+			}
+
+			for _, field := range interfaceMethods.List {
+				if funcType, ok := field.Type.(*ast.FuncType); ok {
+					renameTypeParams(funcType, renames)
+				}
+			}
+		}
+
+		// methods provided by embedded interfaces aren't repeated
+		methods := mergeInterfaceMethods(existingIface.Methods, interfaceMethods, opts.Prune, opts.Order, opts.Docs)
+		methods = removeEmbeddedMethods(methods, file)
+		sortMethods(methods, opts.Order)
+		newSrc, err = newSourceByReplacingInterfaceType(tSpec, methods, typeParams, origSrc, fset)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		sortMethods(ifaceSpec.Type.(*ast.InterfaceType).Methods, opts.Order)
+
+		typeSpec, err := findTypeSpec(typeName, file)
+		if err == nil && opts.Group {
+			// or above the type within its group when they share a file and it is asked for
+			opts.logger().Info("inserting the interface into the group of its type", "interface", interfaceName, "type", typeName, "pos", fset.Position(typeSpec.Pos()).String())
+			newSrc, err = newSourceByInsertingInterfaceIntoGroup(iface, typeSpec, file, origSrc, fset)
+			if err != nil {
+				return nil, err
+			}
+
+			// the interface is spliced in unindented, and a new group around the type
+			filename := fset.Position(file.Package).Filename
+			return ParseFile(fset, filename, []byte(newSrc))
+		} else if err == nil {
+			// the interface goes above the type when they share a file
+			opts.logger().Info("inserting the interface above its type", "interface", interfaceName, "type", typeName, "pos", fset.Position(typeSpec.Pos()).String())
+			newSrc, err = newSourceByInsertingInterfaceAboveType(iface, typeName, file, origSrc, fset)
+		} else {
+			// otherwise it goes at the end of the file
+			opts.logger().Info("appending the interface to the file", "interface", interfaceName, "file", fset.Position(file.Package).Filename)
+			newSrc, err = newSourceByAppendingInterface(iface, origSrc, fset)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// a whole declaration spliced in between others leaves the source formatted
+		filename := fset.Position(file.Package).Filename
+		return parseFormatted(fset, filename, newSrc)
+	}
+
+	// parse the new source once so the file's positions and
+	// comments match it for whatever is merged into it next
+	filename := fset.Position(file.Package).Filename
+	return ParseFile(fset, filename, []byte(newSrc))
+}
+
+// logger returns the logger of the options, which discards everything without one
+func (opts MergeOptions) logger() *slog.Logger {
+	if opts.Log == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+
+	return opts.Log
+}
+
+// FindInterface returns the declaration of the named interface in the file.
+// An interface declared in a group is returned as a declaration of its own
+func FindInterface(file *ast.File, interfaceName string) (*ast.GenDecl, error) {
+	ifaceObj := file.Scope.Lookup(interfaceName)
+	if ifaceObj == nil {
+		return nil, newDiagnostic(CodeInterfaceNotFound, interfaceName, "interface %s not found", interfaceName)
+	}
+
+	typ := ifaceObj.Decl
+	tSpec, ok := typ.(*ast.TypeSpec)
+	if !ok {
+		return nil, newDiagnostic(CodeWrongKind, interfaceName, "%s is not a type", interfaceName)
+	}
+
+	decl := findTopLevelGenDeclForTypeSpec(tSpec, file)
+	if decl == nil {
+		return nil, newDiagnostic(CodeNotTopLevel, interfaceName, "interface %s is not declared at the top level", interfaceName)
+	}
+
+	if len(decl.Specs) > 1 {
+		spec := *tSpec
+		spec.Doc = nil
+		decl = &ast.GenDecl{Doc: tSpec.Doc, TokPos: tSpec.Pos(), Tok: token.TYPE, Specs: []ast.Spec{&spec}}
+	}
+
+	return decl, nil
+}
+
+// firstLineOfTypeIncludingComments returns the first line of the type including its comments.
+// for example, given the following type declaration
+//
+// 1: // comment
+// 2: // comment
+// 3: type test string
+//
+// a token.Pos for line 1 would be returned
+func firstLineOfTypeIncludingComments(typeName string, file *ast.File) (token.Pos, error) {
+	typeSpec, err := findTypeSpec(typeName, file)
+	if err != nil {
+		return token.NoPos, err
+	}
+
+	// Find the ast.GenDecl for the type. We do this because
+	// doc comments for a type are associated with the ast.GenDecl for the type
+	genDecl := findTopLevelGenDeclForTypeSpec(typeSpec, file)
+	if genDecl == nil {
+		return token.NoPos, newDiagnostic(CodeNotTopLevel, typeName, "type %s is not declared at the top level", typeName)
+	}
+
+	// The position to insert at is either the line at which type occurs (ast.GenDecl)
+	// or the first line of the comments above the type declaration. If the type is
+	// declared in a group, this is the first line of the group
+	pos := genDecl.Pos()
+	if genDecl.Doc != nil {
+		pos = genDecl.Doc.Pos()
+	}
+
+	return pos, nil
+}
+
+// findTypeSpec returns the ast.TypeSpec declaring typeName in the file
+func findTypeSpec(typeName string, file *ast.File) (*ast.TypeSpec, error) {
+	// Find the object for the type
+	typeObj := file.Scope.Lookup(typeName)
+	if typeObj == nil || typeObj.Pos().IsValid() == false {
+		return nil, newDiagnostic(CodeTypeNotFound, typeName, "type %s not found", typeName)
+	}
+
+	// Make sure it's a type
+	typeSpec, ok := typeObj.Decl.(*ast.TypeSpec)
+	if !ok {
+		return nil, newDiagnostic(CodeWrongKind, typeName, "%s is a %s, not a type", typeName, typeObj.Kind)
+	}
+
+	return typeSpec, nil
+}
+
+// Find the top level ast.GenDecl for the given ast.TypeSpec.
+// The type spec may be one of many in a grouped declaration
+//
+//	type (
+//		first string
+//		second int
+//	)
+func findTopLevelGenDeclForTypeSpec(typeSpec *ast.TypeSpec, file *ast.File) *ast.GenDecl {
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok {
+			for _, spec := range gen.Specs {
+				if spec == typeSpec {
+					return gen
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// gatherTypeMethods returns all of the *ast.FuncDecl for a given type, which goes by any of names
+func gatherTypeMethods(names map[string]bool, file *ast.File) []*ast.FuncDecl {
+	methods := []*ast.FuncDecl{}
+	ast.Inspect(file, func(x ast.Node) bool {
+		f, ok := x.(*ast.FuncDecl)
+		if !ok {
+			return true
+		}
+
+		if f.Recv == nil { //function
+			return false
+		}
+
+		if len(f.Recv.List) != 1 {
+			return false // this should never happen, there should only be one receiver
+		}
+
+		ident := receiverTypeName(f)
+		if ident == nil {
+			return false
+		}
+
+		if names[ident.String()] {
+			methods = append(methods, f)
+		}
+
+		return false
+	})
+
+	return methods
+}
+
+// receiverTypeName returns the name of the method's receiver type
+// with any pointer and type parameters removed, or nil if it has none
+func receiverTypeName(f *ast.FuncDecl) *ast.Ident {
+	typ := receiverType(f)
+	switch t := typ.(type) {
+	case *ast.IndexExpr: // generic receiver, one type parameter
+		typ = t.X
+	case *ast.IndexListExpr: // generic receiver, many type parameters
+		typ = t.X
+	}
+
+	ident, _ := typ.(*ast.Ident)
+	return ident
+}
+
+// receiverTypeParams returns the type parameter names used by a generic method's receiver
+//
+// func (c *Cache[K, V]) Get(key K) V
+//
+// returns [K V]
+func receiverTypeParams(f *ast.FuncDecl) []string {
+	var indices []ast.Expr
+	switch t := receiverType(f).(type) {
+	case *ast.IndexExpr:
+		indices = []ast.Expr{t.Index}
+	case *ast.IndexListExpr:
+		indices = t.Indices
+	}
+
+	names := []string{}
+	for _, index := range indices {
+		ident, ok := index.(*ast.Ident)
+		if !ok {
+			return nil // not a valid receiver
+		}
+
+		names = append(names, ident.Name)
+	}
+
+	return names
+}
+
+// receiverType returns the method's receiver type with any pointer removed
+func receiverType(f *ast.FuncDecl) ast.Expr {
+	if f.Recv == nil || len(f.Recv.List) != 1 {
+		return nil
+	}
+
+	typ := f.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok { // pointer receiver
+		typ = star.X
+	}
+
+	return typ
+}
+
+// typeParamNames returns the names declared by a type parameter list
+func typeParamNames(typeParams *ast.FieldList) []string {
+	if typeParams == nil {
+		return nil
+	}
+
+	names := []string{}
+	for _, field := range typeParams.List {
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+
+	return names
+}
+
+// generateInterfaceMethods generates a ast.FieldList suitable for use of as the Methods of an ast.InterfaceType.
+// The type parameters of a generic receiver are renamed to typeParams, the type parameters of the interface
+func generateInterfaceMethods(funcDecls []*ast.FuncDecl, typeParams []string, opts Options) (*ast.FieldList, error) {
+	fl := &ast.FieldList{}
+
+	for _, decl := range funcDecls {
+		field := &ast.Field{}
+		name := dupIdent(decl.Name)
+		name.Obj = ast.NewObj(ast.Fun, name.Name) // a FuncDecl's name doesn't have an object but a field's name does
+		name.Obj.Decl = field
+		field.Names = append(field.Names, name)
+
+		// the section goes with the method's name wherever the method is merged to
+		if comment, ok := opts.Sections[decl.Name.Name]; ok {
+			name.Obj.Data = section{comment: comment}
+		}
+
+		funcType, err := dupFuncType(decl.Type)
+		if err != nil {
+			return nil, fmt.Errorf("method %s: %v", decl.Name.Name, err)
+		}
+
+		// a method may name the receiver's type parameters
+		// differently than the type declaration does
+		renames := make(map[string]string)
+		for i, recvName := range receiverTypeParams(decl) {
+			if i < len(typeParams) && recvName != "_" && recvName != typeParams[i] {
+				renames[recvName] = typeParams[i]
+			}
+		}
+		renameTypeParams(funcType, renames)
+
+		// erase the names of any named returns
+		// since they don't really make
+		// sense for interfaces unless asked
+		// to keep them for documentation
+		if funcType.Results != nil && !opts.ResultNames {
+			for _, r := range funcType.Results.List {
+				r.Names = nil
+			}
+		}
+
+		if funcType.Params != nil && opts.StripParamNames {
+			if funcType.Params, err = stripNames(funcType.Params); err != nil {
+				return nil, err
+			}
+		}
+
+		field.Type = funcType
+
+		if opts.Docs {
+			field.Doc = dupCommentGroup(withoutDirectives(decl.Doc))
+		} else if notice := deprecationNotice(decl.Doc); notice != "" && opts.DeprecationNotices {
+			field.Doc = &ast.CommentGroup{}
+			for _, line := range strings.Split(notice, "\n") {
+				field.Doc.List = append(field.Doc.List, &ast.Comment{Text: strings.TrimSpace("// " + line)})
+			}
+		}
+
+		fl.List = append(fl.List, field)
+	}
+
+	return fl, nil
+}
+
+// stripNames returns the parameters without their names. A
+// field naming several parameters is split into one field each
+//
+// (a, b string, c int) becomes (string, string, int)
+func stripNames(params *ast.FieldList) (*ast.FieldList, error) {
+	new := &ast.FieldList{}
+	for _, field := range params.List {
+		for i := 0; i < len(field.Names) || i == 0; i++ {
+			typ, err := dupExpr(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			new.List = append(new.List, &ast.Field{Type: typ})
+		}
+	}
+
+	return new, nil
+}
+
+// renameTypeParams renames the type parameters referenced by the
+// parameter and result types of funcType according to renames
+func renameTypeParams(funcType *ast.FuncType, renames map[string]string) {
+	if len(renames) == 0 {
+		return
+	}
+
+	for _, fl := range []*ast.FieldList{funcType.Params, funcType.Results} {
+		if fl == nil {
+			continue
+		}
+
+		for _, field := range fl.List {
+			ast.Inspect(field.Type, func(x ast.Node) bool {
+				if ident, ok := x.(*ast.Ident); ok {
+					if name, ok := renames[ident.Name]; ok {
+						ident.Name = name
+					}
+				}
+
+				return true
+			})
+		}
+	}
+}
+
+// mergeInterfaceMethods merges two FieldLists of interface methods
+// into a new FieldList. If a method with the same name exists
+// in both FieldLists, the right one wins. When pruning, methods only
+// in the left FieldList are dropped. Embedded interfaces are always kept.
+//
+// With OrderNone the left order is kept for a minimal diff: a method in both is
+// updated in place, or left as written when its signature is unchanged, and the
+// methods only in the right FieldList are appended. Otherwise the right methods
+// come first for sortMethods to order. With docs, a method whose signature is
+// unchanged is still updated when the right one's doc comment differs
+func mergeInterfaceMethods(left, right *ast.FieldList, prune bool, order Order, docs bool) *ast.FieldList {
+	rights := make(map[string]*ast.Field)
+	for _, field := range right.List {
+		rights[fieldKey(field)] = field
+	}
+
+	new := &ast.FieldList{}
+	if order != OrderNone {
+		new.List = append(new.List, right.List...)
+		for _, field := range left.List {
+			if rights[fieldKey(field)] == nil && (len(field.Names) == 0 || !prune) {
+				new.List = append(new.List, field)
+			}
+		}
+
+		return new
+	}
+
+	merged := make(map[string]bool)
+	for _, field := range left.List {
+		r := rights[fieldKey(field)]
+		switch {
+		case r == nil:
+			if len(field.Names) == 0 || !prune {
+				new.List = append(new.List, field)
+			}
+		case types.ExprString(r.Type) == types.ExprString(field.Type) && (!docs || r.Doc == nil || r.Doc.Text() == field.Doc.Text()):
+			new.List = append(new.List, field)
+		default:
+			// the method's comments stay with it when the generated method has none
+			if (r.Doc == nil && field.Doc != nil) || (r.Comment == nil && field.Comment != nil) {
+				updated := *r
+				if updated.Doc == nil {
+					updated.Doc = field.Doc
+				}
+				if updated.Comment == nil {
+					updated.Comment = field.Comment
+				}
+				r = &updated
+			}
+			new.List = append(new.List, r)
+		}
+
+		merged[fieldKey(field)] = r != nil
+	}
+
+	for _, field := range right.List {
+		if !merged[fieldKey(field)] {
+			new.List = append(new.List, field)
+		}
+	}
+
+	return new
+}
+
+// fieldKey identifies a method of an interface by its name and an embedded interface by its type
+func fieldKey(field *ast.Field) string {
+	if len(field.Names) == 0 { // embedded interface
+		return types.ExprString(field.Type)
+	}
+
+	return field.Names[0].Name
+}
+
+// sortMethods sorts the methods of an interface in place
+func sortMethods(methods *ast.FieldList, order Order) {
+	// rank groups embedded interfaces, generated methods
+	// and the methods of an existing interface in that order
+	rank := func(field *ast.Field) int {
+		if len(field.Names) == 0 {
+			return 0
+		}
+
+		if !field.Pos().IsValid() {
+			return 1
+		}
+
+		return 2
+	}
+
+	switch order {
+	case OrderSource:
+		sort.SliceStable(methods.List, func(i, j int) bool {
+			return rank(methods.List[i]) < rank(methods.List[j])
+		})
+	case OrderAlpha:
+		sort.SliceStable(methods.List, func(i, j int) bool {
+			left, right := methods.List[i], methods.List[j]
+			if len(left.Names) == 0 || len(right.Names) == 0 {
+				return len(left.Names) < len(right.Names)
+			}
+
+			return left.Names[0].Name < right.Names[0].Name
+		})
+	}
+}
+
+func newInterface(name string, methods *ast.FieldList) (*ast.GenDecl, *ast.TypeSpec) {
+
+	// given:
+	//
+	// type someInterface interface {
+	//     MethodOne()
+	//     MethodTwo()
+	// }
+	//
+
+	// type
+	decl := &ast.GenDecl{Tok: token.TYPE}
+
+	//  someInterface
+	tSpec := &ast.TypeSpec{}
+	tSpec.Name = &ast.Ident{Name: name}
+	tSpec.Name.Obj = ast.NewObj(ast.Typ, name)
+	tSpec.Name.Obj.Decl = tSpec
+
+	decl.Specs = []ast.Spec{tSpec}
+
+	// interface {
+	//     MethodOne()
+	//     MethodTwo()
+	// }
+	iType := &ast.InterfaceType{
+		Methods: methods,
+	}
+
+	tSpec.Type = iType
+
+	return decl, tSpec
+}
@@ -0,0 +1,3598 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// buildInterface is BuildInterface failing the test on an error
+func buildInterface(t testing.TB, name string, methods []*ast.FuncDecl, typeParams *ast.FieldList, opts Options) *ast.GenDecl {
+	t.Helper()
+
+	decl, err := BuildInterface(name, methods, typeParams, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return decl
+}
+
+// generate parses src and returns the formatted interface generated from typeName's methods
+func generate(t *testing.T, src, typeName string) string {
+	t.Helper()
+
+	return generateWithOptions(t, src, typeName, Options{})
+}
+
+// generateWithOptions is generate with the given options
+func generateWithOptions(t *testing.T, src, typeName string, opts Options) string {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*ast.File{file}
+	var typeParams *ast.FieldList
+	if typeSpec := FindType(files, typeName); typeSpec != nil {
+		typeParams = typeSpec.TypeParams
+	}
+
+	decl := buildInterface(t, "Iface", ExtractMethods(files, typeName), typeParams, opts)
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, decl); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestGenerateVariadic(t *testing.T) {
+	src := `package test
+
+type Server struct{}
+
+func (s *Server) Log(format string, args ...interface{}) {}
+func (s Server) Names(names ...string) int { return 0 }
+`
+	want := `type Iface interface {
+	Log(format string, args ...interface{})
+	Names(names ...string) int
+}`
+
+	if got := generate(t, src, "Server"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateCompositeTypes(t *testing.T) {
+	src := `package test
+
+type Store struct{}
+
+func (s *Store) Pointer(f *Store) *Store { return nil }
+func (s *Store) Slices(b []byte, bb [][]*byte) []*Store { return nil }
+func (s *Store) Arrays(a [4]float64, aa [2][3]int) [4]*[]int { return [4]*[]int{} }
+func (s *Store) Maps(m map[string]int, mm map[*Store][]map[int]bool) map[string][]*Store { return nil }
+func (s *Store) Chans(c chan error, r <-chan []int, w chan<- map[string]int) chan<- chan *Store { return nil }
+`
+	want := `type Iface interface {
+	Pointer(f *Store) *Store
+	Slices(b []byte, bb [][]*byte) []*Store
+	Arrays(a [4]float64, aa [2][3]int) [4]*[]int
+	Maps(m map[string]int, mm map[*Store][]map[int]bool) map[string][]*Store
+	Chans(c chan error, r <-chan []int, w chan<- map[string]int) chan<- chan *Store
+}`
+
+	if got := generate(t, src, "Store"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateArrayLengths(t *testing.T) {
+	src := `package test
+
+import "unsafe"
+
+const n = 4
+
+type Store struct{}
+
+func (s *Store) Sizeof() [unsafe.Sizeof(0)]byte { return [8]byte{} }
+func (s *Store) Len(a [len("abcd")]byte, b [len([2]int{1: 0})]int) {}
+func (s *Store) Expr(a [n * 2]byte, b [(n + 1)]byte, c [cap([n]int{})]byte) {}
+`
+	want := `type Iface interface {
+	Sizeof() [unsafe.Sizeof(0)]byte
+	Len(a [len("abcd")]byte, b [len([2]int{1: 0})]int)
+	Expr(a [n * 2]byte, b [(n + 1)]byte, c [cap([n]int{})]byte)
+}`
+
+	if got := generate(t, src, "Store"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestBuildInterfaceUncopyableType(t *testing.T) {
+	src := `package test
+
+import "unsafe"
+
+type Store struct{}
+
+func (s *Store) Get() [unsafe.Sizeof(func() {})]byte { return [8]byte{} }
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = BuildInterface("Iface", ExtractMethods([]*ast.File{file}, "Store"), nil, Options{})
+	if err == nil || !strings.HasPrefix(err.Error(), "method Get: unsupported expression *ast.") {
+		t.Errorf("got %v, want the error naming the method and the expression's type", err)
+	}
+
+	if _, err := dupExpr(&ast.FuncLit{}); err == nil || err.Error() != "unsupported expression *ast.FuncLit" {
+		t.Errorf("got %v, want the error naming *ast.FuncLit", err)
+	}
+}
+
+func TestGenerateQualifiedTypes(t *testing.T) {
+	src := `package test
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type Handler struct{}
+
+func (h *Handler) Serve(ctx context.Context, r *http.Request) (time.Time, error) { return time.Time{}, nil }
+func (h *Handler) Headers() map[string][]http.Header { return nil }
+`
+	want := `type Iface interface {
+	Serve(ctx context.Context, r *http.Request) (time.Time, error)
+	Headers() map[string][]http.Header
+}`
+
+	if got := generate(t, src, "Handler"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateTypeLiterals(t *testing.T) {
+	src := `package test
+
+import "io"
+
+type Shape struct{}
+
+func (s Shape) Move(p struct{ X, Y int }) struct{} { return struct{}{} }
+func (s Shape) Tagged(t struct {
+	Name string ` + "`json:\"name\"`" + `
+	io.Reader
+}) {
+}
+func (s Shape) Closer() interface{ Close() error } { return nil }
+func (s Shape) Nested(f func(interface {
+	io.Reader
+	Size() (n int64)
+}) error) {
+}
+`
+	want := `type Iface interface {
+	Move(p struct{ X, Y int }) struct{}
+	Tagged(t struct {
+		Name string ` + "`json:\"name\"`" + `
+		io.Reader
+	})
+	Closer() interface{ Close() error }
+	Nested(f func(interface {
+		io.Reader
+		Size() (n int64)
+	}) error)
+}`
+
+	if got := generate(t, src, "Shape"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateGenerics(t *testing.T) {
+	src := `package test
+
+type Number interface{ ~int | ~float64 }
+
+type Cache[K comparable, V any] struct{}
+
+func (c *Cache[K, V]) Get(key K) (V, bool) { var v V; return v, false }
+func (c *Cache[A, B]) Put(key A, value B) {}
+func (c Cache[_, V]) Values() []V { return nil }
+func (c *Cache[K, V]) Sub() *Cache[K, []V] { return nil }
+
+type Sum[N Number] struct{}
+
+func (s *Sum[N]) Add(n N) N { return n }
+`
+	want := `type Iface[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	Put(key K, value V)
+	Values() []V
+	Sub() *Cache[K, []V]
+}`
+
+	if got := generate(t, src, "Cache"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	want = `type Iface[N Number] interface {
+	Add(n N) N
+}`
+
+	if got := generate(t, src, "Sum"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFindTopLevelGenDeclForTypeSpecGrouped(t *testing.T) {
+	src := `package test
+
+type (
+	first string
+	second interface{}
+)
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typeSpec, err := findTypeSpec("second", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decl := findTopLevelGenDeclForTypeSpec(typeSpec, file); decl != file.Decls[0] {
+		t.Errorf("expected grouped declaration, got %v", decl)
+	}
+
+	pos, err := firstLineOfTypeIncludingComments("second", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line := fset.Position(pos).Line; line != 3 {
+		t.Errorf("expected line 3, got %d", line)
+	}
+}
+
+func TestMergeIntoExistingInterface(t *testing.T) {
+	src := `package test
+
+//go:generate gointerfacegen Iface
+
+// Iface doc
+type Iface interface {
+	Old()
+}
+
+// T doc
+type T struct{}
+
+func (t T) New() {}
+`
+	want := `package test
+
+//go:generate gointerfacegen Iface
+
+// Iface doc
+type Iface interface {
+	Old()
+	New()
+}
+
+// T doc
+type T struct{}
+
+func (t T) New() {}
+`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface := buildInterface(t, "Iface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{})
+	file, err = MergeInto(fset, file, iface, "T", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeIntoGroup(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			name: "grouped",
+			src: `package test
+
+type (
+	ID string
+
+	// T doc
+	T struct{}
+)
+
+func (t T) Get() ID { return "" }
+`,
+			want: `package test
+
+type (
+	ID string
+
+	// Iface doc
+	Iface interface {
+		Get() ID
+	}
+
+	// T doc
+	T struct{}
+)
+
+func (t T) Get() ID { return "" }
+`,
+		},
+		{
+			name: "on its own",
+			src: `package test
+
+// T doc
+type T struct {
+	id string
+}
+
+func (t T) Get() string { return t.id }
+`,
+			want: `package test
+
+type (
+	// Iface doc
+	Iface interface {
+		Get() string
+	}
+
+	// T doc
+	T struct {
+		id string
+	}
+)
+
+func (t T) Get() string { return t.id }
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := ParseFile(fset, "test.go", []byte(test.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			iface := buildInterface(t, "Iface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{Doc: "Iface doc"})
+			file, err = MergeInto(fset, file, iface, "T", MergeOptions{Group: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := format.Node(&buf, fset, file); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+
+			// merging again updates the interface in its group
+			iface = buildInterface(t, "Iface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{Doc: "Iface doc"})
+			file, err = MergeInto(fset, file, iface, "T", MergeOptions{Group: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf.Reset()
+			if err := format.Node(&buf, fset, file); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != test.want {
+				t.Errorf("merged again, got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeIntoEditedFile(t *testing.T) {
+	src := `package test
+
+type T struct{}
+
+func (t T) Get() {}
+`
+	want := `package test
+
+// Iface is the interface implemented by T.
+type Iface interface {
+	Fetch()
+}
+
+type T struct{}
+
+var _ Iface = (*T)(nil)
+
+func (t T) Fetch() {}
+`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is edited after it was parsed, its text and positions no longer match
+	methods := ExtractMethods([]*ast.File{file}, "T")
+	methods[0].Name.Name = "Fetch"
+
+	iface := buildInterface(t, "Iface", methods, nil, Options{Doc: "Iface is the interface implemented by T."})
+	file, err = MergeInto(fset, file, iface, "T", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err = AddAssertion(fset, file, "Iface", "T")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeIntoFileWithoutADeclaration(t *testing.T) {
+	src := `package test
+
+type T struct{}
+
+func (t T) Get() {}
+
+var unused = 1
+`
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the declaration removed since the file was parsed isn't in the source remembered for it
+	file.Decls = file.Decls[:len(file.Decls)-1]
+	delete(file.Scope.Objects, "unused")
+
+	iface := buildInterface(t, "Iface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{})
+	file, err = MergeInto(fset, file, iface, "T", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); strings.Contains(got, "unused") || !strings.Contains(got, "type Iface interface") {
+		t.Errorf("got:\n%s\nwant the interface merged into the file without unused", got)
+	}
+}
+
+func TestFilterExported(t *testing.T) {
+	src := `package test
+
+type T struct{}
+
+func (t T) Public() {}
+func (t T) private() {}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := Filter(ExtractMethods([]*ast.File{file}, "T"), Exported)
+	if len(methods) != 1 || methods[0].Name.Name != "Public" {
+		t.Errorf("expected only Public, got %v", methods)
+	}
+}
+
+func TestFilterNameMatches(t *testing.T) {
+	src := `package test
+
+type T struct{}
+
+func (t T) GetUser() {}
+func (t T) ListUsers() {}
+func (t T) GetInternal() {}
+func (t T) Delete() {}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := ExtractMethods([]*ast.File{file}, "T")
+	methods = Filter(methods, NameMatches(regexp.MustCompile("^(Get|List)")))
+	methods = Filter(methods, Not(NameMatches(regexp.MustCompile("Internal$"))))
+
+	var names []string
+	for _, method := range methods {
+		names = append(names, method.Name.Name)
+	}
+
+	if strings.Join(names, ",") != "GetUser,ListUsers" {
+		t.Errorf("unexpected methods %v", names)
+	}
+}
+
+func TestFilterSignatures(t *testing.T) {
+	src := `package test
+
+import "context"
+
+type T struct{}
+
+func (t T) Get(ctx context.Context, id string) (string, error) { return "", nil }
+func (t T) Put(ctx context.Context, id, v string, opts ...int) error { return nil }
+func (t T) Len() int { return 0 }
+func (t T) Close() error { return nil }
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := ExtractMethods([]*ast.File{file}, "T")
+	tests := []struct {
+		filter func(*ast.FuncDecl) bool
+		want   string
+	}{
+		{LastResultIs("error"), "Get,Put,Close"},
+		{FirstParamIs("context.Context"), "Get,Put"},
+		{MaxParams(0), "Len,Close"},
+		{MaxParams(2), "Get,Len,Close"},
+		{MaxParams(4), "Get,Put,Len,Close"},
+	}
+
+	for _, test := range tests {
+		var names []string
+		for _, method := range Filter(methods, test.filter) {
+			names = append(names, method.Name.Name)
+		}
+
+		if got := strings.Join(names, ","); got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestFilterMethods(t *testing.T) {
+	src := `package test
+
+import "context"
+
+type T struct{}
+
+func (t T) Get(ctx context.Context, id string) (string, error) { return "", nil }
+
+//gointerfacegen:ignore
+func (t T) Put(ctx context.Context, id, v string) error { return nil }
+
+// Deprecated: use Get.
+func (t T) Fetch(ctx context.Context, id string) ([] byte, error) { return nil, nil }
+func (t T) Len() int { return 0 }
+func (t T) close() error { return nil }
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := ExtractMethods([]*ast.File{file}, "T")
+	one := 1
+	tests := []struct {
+		opts FilterOptions
+		want string
+	}{
+		{FilterOptions{}, "Get,Put,Fetch,Len,close"},
+		{FilterOptions{IgnoreTag: DefaultIgnoreTag}, "Get,Fetch,Len,close"},
+		{FilterOptions{SkipDeprecated: true, Exported: true}, "Get,Put,Len"},
+		{FilterOptions{Include: "Get|Fetch|close"}, "Get,Fetch,close"},
+		{FilterOptions{Exclude: "Get|Fetch|close"}, "Put,Len"},
+		{FilterOptions{Include: "Ge"}, ""},
+		{FilterOptions{Returning: "error", FirstParam: "context . Context"}, "Get,Put,Fetch"},
+		{FilterOptions{Returning: "[]byte"}, ""},
+		{FilterOptions{MaxParams: &one}, "Len,close"},
+	}
+
+	for _, test := range tests {
+		var names []string
+		filtered, err := FilterMethods(methods, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, method := range filtered {
+			names = append(names, method.Name.Name)
+		}
+
+		if got := strings.Join(names, ","); got != test.want {
+			t.Errorf("%+v: got %s, want %s", test.opts, got, test.want)
+		}
+	}
+
+	for _, opts := range []FilterOptions{{Include: "("}, {Exclude: "("}, {Returning: "[]"}, {FirstParam: "func("}} {
+		if _, err := FilterMethods(methods, opts); err == nil {
+			t.Errorf("%+v: expected an error", opts)
+		}
+	}
+}
+
+func TestBuildInterfaceDocs(t *testing.T) {
+	src := `package test
+
+// Iface doc
+type Iface interface {
+	// Old is documented
+	Old() // and commented
+}
+
+type T struct{}
+
+// New is documented
+// over two lines
+func (t T) New() {}
+
+func (t T) Undocumented() {}
+`
+	want := `package test
+
+// Iface doc
+type Iface interface {
+	// Old is documented
+	Old() // and commented
+	// New is documented
+	// over two lines
+	New()
+	Undocumented()
+}
+
+type T struct{}
+
+// New is documented
+// over two lines
+func (t T) New() {}
+
+func (t T) Undocumented() {}
+`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface := buildInterface(t, "Iface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{Docs: true})
+	file, err = MergeInto(fset, file, iface, "T", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestBuildInterfaceDoc(t *testing.T) {
+	iface := buildInterface(t, "Iface", nil, nil, Options{Doc: "Iface is the interface implemented by T."})
+	if iface.Doc == nil || iface.Doc.Text() != "Iface is the interface implemented by T.\n" {
+		t.Errorf("unexpected doc %v", iface.Doc)
+	}
+}
+
+func TestAddAssertion(t *testing.T) {
+	src := `package test
+
+type Iface interface {
+	New()
+}
+
+// T doc
+type T struct{}
+
+func (t T) New() {}
+`
+	want := `package test
+
+type Iface interface {
+	New()
+}
+
+// T doc
+type T struct{}
+
+var _ Iface = (*T)(nil)
+
+func (t T) New() {}
+`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// adding it twice must not duplicate the assertion
+	for i := 0; i < 2; i++ {
+		file, err = AddAssertion(fset, file, "Iface", "T")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeIntoPrune(t *testing.T) {
+	src := `package test
+
+// Iface doc
+type Iface interface {
+	Removed()
+	Kept()
+}
+
+type T struct{}
+
+func (t T) Kept() {}
+`
+	want := `package test
+
+// Iface doc
+type Iface interface {
+	Kept()
+}
+
+type T struct{}
+
+func (t T) Kept() {}
+`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface := buildInterface(t, "Iface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{})
+	file, err = MergeInto(fset, file, iface, "T", MergeOptions{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeIntoEmbedded(t *testing.T) {
+	src := `package test
+
+import "io"
+
+type Namer interface {
+	Name() string
+}
+
+type Iface interface {
+	io.Closer
+	Namer
+	Old()
+}
+
+type T struct{}
+
+func (t T) Close() error  { return nil }
+func (t T) Name() string  { return "" }
+func (t T) Read() []byte { return nil }
+`
+	want := `package test
+
+import "io"
+
+type Namer interface {
+	Name() string
+}
+
+type Iface interface {
+	io.Closer
+	Namer
+	Read() []byte
+}
+
+type T struct{}
+
+func (t T) Close() error { return nil }
+func (t T) Name() string { return "" }
+func (t T) Read() []byte { return nil }
+`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface := buildInterface(t, "Iface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{})
+	file, err = MergeInto(fset, file, iface, "T", MergeOptions{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateResultNames(t *testing.T) {
+	src := `package test
+
+type File struct{}
+
+func (f *File) Read(p []byte) (n int, err error) { return 0, nil }
+`
+	want := `type Iface interface {
+	Read(p []byte) (n int, err error)
+}`
+
+	if got := generateWithOptions(t, src, "File", Options{ResultNames: true}); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateStripParamNames(t *testing.T) {
+	src := `package test
+
+type Store struct{}
+
+func (s *Store) Get(a, b string, n int, opts ...func()) (string, error) { return "", nil }
+func (s *Store) Unnamed(string, int) {}
+`
+	want := `type Iface interface {
+	Get(string, string, int, ...func()) (string, error)
+	Unnamed(string, int)
+}`
+
+	if got := generateWithOptions(t, src, "Store", Options{StripParamNames: true}); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeIntoOrder(t *testing.T) {
+	src := `package test
+
+import "io"
+
+type Iface interface {
+	io.Closer
+	Extra()
+	B()
+}
+
+type T struct{}
+
+func (t T) C() {}
+func (t T) B() {}
+func (t T) A() {}
+`
+	tests := []struct {
+		order Order
+		want  string
+	}{
+		{OrderNone, "io.Closer Extra B C A"},
+		{OrderSource, "io.Closer C B A Extra"},
+		{OrderAlpha, "io.Closer A B C Extra"},
+	}
+
+	for _, test := range tests {
+		// repeated runs must not reorder the interface
+		fset := token.NewFileSet()
+		file, err := ParseFile(fset, "test.go", []byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 2; i++ {
+			iface := buildInterface(t, "Iface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{})
+			file, err = MergeInto(fset, file, iface, "T", MergeOptions{Order: test.order})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		decl, err := FindInterface(file, "Iface")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, field := range decl.Specs[0].(*ast.TypeSpec).Type.(*ast.InterfaceType).Methods.List {
+			got = append(got, types.ExprString(field.Type))
+			if len(field.Names) > 0 {
+				got[len(got)-1] = field.Names[0].Name
+			}
+		}
+
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("order %d: got %v, want %s", test.order, got, test.want)
+		}
+	}
+}
+
+func TestMergeIntoRefreshesDocs(t *testing.T) {
+	src := `package test
+
+// Iface is the interface implemented by T.
+type Iface interface {
+	// Get gets.
+	Get() int // the value
+	// Put puts.
+	Put(v int)
+}
+
+type T struct{}
+
+// Get gets the value by id.
+func (t T) Get() int { return 0 }
+
+// Put puts.
+func (t T) Put(v int) {}
+`
+	tests := []struct {
+		docs bool
+		want string
+	}{
+		{true, `type Iface interface {
+	// Get gets the value by id.
+	Get() int // the value
+	// Put puts.
+	Put(v int)
+}`},
+		{false, `type Iface interface {
+	// Get gets.
+	Get() int // the value
+	// Put puts.
+	Put(v int)
+}`},
+	}
+
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		file, err := ParseFile(fset, "test.go", []byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		iface := buildInterface(t, "Iface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{Docs: test.docs})
+		file, err = MergeInto(fset, file, iface, "T", MergeOptions{Docs: test.docs})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, file); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(buf.String(), test.want) {
+			t.Errorf("docs %v: got\n%s\nwant it to contain\n%s", test.docs, buf.String(), test.want)
+		}
+	}
+}
+
+func TestResolveMethodsAlias(t *testing.T) {
+	src := `package test
+
+type Conn struct{}
+
+type Alias = Conn
+
+func (c *Conn) Close() error { return nil }
+func (a Alias) Name() string { return "" }
+func (o Other) Name() string { return "" }
+
+type Other struct{}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods, err := ResolveMethods(fset, []*ast.File{file}, "Conn", ResolveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, method := range methods {
+		names = append(names, method.Name.Name)
+	}
+
+	if got, want := strings.Join(names, " "), "Close Name"; got != want {
+		t.Errorf("got methods %s, want %s", got, want)
+	}
+}
+
+func TestResolveMethodsPromoted(t *testing.T) {
+	src := `package test
+
+import "sync"
+
+type Logger struct{}
+
+// Log logs the message
+func (l *Logger) Log(msg string) {}
+
+type Named interface {
+	Name() string
+}
+
+type Server struct {
+	*Logger
+	Named
+	sync.Mutex
+}
+
+func (s *Server) Serve() error { return nil }
+`
+	want := `type Iface interface {
+	Serve() error
+	Lock()
+	// Log logs the message
+	Log(msg string)
+	Name() string
+	TryLock() bool
+	Unlock()
+}`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods, err := ResolveMethods(fset, []*ast.File{file}, "Server", ResolveOptions{Promoted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err = MergeInto(fset, file, buildInterface(t, "Iface", methods, nil, Options{Docs: true}), "Server", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decl, err := FindInterface(file, "Iface")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, decl); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestChecker(t *testing.T) {
+	src := `package test
+
+import "sync"
+
+type Server struct {
+	sync.Mutex
+}
+
+func (s *Server) Serve() error { return nil }
+`
+
+	fset := token.NewFileSet()
+	checker := NewChecker(fset)
+	names := func(file *ast.File) []string {
+		t.Helper()
+
+		methods, err := ResolveMethods(fset, []*ast.File{file}, "Server", ResolveOptions{Promoted: true, Checker: checker})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := []string{}
+		for _, method := range methods {
+			names = append(names, method.Name.Name)
+		}
+
+		return names
+	}
+
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Serve", "Lock", "TryLock", "Unlock"}
+	if got := names(file); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	pkg := checker.checked["test.go"].pkg
+	if got := names(file); !reflect.DeepEqual(got, want) || checker.checked["test.go"].pkg != pkg {
+		t.Errorf("got %v checked again, want %v checked once", got, want)
+	}
+
+	// the file parsed anew is checked again
+	file, err = ParseFile(fset, "test.go", []byte(strings.Replace(src, "Serve()", "Run()", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want = []string{"Run", "Lock", "TryLock", "Unlock"}
+	if got := names(file); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestResolveMethodsValue(t *testing.T) {
+	src := `package test
+
+type Inner struct{}
+
+func (i Inner) Get() int { return 0 }
+func (i *Inner) Set(v int) {}
+
+type Ptr struct{}
+
+func (p *Ptr) Reset() {}
+
+type T struct {
+	Inner
+	*Ptr
+}
+
+func (t T) Value() {}
+func (t *T) Pointer() {}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts ResolveOptions
+		want string
+	}{
+		{ResolveOptions{}, "Value Pointer"},
+		{ResolveOptions{Value: true}, "Value"},
+		{ResolveOptions{Promoted: true}, "Value Pointer Get Reset Set"},
+		{ResolveOptions{Promoted: true, Value: true}, "Value Get Reset"},
+	}
+
+	for _, test := range tests {
+		methods, err := ResolveMethods(fset, []*ast.File{file}, "T", test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := []string{}
+		for _, method := range methods {
+			names = append(names, method.Name.Name)
+		}
+
+		if got := strings.Join(names, " "); got != test.want {
+			t.Errorf("%+v: got methods %s, want %s", test.opts, got, test.want)
+		}
+	}
+}
+
+func TestUnion(t *testing.T) {
+	src := `package test
+
+type UserStore struct{}
+
+func (s *UserStore) User(id string) (string, error) { return "", nil }
+func (s *UserStore) Close() error                  { return nil }
+
+type OrderStore struct{}
+
+func (s *OrderStore) Order(id string) (int, error) { return 0, nil }
+func (s *OrderStore) Close() (err error)           { return nil }
+
+type BadStore struct{}
+
+func (s *BadStore) Close() {}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*ast.File{file}
+	methods, err := Union(ExtractMethods(files, "UserStore"), ExtractMethods(files, "OrderStore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, method := range methods {
+		names = append(names, method.Name.Name)
+	}
+
+	if got, want := strings.Join(names, " "), "User Close Order"; got != want {
+		t.Errorf("got methods %s, want %s", got, want)
+	}
+
+	_, err = Union(ExtractMethods(files, "UserStore"), ExtractMethods(files, "BadStore"))
+	if err == nil || !strings.Contains(err.Error(), "conflicting signatures for method Close") {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	src := `package test
+
+type PostgresStore struct{}
+
+func (s *PostgresStore) Get(key string) ([]byte, error) { return nil, nil }
+func (s *PostgresStore) Put(key string, value []byte)   {}
+func (s *PostgresStore) Vacuum()                        {}
+
+type MemoryStore struct{}
+
+func (s *MemoryStore) Put(k string, v []byte)        {}
+func (s *MemoryStore) Get(key string) []byte         { return nil }
+func (s *MemoryStore) Reset()                        {}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*ast.File{file}
+	methods := Intersect(ExtractMethods(files, "PostgresStore"), ExtractMethods(files, "MemoryStore"))
+
+	names := []string{}
+	for _, method := range methods {
+		names = append(names, method.Name.Name)
+	}
+
+	if got, want := strings.Join(names, " "), "Put"; got != want {
+		t.Errorf("got methods %s, want %s", got, want)
+	}
+}
+
+// resolveInterface parses src and resolves the named interface declared in it
+func resolveInterface(t *testing.T, src, interfaceName string, opts ImplOptions) *Impl {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	impl, err := ResolveInterface(fset, []*ast.File{file}, interfaceName, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return impl
+}
+
+func TestMock(t *testing.T) {
+	src := `package test
+
+import "io"
+
+type Store interface {
+	io.Closer
+	Get(key string, m int) ([]byte, error)
+	Tag(string, ...string)
+}
+`
+	want := `package test
+
+// MockStore is a mock implementation of Store. Each method
+// calls the function of the same name, which must be set
+type MockStore struct {
+	CloseFunc func() error
+	GetFunc   func(key string, p1 int) ([]byte, error)
+	TagFunc   func(p0 string, p1 ...string)
+}
+
+var _ Store = (*MockStore)(nil)
+
+// Close calls CloseFunc
+func (m *MockStore) Close() error {
+	if m.CloseFunc == nil {
+		panic("MockStore.Close: CloseFunc is not set")
+	}
+	return m.CloseFunc()
+}
+
+// Get calls GetFunc
+func (m *MockStore) Get(key string, p1 int) ([]byte, error) {
+	if m.GetFunc == nil {
+		panic("MockStore.Get: GetFunc is not set")
+	}
+	return m.GetFunc(key, p1)
+}
+
+// Tag calls TagFunc
+func (m *MockStore) Tag(p0 string, p1 ...string) {
+	if m.TagFunc == nil {
+		panic("MockStore.Tag: TagFunc is not set")
+	}
+	m.TagFunc(p0, p1...)
+}
+`
+
+	impl := resolveInterface(t, src, "Store", ImplOptions{})
+	got, err := impl.Source("", impl.Mock("MockStore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestResolveInterfaceOtherPackage(t *testing.T) {
+	src := `package store
+
+import "context"
+
+type Option func()
+
+type Store interface {
+	Get(ctx context.Context, opts ...Option) error
+}
+`
+	want := `package mocks
+
+import (
+	"context"
+	"example.com/store"
+)
+
+var _ store.Store = (*MockStore)(nil)
+`
+
+	impl := resolveInterface(t, src, "Store", ImplOptions{Package: "mocks", ImportPath: "example.com/store"})
+	got, err := impl.Source("", "var _ "+impl.Interface+" = (*MockStore)(nil)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if sig := impl.Methods[0].signature(); sig != "(ctx context.Context, opts ...store.Option) error" {
+		t.Errorf("unexpected signature %s", sig)
+	}
+}
+
+func TestStub(t *testing.T) {
+	src := `package test
+
+type Store interface {
+	Get(key string) ([]byte, error)
+	Put(p string, v []byte)
+}
+`
+	want := `package test
+
+// PostgresStore implements Store
+type PostgresStore struct {
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+func (p *PostgresStore) Get(key string) ([]byte, error) {
+	panic("not implemented")
+}
+
+func (m *PostgresStore) Put(p string, v []byte) {
+	panic("not implemented")
+}
+`
+
+	impl := resolveInterface(t, src, "Store", ImplOptions{})
+	got, err := impl.Source("", impl.Stub("PostgresStore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNoop(t *testing.T) {
+	src := `package test
+
+import "time"
+
+type Point struct{ X, Y int }
+
+type Metrics interface {
+	Count(name string, n int)
+	Enabled() bool
+	Name() string
+	Elapsed() time.Duration
+	Origin() (Point, [2]int, error)
+	Tags() map[string]string
+}
+`
+	want := `package test
+
+import (
+	"time"
+)
+
+// NoopMetrics is a Metrics that does nothing. Its methods return zero values
+type NoopMetrics struct{}
+
+var _ Metrics = NoopMetrics{}
+
+func (NoopMetrics) Count(name string, n int) {
+}
+
+func (NoopMetrics) Elapsed() time.Duration {
+	return 0
+}
+
+func (NoopMetrics) Enabled() bool {
+	return false
+}
+
+func (NoopMetrics) Name() string {
+	return ""
+}
+
+func (NoopMetrics) Origin() (Point, [2]int, error) {
+	return Point{}, [2]int{}, nil
+}
+
+func (NoopMetrics) Tags() map[string]string {
+	return nil
+}
+`
+
+	impl := resolveInterface(t, src, "Metrics", ImplOptions{})
+	got, err := impl.Source("", impl.Noop("NoopMetrics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSpy(t *testing.T) {
+	src := `package test
+
+type Store interface {
+	Get(key string) ([]byte, error)
+	Tag(string, ...string)
+}
+`
+	want := `package test
+
+import (
+	"sync"
+)
+
+// SpyStore is a Store that records its calls. Calls are passed
+// on to Next when it is set and return zero values otherwise
+type SpyStore struct {
+	Next Store
+
+	mu       sync.Mutex
+	getCalls []SpyStoreGetCall
+	tagCalls []SpyStoreTagCall
+}
+
+var _ Store = (*SpyStore)(nil)
+
+// SpyStoreGetCall holds the arguments of a call to SpyStore.Get
+type SpyStoreGetCall struct {
+	Key string
+}
+
+func (m *SpyStore) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	m.getCalls = append(m.getCalls, SpyStoreGetCall{Key: key})
+	m.mu.Unlock()
+
+	if m.Next == nil {
+		return nil, nil
+	}
+
+	return m.Next.Get(key)
+}
+
+// GetCalls returns the calls to Get so far
+func (m *SpyStore) GetCalls() []SpyStoreGetCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]SpyStoreGetCall(nil), m.getCalls...)
+}
+
+// SpyStoreTagCall holds the arguments of a call to SpyStore.Tag
+type SpyStoreTagCall struct {
+	P0 string
+	P1 []string
+}
+
+func (m *SpyStore) Tag(p0 string, p1 ...string) {
+	m.mu.Lock()
+	m.tagCalls = append(m.tagCalls, SpyStoreTagCall{P0: p0, P1: p1})
+	m.mu.Unlock()
+
+	if m.Next == nil {
+		return
+	}
+
+	m.Next.Tag(p0, p1...)
+}
+
+// TagCalls returns the calls to Tag so far
+func (m *SpyStore) TagCalls() []SpyStoreTagCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]SpyStoreTagCall(nil), m.tagCalls...)
+}
+`
+
+	impl := resolveInterface(t, src, "Store", ImplOptions{})
+	got, err := impl.Source("", impl.Spy("SpyStore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDecorator(t *testing.T) {
+	src := `package store
+
+type Store interface {
+	Get(key string) ([]byte, error)
+	Tag(l string, tags ...string)
+}
+`
+	want := `package cache
+
+import (
+	"example.com/store"
+)
+
+// LoggingStore wraps a store.Store, passing every call on to it
+type LoggingStore struct {
+	store.Store
+}
+
+var _ store.Store = (*LoggingStore)(nil)
+
+func (l *LoggingStore) Get(key string) ([]byte, error) {
+	return l.Store.Get(key)
+}
+
+func (m *LoggingStore) Tag(l string, tags ...string) {
+	m.Store.Tag(l, tags...)
+}
+`
+
+	impl := resolveInterface(t, src, "Store", ImplOptions{Package: "cache", ImportPath: "example.com/store"})
+	got, err := impl.Source("", impl.Decorator("LoggingStore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUsedMethods(t *testing.T) {
+	src := `package test
+
+type Client struct{}
+
+func (c *Client) Get(url string) error  { return nil }
+func (c *Client) Post(url string) error { return nil }
+func (c *Client) Close()                {}
+
+func Fetch(c *Client) error {
+	defer c.Close()
+	return c.Get("https://example.com")
+}
+
+func Upload(c Client) error {
+	return (*Client).Post(&c, "https://example.com")
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		funcName string
+		want     string
+	}{
+		{"", "Close Get Post"},
+		{"Fetch", "Close Get"},
+	}
+
+	for _, test := range tests {
+		used, err := UsedMethods(fset, []*ast.File{file}, "test", "Client", test.funcName)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := []string{}
+		for name := range used {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		if got := strings.Join(names, " "); got != test.want {
+			t.Errorf("%q: got methods %s, want %s", test.funcName, got, test.want)
+		}
+	}
+}
+
+func TestImplementers(t *testing.T) {
+	src := `package test
+
+type Store interface {
+	Get(key string) ([]byte, error)
+	Close() error
+}
+
+type Memory struct{}
+
+func (m Memory) Get(key string) ([]byte, error) { return nil, nil }
+func (m Memory) Close() error                   { return nil }
+
+type Postgres struct{}
+
+func (p *Postgres) Get(key string) ([]byte, error) { return nil, nil }
+func (p *Postgres) Close() error                   { return nil }
+
+type File struct{}
+
+func (f *File) Get(key string) []byte { return nil }
+func (f *File) Close() error          { return nil }
+
+type Unrelated struct{}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	implementers, err := Implementers(fset, []Package{{Path: "example.com/test", Files: []*ast.File{file}}}, "example.com/test", "Store", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Implementer{
+		{Type: "example.com/test.File", Missing: []string{"wrong signature for Get: have (key string) []byte, want (key string) ([]byte, error)"}},
+		{Type: "example.com/test.Memory"},
+		{Type: "*example.com/test.Postgres"},
+	}
+
+	if !reflect.DeepEqual(implementers, want) {
+		t.Errorf("got %+v, want %+v", implementers, want)
+	}
+}
+
+func TestEmbedStd(t *testing.T) {
+	src := `package test
+
+type File struct{}
+
+func (f *File) Read(p []byte) (n int, err error) { return 0, nil }
+func (f *File) Close() error                     { return nil }
+func (f *File) String() string                   { return "" }
+func (f *File) Name() string                     { return "" }
+`
+	want := `package test
+
+import (
+	"fmt"
+	"io"
+)
+
+// Iface doc
+type Iface interface {
+	io.ReadCloser
+	fmt.Stringer
+	Name() string
+}
+
+type File struct{}
+
+func (f *File) Read(p []byte) (n int, err error) { return 0, nil }
+func (f *File) Close() error                     { return nil }
+func (f *File) String() string                   { return "" }
+func (f *File) Name() string                     { return "" }
+`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	embeds, methods := EmbedStd(ExtractMethods([]*ast.File{file}, "File"))
+	names := []string{}
+	for _, embed := range embeds {
+		names = append(names, embed.Name)
+		file, err = AddImport(fset, file, embed.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	iface := buildInterface(t, "Iface", methods, nil, Options{Doc: "Iface doc", Embeds: names})
+	file, err = MergeInto(fset, file, iface, "File", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	src := `package test
+
+type User struct{}
+
+// Name returns the name
+//
+//gointerfacegen:group Reader
+func (u *User) Name() string { return "" }
+
+//gointerfacegen:group Writer
+func (u *User) SetName(name string) {}
+
+//gointerfacegen:group Reader Writer
+func (u *User) ID() int { return 0 }
+
+func (u *User) internal() {}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := Groups(ExtractMethods([]*ast.File{file}, "User"))
+
+	got := []string{}
+	for _, group := range groups {
+		names := []string{}
+		for _, method := range group.Methods {
+			names = append(names, method.Name.Name)
+		}
+		got = append(got, group.Name+": "+strings.Join(names, " "))
+	}
+
+	if want := []string{"Reader: Name ID", "Writer: SetName ID"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got groups %q, want %q", got, want)
+	}
+
+	// the directives aren't copied onto the interface
+	want := `type Iface interface {
+	// Name returns the name
+	Name() string
+	ID() int
+}`
+
+	file, err = ParseFile(fset, "test.go", []byte("package test\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decl := buildInterface(t, "Iface", groups[0].Methods, nil, Options{Docs: true})
+	file, err = MergeInto(fset, file, decl, "User", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface, err := FindInterface(file, "Iface")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, iface); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFilterHasDirective(t *testing.T) {
+	src := `package test
+
+type T struct{}
+
+// Close closes
+//
+//gointerfacegen:ignore only used by tests
+func (t T) Close() {}
+
+// Open opens, see gointerfacegen:ignore
+func (t T) Open() {}
+
+//custom:skip
+func (t T) Reset() {}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := ExtractMethods([]*ast.File{file}, "T")
+
+	tests := []struct {
+		directive string
+		want      string
+	}{
+		{"gointerfacegen:ignore", "Open Reset"},
+		{"custom:skip", "Close Open"},
+	}
+
+	for _, test := range tests {
+		names := []string{}
+		for _, method := range Filter(methods, Not(HasDirective(test.directive))) {
+			names = append(names, method.Name.Name)
+		}
+
+		if got := strings.Join(names, " "); got != test.want {
+			t.Errorf("%s: got methods %s, want %s", test.directive, got, test.want)
+		}
+	}
+}
+
+func TestTypeMarkers(t *testing.T) {
+	src := `package test
+
+// User is a user
+//
+//gointerfacegen:interface=UserStore output=store_iface.go
+//gointerfacegen:interface=UserReader include=Get.*
+type User struct{}
+
+type (
+	//gointerfacegen:interface=OrderStore
+	Order struct{}
+
+	//gointerfacegen:ignore
+	Item struct{}
+)
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []TypeMarker{
+		{TypeName: "User", Options: map[string]string{"interface": "UserStore", "output": "store_iface.go"}},
+		{TypeName: "User", Options: map[string]string{"interface": "UserReader", "include": "Get.*"}},
+		{TypeName: "Order", Options: map[string]string{"interface": "OrderStore"}},
+	}
+
+	if got := TypeMarkers(file); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestMark(t *testing.T) {
+	src := `package test
+
+// Store is a store
+type Store interface {
+	Get()
+}
+
+type (
+	Reader interface{}
+
+	// Writer writes
+	//
+	//gointerfacegen:source file=old.go type=Old
+	Writer interface{}
+)
+`
+	want := `package test
+
+// Store is a store
+//
+//gointerfacegen:source file=store.go type=UserStore
+type Store interface {
+	Get()
+}
+
+type (
+	//gointerfacegen:source type=Reader
+	Reader interface{}
+
+	// Writer writes
+	//
+	//gointerfacegen:source exported=true file=w.go type=W
+	Writer interface{}
+)
+`
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// marking again leaves the markers as they are
+	marks := []struct {
+		name    string
+		options map[string]string
+	}{
+		{"Store", map[string]string{"type": "UserStore", "file": "store.go"}},
+		{"Reader", map[string]string{"type": "Reader"}},
+		{"Writer", map[string]string{"type": "W", "file": "w.go", "exported": "true"}},
+		{"Store", map[string]string{"type": "UserStore", "file": "store.go"}},
+	}
+	for _, mark := range marks {
+		file, err = Mark(fset, file, mark.name, mark.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	wantMarkers := []InterfaceMarker{
+		{InterfaceName: "Store", Options: marks[0].options},
+		{InterfaceName: "Reader", Options: marks[1].options},
+		{InterfaceName: "Writer", Options: marks[2].options},
+	}
+
+	if got := InterfaceMarkers(file); !reflect.DeepEqual(got, wantMarkers) {
+		t.Errorf("got %+v, want %+v", got, wantMarkers)
+	}
+}
+
+func TestQualify(t *testing.T) {
+	src := `package postgres
+
+type Store[T any] struct{}
+
+func (s *Store[V]) Get(ctx context.Context, c Config, opts ...Option) (*Result[V], map[Key][]error)
+func (s *Store[V]) Each(fn func(key Key, value V) error, done chan<- struct{ N Count })
+func (s *Store[V]) Buffer() [Size]byte
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods, unqualifiable, err := Qualify(ExtractMethods([]*ast.File{file}, "Store"), "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unqualifiable) > 0 {
+		t.Fatalf("got unqualifiable methods %+v", unqualifiable)
+	}
+
+	got := []string{}
+	for _, method := range methods {
+		got = append(got, method.Name.Name+types.ExprString(method.Type)[len("func"):])
+	}
+
+	want := []string{
+		"Get(ctx context.Context, c postgres.Config, opts ...postgres.Option) (*postgres.Result[V], map[postgres.Key][]error)",
+		"Each(fn func(key postgres.Key, value V) error, done chan<- struct{N postgres.Count})",
+		"Buffer() [postgres.Size]byte",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// the originals are left as they were
+	if got := types.ExprString(file.Decls[1].(*ast.FuncDecl).Type.Params.List[1].Type); got != "Config" {
+		t.Errorf("original parameter changed to %s", got)
+	}
+}
+
+func TestQualifyUnexported(t *testing.T) {
+	src := `package postgres
+
+type Store struct{}
+
+func (s *Store) Get() *config
+func (s *Store) Len() C.int
+func (s *Store) reset()
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := ExtractMethods([]*ast.File{file}, "Store")
+	qualified, unqualifiable, err := Qualify(methods, "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(qualified) != 0 {
+		t.Errorf("got %d qualified methods, want none", len(qualified))
+	}
+
+	want := []Unqualifiable{{Method: methods[0], Ident: "config"}, {Method: methods[1], Ident: "C.int"}, {Method: methods[2], Ident: "reset"}}
+	if !reflect.DeepEqual(unqualifiable, want) {
+		t.Errorf("got %+v, want %+v", unqualifiable, want)
+	}
+}
+
+func TestMethodImports(t *testing.T) {
+	src := `package test
+
+import (
+	"context"
+	"io"
+	yaml "gopkg.in/yaml.v3"
+	"github.com/jackc/pgx/v5"
+)
+
+type Store struct{}
+
+func (s *Store) Get(ctx context.Context, conn *pgx.Conn) (*yaml.Node, error)
+func (s *Store) Close() error
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "store.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*ast.File{file}
+	got := MethodImports(fset, files, ExtractMethods(files, "Store"))
+	want := []Import{
+		{Path: "context"},
+		{Path: "github.com/jackc/pgx/v5"},
+		{Name: "yaml", Path: "gopkg.in/yaml.v3"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	out, err := ParseFile(fset, "iface.go", []byte("package other\n\nimport \"context\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, imp := range got {
+		out, err = AddNamedImport(fset, out, imp.Name, imp.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, out); err != nil {
+		t.Fatal(err)
+	}
+
+	wantSrc := `package other
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	yaml "gopkg.in/yaml.v3"
+)
+`
+	if buf.String() != wantSrc {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), wantSrc)
+	}
+}
+
+func TestMethodImportsSamePathTwice(t *testing.T) {
+	src := `package test
+
+import (
+	"context"
+	ctxpkg "context"
+)
+
+type Store struct{}
+
+func (s *Store) Get(ctx context.Context) error
+func (s *Store) Put(ctx ctxpkg.Context) error
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "store.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*ast.File{file}
+	imports := MethodImports(fset, files, ExtractMethods(files, "Store"))
+
+	// the file already importing context as context only gets ctxpkg, and only once
+	out, err := ParseFile(fset, "iface.go", []byte("package test\n\nimport \"context\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, imp := range append(imports, imports...) {
+		out, err = AddNamedImport(fset, out, imp.Name, imp.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `package test
+
+import (
+	"context"
+	ctxpkg "context"
+)
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestPreserveFormatting(t *testing.T) {
+	orig := `package test
+import "fmt"
+
+
+
+// Store stores
+type   Store struct {x int}
+func (s *Store)   Get(  key string) { fmt.Println( key ) } // trailing
+
+
+var  x = 1
+`
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "", []byte(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface := buildInterface(t, "Getter", ExtractMethods([]*ast.File{file}, "Store"), nil, Options{})
+	file, err = MergeInto(fset, file, iface, "Store", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := PreserveFormatting([]byte(orig), buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `package test
+import "fmt"
+
+type Getter interface {
+	Get(key string)
+}
+
+// Store stores
+type   Store struct {x int}
+func (s *Store)   Get(  key string) { fmt.Println( key ) } // trailing
+
+
+var  x = 1
+`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPreserveFormattingStyle(t *testing.T) {
+	orig := "\ufeffpackage test\r\n\r\n// Store stores\r\ntype Store struct{}\r\n\r\nfunc (s *Store) Get(key string) {}\r\n"
+	want := "\ufeffpackage test\r\n\r\ntype Getter interface {\r\n\tGet(key string)\r\n}\r\n\r\n// Store stores\r\ntype Store struct{}\r\n\r\nfunc (s *Store) Get(key string) {}\r\n"
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "", []byte(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface := buildInterface(t, "Getter", ExtractMethods([]*ast.File{file}, "Store"), nil, Options{})
+	file, err = MergeInto(fset, file, iface, "Store", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := PreserveFormatting([]byte(orig), buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+
+	// an unchanged file is left as it is
+	got, err = PreserveFormatting([]byte(orig), []byte("package test\n\n// Store stores\ntype Store struct{}\n\nfunc (s *Store) Get(key string) {}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != orig {
+		t.Errorf("got\n%q\nwant\n%q", got, orig)
+	}
+}
+
+func TestResolveMethodsCgo(t *testing.T) {
+	src := `package test
+
+/*
+static int add(int a, int b) { return a + b; }
+*/
+import "C"
+
+type Inner struct{}
+
+func (i *Inner) Add(a, b C.int) C.int { return C.add(a, b) }
+
+type Outer struct {
+	*Inner
+}
+
+func (o *Outer) Name() string { return "" }
+`
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "cgo.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods, err := ResolveMethods(fset, []*ast.File{file}, "Outer", ResolveOptions{Promoted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, method := range methods {
+		got = append(got, method.Name.Name+types.ExprString(method.Type)[len("func"):])
+	}
+
+	if want := []string{"Name() string", "Add(a, b C.int) C.int"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExtractMethodsAlias(t *testing.T) {
+	src := `package test
+
+type store struct{}
+
+type Store = store
+
+type Alias = Store
+
+func (s *store) Get() string { return "" }
+func (a Alias) Put()          {}
+func (o *other) Get() string  { return "" }
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, typeName := range []string{"store", "Store", "Alias"} {
+		names := []string{}
+		for _, method := range ExtractMethods([]*ast.File{file}, typeName) {
+			names = append(names, method.Name.Name)
+		}
+
+		if got, want := strings.Join(names, " "), "Get Put"; got != want {
+			t.Errorf("%s: got methods %s, want %s", typeName, got, want)
+		}
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	fset := token.NewFileSet()
+	files := []*ast.File{}
+	for name, src := range map[string]string{
+		"store_linux.go":   "package test\n\nfunc (s *Store) Open(path string) error\nfunc (s *Store) Fd() uintptr\n",
+		"store_windows.go": "package test\n\nfunc (s *Store) Open(name string) error\nfunc (s *Store) Fd() int\n",
+	} {
+		file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return fset.Position(files[i].Package).Filename < fset.Position(files[j].Package).Filename
+	})
+
+	methods := ExtractMethods(files, "Store")
+	deduped, err := Dedupe(fset, []*ast.FuncDecl{methods[0], methods[2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deduped) != 1 || deduped[0] != methods[0] {
+		t.Errorf("got %d methods, want Open from store_linux.go", len(deduped))
+	}
+
+	_, err = Dedupe(fset, methods)
+	want := "store_windows.go:4:1: method Fd is declared as Fd() uintptr in store_linux.go and as Fd() int in store_windows.go (GIG005)"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	src := `package test
+
+type Store struct{}
+
+// Get gets the value.
+//
+// Deprecated: Use Lookup instead,
+// it handles missing keys.
+func (s *Store) Get(key string) string
+
+// Lookup looks up the value.
+func (s *Store) Lookup(key string) (string, bool)
+
+// Put stores the value. Deprecated: mentioned in passing.
+func (s *Store) Put(key, value string)
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := ExtractMethods([]*ast.File{file}, "Store")
+
+	names := []string{}
+	for _, method := range Filter(methods, Not(IsDeprecated)) {
+		names = append(names, method.Name.Name)
+	}
+
+	if got, want := strings.Join(names, " "), "Lookup Put"; got != want {
+		t.Errorf("got methods %s, want %s", got, want)
+	}
+
+	file, err = ParseFile(fset, "test.go", []byte("package test\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err = MergeInto(fset, file, buildInterface(t, "Iface", methods, nil, Options{DeprecationNotices: true}), "Store", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface, err := FindInterface(file, "Iface")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, iface); err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	want := `type Iface interface {
+	// Deprecated: Use Lookup instead,
+	// it handles missing keys.
+	Get(key string) string
+	Lookup(key string) (string, bool)
+	Put(key, value string)
+}`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNameConflicts(t *testing.T) {
+	src := `package test
+
+type Store struct{}
+
+type (
+	// StoreIface is taken.
+	StoreIface struct{}
+
+	// Other stays.
+	Other int
+)
+
+type StoreIface2 int
+
+type Reader interface{}
+
+func StoreIfaceX() {}
+`
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{"StoreIface": true, "StoreIfaceX": true, "Reader": false, "Writer": false} {
+		if got := NameTaken(file, name); got != want {
+			t.Errorf("NameTaken(%s) = %v, want %v", name, got, want)
+		}
+	}
+
+	if got := FreeName(file, "StoreIface", ""); got != "StoreIface3" {
+		t.Errorf("got %s, want StoreIface3", got)
+	}
+
+	if got := FreeName(file, "StoreIface", "X"); got != "StoreIfaceX2" {
+		t.Errorf("got %s, want StoreIfaceX2", got)
+	}
+
+	file, err = RemoveType(fset, file, "StoreIface")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "StoreIface is taken") || !strings.Contains(buf.String(), "// Other stays.\n\tOther int") {
+		t.Errorf("got\n%s\nwant StoreIface and its comment removed from the group", buf.String())
+	}
+
+	if NameTaken(file, "StoreIface") {
+		t.Error("StoreIface is still taken after removing it")
+	}
+}
+
+func TestPackageMethods(t *testing.T) {
+	fset := token.NewFileSet()
+	methods, files, err := PackageMethods(fset, "bytes", ".", "Buffer", ResolveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, method := range methods {
+		got[method.Name.Name] = types.ExprString(method.Type)
+	}
+
+	if want := "func(r io.Reader) (n int64, err error)"; got["ReadFrom"] != want {
+		t.Errorf("got ReadFrom %s, want %s", got["ReadFrom"], want)
+	}
+
+	if _, ok := got["grow"]; ok {
+		t.Error("got the unexported method grow")
+	}
+
+	// the doc comments are carried over
+	docs := map[string]string{}
+	for _, method := range methods {
+		docs[method.Name.Name] = method.Doc.Text()
+	}
+
+	if want := "Len returns the number of bytes"; !strings.HasPrefix(docs["Len"], want) {
+		t.Errorf("got Len doc %q, want it to start with %q", docs["Len"], want)
+	}
+
+	// types.Interface.Embedded is deprecated
+	ifaceMethods, _, err := PackageMethods(fset, "go/types", ".", "Interface", ResolveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := FilterMethods(ifaceMethods, FilterOptions{SkipDeprecated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range current {
+		if method.Name.Name == "Embedded" {
+			t.Error("got the deprecated method Embedded")
+		}
+	}
+	if len(current) != len(ifaceMethods)-1 {
+		t.Errorf("got %d methods, want all %d but Embedded", len(current), len(ifaceMethods))
+	}
+
+	imports := MethodImports(fset, files, methods)
+	if !reflect.DeepEqual(imports, []Import{{Path: "io"}}) {
+		t.Errorf("got imports %v, want io", imports)
+	}
+
+	var d *Diagnostic
+	if _, _, err := PackageMethods(fset, "bytes", ".", "Missing", ResolveOptions{}); !errors.As(err, &d) || d.Code != CodeTypeNotFound || d.Msg != "type Missing not found in package bytes" {
+		t.Errorf("got error %v for a missing type", err)
+	}
+}
+
+func TestDeclarable(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", `package test
+
+func (s *Store) Conn() *sql.Conn
+func (s *Store) Driver() *sql.driverConn
+func (s *Store) close() error
+`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declarable, unqualifiable := Declarable(ExtractMethods([]*ast.File{file}, "Store"))
+	if len(declarable) != 1 || declarable[0].Name.Name != "Conn" {
+		t.Errorf("got %d declarable methods, want Conn", len(declarable))
+	}
+
+	if len(unqualifiable) != 2 || unqualifiable[0].Ident != "sql.driverConn" || unqualifiable[1].Ident != "close" {
+		t.Errorf("got unqualifiable %v, want Driver referencing sql.driverConn and the unexported close", unqualifiable)
+	}
+}
+
+func TestRenamePackage(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", `package test
+
+func (c *Client) Do(req *http.Request) (*http.Response, error)
+`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := ExtractMethods([]*ast.File{file}, "Client")
+	renamed, err := RenamePackage(methods, "http", "nethttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := types.ExprString(renamed[0].Type), "func(req *nethttp.Request) (*nethttp.Response, error)"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if got := types.ExprString(methods[0].Type); got != "func(req *http.Request) (*http.Response, error)" {
+		t.Errorf("the original method was changed to %s", got)
+	}
+
+	if !References(renamed, "nethttp") || References(renamed, "http") {
+		t.Error("got references to http, want only to nethttp")
+	}
+}
+
+func TestUsedImports(t *testing.T) {
+	src := `package test
+
+import (
+	"context"
+	nethttp "net/http"
+	"time"
+)
+
+type Client interface {
+	Do(ctx context.Context, req *nethttp.Request) (*nethttp.Response, error)
+}
+
+var started = time.Now()
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decl, err := FindInterface(file, "Client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Import{{Path: "context"}, {Name: "nethttp", Path: "net/http"}}
+	if got := UsedImports(fset, file, decl); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	src := `package test
+
+type T struct{}
+
+func (t T) Get() {}
+
+var TIface int
+
+type G[K comparable] struct{}
+`
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface := buildInterface(t, "TIface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{})
+	_, err = MergeInto(fset, file, iface, "T", MergeOptions{})
+
+	var d *Diagnostic
+	if !errors.As(err, &d) {
+		t.Fatalf("got error %v, want a diagnostic", err)
+	}
+
+	if d.Code != CodeNameTaken || d.Ident != "TIface" || d.Pos.String() != "test.go:7:5" {
+		t.Errorf("got %s %s at %s, want GIG002 TIface at test.go:7:5", d.Code, d.Ident, d.Pos)
+	}
+
+	if _, err := AddAssertion(fset, file, "TIface", "G"); !errors.As(err, &d) || d.Code != CodeGeneric || d.Pos.Line != 9 {
+		t.Errorf("got error %v, want GIG006 at line 9", err)
+	}
+
+	_, err = firstLineOfTypeIncludingComments("Missing", file)
+	if !errors.As(err, &d) || d.Code != CodeTypeNotFound || d.Pos.IsValid() {
+		t.Fatalf("got error %v, want GIG001 without a position", err)
+	}
+
+	if err := Place(err, fset, file, "test.go"); err.Error() != "test.go: type Missing not found (GIG001)" {
+		t.Errorf("got %v once placed", err)
+	}
+
+	if _, err := FindInterface(file, "Missing"); !errors.As(err, &d) || d.Code != CodeInterfaceNotFound {
+		t.Errorf("got error %v, want GIG004", err)
+	}
+}
+
+func TestMergeIntoLog(t *testing.T) {
+	src := `package test
+
+type T struct{}
+
+func (t T) Get() {}
+`
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}}))
+
+	iface := buildInterface(t, "TIface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{})
+	if _, err := MergeInto(fset, file, iface, "T", MergeOptions{Log: log}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "level=INFO msg=\"inserting the interface above its type\" interface=TIface type=T pos=test.go:3:6\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAssertionTest(t *testing.T) {
+	src, err := AssertionTest("// header", "iface", []Import{{Name: "pg", Path: "example.com/postgres"}}, []string{"Store"}, []string{"pg.Store"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `// header
+
+package iface
+
+import (
+	pg "example.com/postgres"
+	"testing"
+)
+
+var _ Store = *new(pg.Store)
+
+func TestStoreImplementsStore(t *testing.T) {
+	var v interface{} = *new(pg.Store)
+	if _, ok := v.(Store); !ok {
+		t.Error("pg.Store does not implement Store")
+	}
+}
+`
+	if got := string(src); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeIntoStable(t *testing.T) {
+	src := `package test
+
+type Iface interface {
+	// Get gets
+	Get(id int)
+	Keep() // as written
+}
+
+type T struct{}
+
+func (t T) Add()          {}
+func (t T) Get(id string) {}
+func (t T) Keep()         {}
+`
+	want := `package test
+
+type Iface interface {
+	// Get gets
+	Get(id string)
+	Keep() // as written
+	Add()
+}
+
+type T struct{}
+
+func (t T) Add()          {}
+func (t T) Get(id string) {}
+func (t T) Keep()         {}
+`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// regenerating leaves the interface as it is
+	for i := 0; i < 2; i++ {
+		iface := buildInterface(t, "Iface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{})
+		file, err = MergeInto(fset, file, iface, "T", MergeOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, file); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := buf.String(); got != want {
+			t.Errorf("run %d got:\n%s\nwant:\n%s", i+1, got, want)
+		}
+	}
+}
+
+func TestMergeIntoComments(t *testing.T) {
+	src := `package test
+
+type Iface interface {
+	// reads
+
+	// Get gets
+	Get(id int) // by id
+
+	// writes
+
+	// Old is gone
+	Old()
+	Put(id int) // stores
+
+	// TODO: Delete
+}
+
+type T struct{}
+
+func (t T) Get(id string) {}
+func (t T) Put(id int)    {}
+`
+	want := `package test
+
+type Iface interface {
+	// reads
+
+	// Get gets
+	Get(id string) // by id
+
+	// writes
+
+	Put(id int) // stores
+
+	// TODO: Delete
+}
+
+type T struct{}
+
+func (t T) Get(id string) {}
+func (t T) Put(id int)    {}
+`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface := buildInterface(t, "Iface", ExtractMethods([]*ast.File{file}, "T"), nil, Options{})
+	file, err = MergeInto(fset, file, iface, "T", MergeOptions{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDrifted(t *testing.T) {
+	src := `package test
+
+import "context"
+
+type Iface interface {
+	io.Closer
+	Get(id string) (item string, err error)
+	Old()
+	Wait(ctx context.Context)
+}
+
+type T struct{}
+
+func (t T) Get(ctx context.Context, id string) (string, error) { return "", nil }
+func (t T) Wait(ctx other.Context)                               {}
+`
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drifted, err := Drifted(fset, file, "Iface", ExtractMethods([]*ast.File{file}, "T"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Drift{
+		{Name: "Get", Method: "Get(string) (string, error)", TypeMethod: "Get(context.Context, string) (string, error)"},
+		{Name: "Old", Method: "Old()"},
+	}
+	if len(drifted) != len(want) {
+		t.Fatalf("got %v, want %v", drifted, want)
+	}
+
+	for i, d := range drifted {
+		d.Pos = token.Position{}
+		if d != want[i] {
+			t.Errorf("got %v, want %v", d, want[i])
+		}
+	}
+}
+
+func TestBuildInterfaceTerms(t *testing.T) {
+	src := `package test
+
+type Celsius float64
+
+func (c Celsius) String() string { return "" }
+
+type Set[K comparable] struct{}
+
+func (s Set[K]) Has(k K) bool { return false }
+`
+	want := `type Iface interface {
+	Celsius | ~float64
+	fmt.Stringer
+	String() string
+}`
+
+	got := generateWithOptions(t, src, "Celsius", Options{Terms: []string{"Celsius", "~float64", "~"}, Embeds: []string{"fmt.Stringer"}})
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	want = `type Iface[K comparable] interface {
+	Set[K]
+	Has(k K) bool
+}`
+
+	if got := generateWithOptions(t, src, "Set", Options{Terms: []string{"Set[K]"}}); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEmbedInto(t *testing.T) {
+	src := `package test
+
+import "io"
+
+// Big is everything
+type Big interface {
+	io.Closer
+	// Get gets
+	Get(id int)
+	Keep() // as written
+}
+
+// Getter is the interface implemented by T.
+type Getter interface {
+	Get(id int)
+}
+
+type T struct{}
+
+func (t T) Get(id int) {}
+`
+	want := `package test
+
+import "io"
+
+// Big is everything
+type Big interface {
+	io.Closer
+	Getter
+	Keep() // as written
+}
+
+// Getter is the interface implemented by T.
+type Getter interface {
+	Get(id int)
+}
+
+type T struct{}
+
+func (t T) Get(id int) {}
+`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// embedding again leaves the interface as it is
+	for i := 0; i < 2; i++ {
+		file, err = EmbedInto(fset, file, "Getter", "Big")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, file); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := buf.String(); got != want {
+			t.Errorf("run %d got:\n%s\nwant:\n%s", i+1, got, want)
+		}
+	}
+
+	var d *Diagnostic
+	if _, err := EmbedInto(fset, file, "Getter", "T"); !errors.As(err, &d) || d.Code != CodeWrongKind {
+		t.Errorf("got error %v, want GIG007", err)
+	}
+}
+
+func TestSections(t *testing.T) {
+	src := `package test
+
+type Store struct{}
+
+// queries
+
+func (s *Store) Get(id int) {}
+
+func (s *Store) List() {} // all of them
+
+// mutations
+
+func (s *Store) Put(id int) {}
+
+type Small struct{}
+
+func (Small) A() {}
+func (Small) B() {}
+
+func (Small) C() {}
+`
+	want := `package test
+
+type Iface interface {
+	// queries
+	Get(id int)
+	List()
+
+	// mutations
+	Put(id int)
+}
+
+type Smaller interface {
+	A()
+	B()
+
+	C()
+}
+`
+
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the sections of an existing interface are kept
+	for i := 0; i < 2; i++ {
+		var out *ast.File
+		out, err = ParseFile(fset, "out.go", []byte("package test\n"))
+		if i > 0 {
+			out, err = ParseFile(fset, "out.go", []byte(want))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, typeName := range []string{"Store", "Small"} {
+			methods := ExtractMethods([]*ast.File{file}, typeName)
+			name := "Iface"
+			if typeName == "Small" {
+				name = "Smaller"
+			}
+
+			iface := buildInterface(t, name, methods, nil, Options{Sections: Sections(fset, []*ast.File{file}, methods)})
+			out, err = MergeInto(fset, out, iface, typeName, MergeOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, out); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := buf.String(); got != want {
+			t.Errorf("run %d got:\n%s\nwant:\n%s", i+1, got, want)
+		}
+	}
+}
+
+// largeSource returns a file declaring n types with a few methods each, like a generated file
+func largeSource(n int) []byte {
+	var b strings.Builder
+	b.WriteString("package test\n\nimport \"fmt\"\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "\n// T%d is a type\ntype T%d struct {\n\tname string // the name\n}\n", i, i)
+		for _, method := range []string{"Get", "Put", "Delete"} {
+			fmt.Fprintf(&b, "\n// %s does something\nfunc (t *T%d) %s(key string, value []byte) (int, error) {\n\treturn fmt.Println(t.name, key, value)\n}\n", method, i, method)
+		}
+	}
+
+	return []byte(b.String())
+}
+
+func BenchmarkMergeInto(b *testing.B) {
+	src := largeSource(2000)
+	for i := 0; i < b.N; i++ {
+		fset := token.NewFileSet()
+		file, err := ParseFile(fset, "test.go", src)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		// several interfaces merged into the file, as with -gen
+		for _, typeName := range []string{"T0", "T1000", "T1999"} {
+			iface := buildInterface(b, typeName+"Iface", ExtractMethods([]*ast.File{file}, typeName), nil, Options{})
+			file, err = MergeInto(fset, file, iface, typeName, MergeOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, file); err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := PreserveFormatting(src, buf.Bytes()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	v1 := `package store
+
+type User struct{}
+
+type Store interface {
+	Get(id string) *User
+	Delete(id string) error
+	List() []User
+}
+`
+	v2 := `package store
+
+type User struct{}
+
+type Lister interface{ List() []User }
+
+type Store interface {
+	Lister
+	Get(key string) (*User, error)
+	Put(u *User) error
+}
+
+type Memory struct{}
+
+func (m *Memory) Get(id string) *User { return nil }
+func (m Memory) List() []User        { return nil }
+`
+	fset := token.NewFileSet()
+	pkgs := []Package{}
+	for i, src := range []string{v1, v2} {
+		file, err := parser.ParseFile(fset, fmt.Sprintf("v%d.go", i+1), src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgs = append(pkgs, Package{Path: fmt.Sprintf("example.com/v%d/store", i+1), Files: []*ast.File{file}})
+	}
+
+	// parameter names don't change a method and types compare by their package's name
+	changes, err := Compare(fset, pkgs[0], "Store", pkgs[1], "Store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []MethodChange{
+		{Name: "Delete", Old: "Delete(id string) error"},
+		{Name: "Get", Old: "Get(id string) *store.User", New: "Get(key string) (*store.User, error)"},
+		{Name: "Put", New: "Put(u *store.User) error"},
+	}
+
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got %+v, want %+v", changes, want)
+	}
+
+	// a type is compared by the method set of its pointer
+	changes, err = Compare(fset, pkgs[1], "Store", pkgs[1], "Memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want = []MethodChange{
+		{Name: "Get", Old: "Get(key string) (*store.User, error)", New: "Get(id string) *store.User"},
+		{Name: "Put", Old: "Put(u *store.User) error"},
+	}
+
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got %+v, want %+v", changes, want)
+	}
+
+	if _, err := Compare(fset, pkgs[0], "Missing", pkgs[1], "Store"); err == nil {
+		t.Error("expected an error comparing a missing type")
+	}
+}
+
+func TestRename(t *testing.T) {
+	src := `package test
+
+// Store is the interface implemented by Memory.
+type Store interface {
+	Get(id string) string
+}
+
+type Memory struct{}
+
+func (m Memory) Get(id string) string { return id }
+
+var _ Store = Memory{}
+
+type Service struct {
+	store Store
+}
+
+func New(s Store) *Service { return &Service{store: s} }
+`
+	other := `package test
+
+func Wrap(s Store) Store { return s }
+`
+	fset := token.NewFileSet()
+	files := []*ast.File{}
+	for i, s := range []string{src, other, "package test\n"} {
+		file, err := ParseFile(fset, fmt.Sprintf("file%d.go", i), []byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	renamed, err := Rename(fset, files, "Store", "UserStore")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file without references is left out
+	if len(renamed) != 2 {
+		t.Fatalf("expected 2 renamed files, got %d", len(renamed))
+	}
+
+	got := ""
+	for _, file := range renamed {
+		_, s, err := source(fset, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got += s
+	}
+
+	want := strings.ReplaceAll(src+other, "Store", "UserStore")
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := Rename(fset, files, "Store", "Service"); err == nil {
+		t.Error("expected an error renaming to a declared name")
+	}
+
+	if _, err := Rename(fset, files, "Memory", "Mem"); err == nil {
+		t.Error("expected an error renaming a type that isn't an interface")
+	}
+
+	// a reference would refer to the local declaration instead
+	shadowed, err := ParseFile(fset, "shadowed.go", []byte(`package test
+
+func Use() {
+	UserStore := 1
+	var s Store
+	_, _ = UserStore, s
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Rename(fset, append(files, shadowed), "Store", "UserStore"); err == nil {
+		t.Error("expected an error renaming to a name declared where the interface is referenced")
+	}
+}
+
+func TestReplaceType(t *testing.T) {
+	src := `package test
+
+type Store struct{ n int }
+
+func (s *Store) Get(id string) string { return id }
+func (s *Store) Merge(other *Store)    { s.n += other.n }
+
+type Getter interface {
+	Get(id string) string
+}
+
+type Service struct {
+	store *Store
+	raw   *Store
+}
+
+func New(store *Store) *Service { return &Service{store: store} }
+
+func (s *Service) Name(id string) string {
+	st := s.store
+	return st.Get(id)
+}
+
+func Count(s *Store) int { return s.n }
+
+func Both(a, b *Store) int { a.Get(""); return b.n }
+
+func Reset(s *Service) { s.raw.n = 0 }
+
+var hook func(*Store) = Hooked
+
+func Hooked(s *Store) { s.Get("") }
+`
+	fset := token.NewFileSet()
+	file, err := ParseFile(fset, "test.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switched, err := ReplaceType(fset, []*ast.File{file}, "Store", "Getter")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(switched) != 1 {
+		t.Fatalf("expected 1 switched file, got %d", len(switched))
+	}
+
+	_, got, err := source(fset, switched[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only what calls nothing but Get is switched, not the fields used, the declarations sharing
+	// their type with one that's used, the type's own methods or a function used as a value
+	for _, want := range []string{
+		"\tstore Getter\n\traw   *Store\n",
+		"func New(store Getter) *Service",
+		"func Count(s *Store) int",
+		"func Both(a, b *Store) int",
+		"func (s *Store) Merge(other *Store)",
+		"func Hooked(s *Store)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in\n%s", want, got)
+		}
+	}
+
+	if _, err := ReplaceType(fset, []*ast.File{file}, "Store", "Service"); err == nil {
+		t.Error("expected an error switching to a type that isn't an interface")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	fset := token.NewFileSet()
+	files := []*ast.File{}
+	for name, src := range map[string]string{
+		"store.go":  "package test\n\ntype Store struct{}\n\nfunc (s *Store) Get() Item { return Item{} }\n\ntype Item struct{}\n",
+		"iface.go":  "package test\n\ntype Iface interface {\n\tGet() Item\n\tPut(Itme)\n}\n",
+		"broken.go": "package test\n\nvar broken = undefined\n",
+	} {
+		file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	// the errors of the other files are left out
+	err := Validate(fset, files, "iface.go")
+	if err == nil || err.Error() != "iface.go:5:6: undefined: Itme" {
+		t.Errorf("expected the undefined type of iface.go, got %v", err)
+	}
+
+	if err := Validate(fset, files, "store.go"); err != nil {
+		t.Errorf("expected store.go to compile, got %v", err)
+	}
+}
+
+func TestClusters(t *testing.T) {
+	src := `package test
+
+type God struct {
+	db    map[string]string
+	cache map[string]string
+	ttl   int
+}
+
+func (g *God) Get(id string) string  { return g.load(id) }
+func (g *God) Put(id, v string)      { g.db[id] = v }
+func (g *God) load(id string) string { return g.db[id] }
+func (g *God) Invalidate(id string)  { delete(g.cache, id) }
+func (g *God) Expire()               { g.ttl = 0 }
+func (g *God) Warm(id string)        { g.cache[id] = ""; g.Expire() }
+func (g *God) Version() string       { return "1" }
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*ast.File{file}
+	methods := Filter(ExtractMethods(files, "God"), Exported)
+
+	// Get uses db through load and Warm joins Expire's ttl to the cache's methods
+	got := []string{}
+	for _, cluster := range Clusters(files, "God", methods) {
+		names := []string{}
+		for _, method := range cluster.Methods {
+			names = append(names, method.Name.Name)
+		}
+		got = append(got, fmt.Sprintf("%s %v %v", cluster.Name, names, cluster.Fields))
+	}
+
+	want := []string{
+		"DB [Get Put] [db]",
+		"Cache [Invalidate Expire Warm] [cache ttl]",
+		" [Version] []",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestClustersGodObject(t *testing.T) {
+	src := `package test
+
+type SvcCache struct {
+	db    map[string]string
+	cache map[string]string
+	log   []string
+	hits  int
+}
+
+func (s *SvcCache) Get(id string) string { return s.db[id] }
+func (s *SvcCache) Put(id, v string)     { s.db[id] = v }
+func (s *SvcCache) Invalidate(id string) { delete(s.cache, id) }
+func (s *SvcCache) Warm(id string)       { s.cache[id] = "" }
+func (s *SvcCache) Logf(line string)     { s.log = append(s.log, line) }
+func (s *SvcCache) Hit()                 { s.hits++ }
+func (s *SvcCache) Reset()               { s.db, s.cache, s.log, s.hits = nil, nil, nil, 0 }
+func (s *SvcCache) Stats() string        { return fmt.Sprint(len(s.cache), s.hits, len(s.log)) }
+func (s *SvcCache) Close() error         { s.Reset(); return nil }
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*ast.File{file}
+	methods := ExtractMethods(files, "SvcCache")
+
+	// Reset, Stats and Close use most of the fields and would join every cluster into one
+	got := []string{}
+	for _, cluster := range Clusters(files, "SvcCache", methods) {
+		names := []string{}
+		for _, method := range cluster.Methods {
+			names = append(names, method.Name.Name)
+		}
+		got = append(got, fmt.Sprintf("%s %v", cluster.Name, names))
+	}
+
+	want := []string{
+		"DB [Get Put Reset Close]",
+		"Cache [Invalidate Warm Stats]",
+		"Log [Logf]",
+		"Hits [Hit]",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,464 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ImplOptions control how ResolveInterface resolves an interface for generating
+// types that implement it
+type ImplOptions struct {
+	Package    string // package of the generated file, the interface's own package when empty
+	ImportPath string // import path of the interface's package, required when Package differs
+}
+
+// Impl is an interface resolved for generating types that implement it
+type Impl struct {
+	Interface string // the interface as referred to by the generated file
+	Methods   []Method
+
+	pkgName string
+	imports map[string]string // package name keyed by import path
+}
+
+// Method is a method of an interface. Unnamed parameters are given names
+// so the method can be implemented and the names never clash with the
+// receiver or the packages referred to by the types
+type Method struct {
+	Name     string
+	Params   []Var
+	Results  []Var
+	Variadic bool // the last parameter's type is ...T, its Type is T
+}
+
+// Var is a parameter or result of a method
+type Var struct {
+	Name string
+	Type string
+	Zero string // the zero value of the type
+}
+
+// receiverName is the name of the receiver of generated methods
+const receiverName = "m"
+
+// ResolveInterface resolves the named interface by type checking files, the files
+// of a single package. The methods of embedded interfaces are included
+func ResolveInterface(fset *token.FileSet, files []*ast.File, interfaceName string, opts ImplOptions) (*Impl, error) {
+	pkg, named, err := lookupNamed(fset, files, interfaceName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	iface, ok := named.Underlying().(*types.Interface)
+	if !ok {
+		return nil, diagnosticAt(fset, named.Obj().Pos(), CodeWrongKind, interfaceName, "%s is not an interface", interfaceName)
+	}
+
+	if named.TypeParams().Len() > 0 {
+		return nil, diagnosticAt(fset, named.Obj().Pos(), CodeGeneric, interfaceName, "cannot implement generic interface %s", interfaceName)
+	}
+
+	impl := &Impl{
+		Interface: interfaceName,
+		pkgName:   pkg.Name(),
+		imports:   make(map[string]string),
+	}
+
+	// types of the interface's package are qualified
+	// when generating into another package
+	external := opts.Package != "" && opts.Package != pkg.Name()
+	if external {
+		if opts.ImportPath == "" {
+			return nil, fmt.Errorf("the import path of package %s is required to generate into package %s", pkg.Name(), opts.Package)
+		}
+
+		impl.pkgName = opts.Package
+		impl.imports[opts.ImportPath] = pkg.Name()
+		impl.Interface = pkg.Name() + "." + interfaceName
+	}
+
+	qualifier := func(other *types.Package) string {
+		if other == pkg {
+			if !external {
+				return ""
+			}
+
+			return pkg.Name()
+		}
+
+		impl.imports[other.Path()] = other.Name()
+		return other.Name()
+	}
+
+	for i := 0; i < iface.NumMethods(); i++ {
+		fn := iface.Method(i)
+		if external && !fn.Exported() {
+			return nil, diagnosticAt(fset, fn.Pos(), CodeUnexported, fn.Name(), "cannot implement %s outside of package %s: method %s is unexported", interfaceName, pkg.Name(), fn.Name())
+		}
+
+		sig := fn.Type().(*types.Signature)
+		method := Method{Name: fn.Name(), Variadic: sig.Variadic()}
+		for j := 0; j < sig.Params().Len(); j++ {
+			param := sig.Params().At(j)
+			typ := param.Type()
+			if method.Variadic && j == sig.Params().Len()-1 {
+				typ = typ.(*types.Slice).Elem()
+			}
+
+			method.Params = append(method.Params, Var{Name: param.Name(), Type: types.TypeString(typ, qualifier)})
+		}
+
+		for j := 0; j < sig.Results().Len(); j++ {
+			result := sig.Results().At(j)
+			method.Results = append(method.Results, Var{
+				Name: result.Name(),
+				Type: types.TypeString(result.Type(), qualifier),
+				Zero: zeroValue(result.Type(), qualifier),
+			})
+		}
+
+		impl.Methods = append(impl.Methods, method)
+	}
+
+	// name the parameters once every imported package is known
+	for i := range impl.Methods {
+		impl.nameVars(impl.Methods[i].Params, "p")
+		impl.nameVars(impl.Methods[i].Results, "r")
+	}
+
+	return impl, nil
+}
+
+// zeroValue returns an expression of the zero value of the type
+func zeroValue(typ types.Type, qualifier types.Qualifier) string {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			return "false"
+		case t.Info()&types.IsString != 0:
+			return `""`
+		case t.Info()&types.IsNumeric != 0:
+			return "0"
+		}
+	case *types.Struct, *types.Array:
+		return types.TypeString(typ, qualifier) + "{}"
+	case *types.Interface:
+		if _, ok := typ.(*types.TypeParam); ok {
+			return "*new(" + types.TypeString(typ, qualifier) + ")"
+		}
+	}
+
+	return "nil"
+}
+
+// nameVars names vars that are unnamed or whose names clash
+// with the receiver, an imported package or one another
+func (impl *Impl) nameVars(vars []Var, prefix string) {
+	taken := map[string]bool{receiverName: true}
+	for _, name := range impl.imports {
+		taken[name] = true
+	}
+
+	for i := range vars {
+		if vars[i].Name == "" || vars[i].Name == "_" || taken[vars[i].Name] {
+			vars[i].Name = prefix + strconv.Itoa(i)
+		}
+		taken[vars[i].Name] = true
+	}
+}
+
+// Source returns the formatted source of a file in the generated package
+// with the given header comment, imports and declarations
+func (impl *Impl) Source(header string, decls ...string) ([]byte, error) {
+	var buf bytes.Buffer
+	if header != "" {
+		buf.WriteString(header + "\n\n")
+	}
+
+	fmt.Fprintf(&buf, "package %s\n", impl.pkgName)
+
+	paths := []string{}
+	for importPath := range impl.imports {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+
+	if len(paths) > 0 {
+		buf.WriteString("\nimport (\n")
+		for _, importPath := range paths {
+			if name := impl.imports[importPath]; name != path.Base(importPath) {
+				buf.WriteString(name + " ")
+			}
+			buf.WriteString(strconv.Quote(importPath) + "\n")
+		}
+		buf.WriteString(")\n")
+	}
+
+	for _, decl := range decls {
+		buf.WriteString("\n" + decl + "\n")
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// Mock returns the declaration of a mock implementation of the interface named
+// typeName. Each method calls the function field named after it, which a test sets
+//
+//	mock := &MockStore{GetFunc: func(key string) ([]byte, error) { return nil, nil }}
+func (impl *Impl) Mock(typeName string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s is a mock implementation of %s. Each method\n", typeName, impl.Interface)
+	fmt.Fprintf(&buf, "// calls the function of the same name, which must be set\n")
+	fmt.Fprintf(&buf, "type %s struct {\n", typeName)
+	for _, method := range impl.Methods {
+		fmt.Fprintf(&buf, "%sFunc func%s\n", method.Name, method.signature())
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(&buf, "var _ %s = (*%s)(nil)\n", impl.Interface, typeName)
+
+	for _, method := range impl.Methods {
+		fmt.Fprintf(&buf, "\n// %s calls %sFunc\n", method.Name, method.Name)
+		fmt.Fprintf(&buf, "func (%s *%s) %s%s {\n", receiverName, typeName, method.Name, method.signature())
+		fmt.Fprintf(&buf, "if %s.%sFunc == nil {\n", receiverName, method.Name)
+		fmt.Fprintf(&buf, "panic(%q)\n", typeName+"."+method.Name+": "+method.Name+"Func is not set")
+		buf.WriteString("}\n")
+		fmt.Fprintf(&buf, "%s%s.%sFunc(%s)\n", method.returnPrefix(), receiverName, method.Name, method.args())
+		buf.WriteString("}\n")
+	}
+
+	return buf.String()
+}
+
+// Stub returns the declaration of a type named typeName implementing the interface
+// with methods that panic, a skeleton for a new implementation to fill in
+func (impl *Impl) Stub(typeName string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s implements %s\n", typeName, impl.Interface)
+	fmt.Fprintf(&buf, "type %s struct {\n}\n\n", typeName)
+	fmt.Fprintf(&buf, "var _ %s = (*%s)(nil)\n", impl.Interface, typeName)
+
+	for _, method := range impl.Methods {
+		fmt.Fprintf(&buf, "\nfunc (%s *%s) %s%s {\n", method.receiver(typeName), typeName, method.Name, method.signature())
+		buf.WriteString("panic(\"not implemented\")\n")
+		buf.WriteString("}\n")
+	}
+
+	return buf.String()
+}
+
+// Noop returns the declaration of a type named typeName implementing
+// the interface with methods that do nothing and return zero values
+func (impl *Impl) Noop(typeName string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s is a %s that does nothing. Its methods return zero values\n", typeName, impl.Interface)
+	fmt.Fprintf(&buf, "type %s struct{}\n\n", typeName)
+	fmt.Fprintf(&buf, "var _ %s = %s{}\n", impl.Interface, typeName)
+
+	for _, method := range impl.Methods {
+		fmt.Fprintf(&buf, "\nfunc (%s) %s%s {\n", typeName, method.Name, method.signature())
+		if len(method.Results) > 0 {
+			zeros := []string{}
+			for _, result := range method.Results {
+				zeros = append(zeros, result.Zero)
+			}
+			fmt.Fprintf(&buf, "return %s\n", strings.Join(zeros, ", "))
+		}
+		buf.WriteString("}\n")
+	}
+
+	return buf.String()
+}
+
+// Spy returns the declarations of a type named typeName implementing the interface
+// that records the arguments of every call for tests to inspect through its Calls
+// methods, which hold its lock like the calls do. Calls are passed on to the
+// implementation in its Next field when it is set and return zero values otherwise
+func (impl *Impl) Spy(typeName string) string {
+	impl.imports["sync"] = "sync"
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s is a %s that records its calls. Calls are passed\n", typeName, impl.Interface)
+	fmt.Fprintf(&buf, "// on to Next when it is set and return zero values otherwise\n")
+	fmt.Fprintf(&buf, "type %s struct {\n", typeName)
+	fmt.Fprintf(&buf, "Next %s\n\n", impl.Interface)
+	buf.WriteString("mu sync.Mutex\n")
+	for _, method := range impl.Methods {
+		fmt.Fprintf(&buf, "%sCalls []%s%sCall\n", lowerFirst(method.Name), typeName, method.Name)
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(&buf, "var _ %s = (*%s)(nil)\n", impl.Interface, typeName)
+
+	for _, method := range impl.Methods {
+		call := typeName + method.Name + "Call"
+		fmt.Fprintf(&buf, "\n// %s holds the arguments of a call to %s.%s\n", call, typeName, method.Name)
+		fmt.Fprintf(&buf, "type %s struct {\n", call)
+		for i, param := range method.Params {
+			if method.Variadic && i == len(method.Params)-1 {
+				fmt.Fprintf(&buf, "%s []%s\n", UpperFirst(param.Name), param.Type)
+			} else {
+				fmt.Fprintf(&buf, "%s %s\n", UpperFirst(param.Name), param.Type)
+			}
+		}
+		buf.WriteString("}\n")
+
+		fields := []string{}
+		for _, param := range method.Params {
+			fields = append(fields, UpperFirst(param.Name)+": "+param.Name)
+		}
+
+		fmt.Fprintf(&buf, "\nfunc (%s *%s) %s%s {\n", receiverName, typeName, method.Name, method.signature())
+		fmt.Fprintf(&buf, "%s.mu.Lock()\n", receiverName)
+		calls := lowerFirst(method.Name) + "Calls"
+		fmt.Fprintf(&buf, "%s.%s = append(%s.%s, %s{%s})\n", receiverName, calls, receiverName, calls, call, strings.Join(fields, ", "))
+		fmt.Fprintf(&buf, "%s.mu.Unlock()\n\n", receiverName)
+		fmt.Fprintf(&buf, "if %s.Next == nil {\n", receiverName)
+		if len(method.Results) > 0 {
+			zeros := []string{}
+			for _, result := range method.Results {
+				zeros = append(zeros, result.Zero)
+			}
+			fmt.Fprintf(&buf, "return %s\n", strings.Join(zeros, ", "))
+		} else {
+			buf.WriteString("return\n")
+		}
+		buf.WriteString("}\n\n")
+		fmt.Fprintf(&buf, "%s%s.Next.%s(%s)\n", method.returnPrefix(), receiverName, method.Name, method.args())
+		buf.WriteString("}\n")
+
+		fmt.Fprintf(&buf, "\n// %sCalls returns the calls to %s so far\n", method.Name, method.Name)
+		fmt.Fprintf(&buf, "func (%s *%s) %sCalls() []%s {\n", receiverName, typeName, method.Name, call)
+		fmt.Fprintf(&buf, "%s.mu.Lock()\n", receiverName)
+		fmt.Fprintf(&buf, "defer %s.mu.Unlock()\n\n", receiverName)
+		fmt.Fprintf(&buf, "return append([]%s(nil), %s.%s...)\n", call, receiverName, calls)
+		buf.WriteString("}\n")
+	}
+
+	return buf.String()
+}
+
+// Decorator returns the declaration of a type named typeName that embeds the interface
+// and passes every call on to it, a starting point for overriding some of the methods
+func (impl *Impl) Decorator(typeName string) string {
+	field := impl.Interface[strings.LastIndex(impl.Interface, ".")+1:]
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s wraps a %s, passing every call on to it\n", typeName, impl.Interface)
+	fmt.Fprintf(&buf, "type %s struct {\n", typeName)
+	fmt.Fprintf(&buf, "%s\n", impl.Interface)
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(&buf, "var _ %s = (*%s)(nil)\n", impl.Interface, typeName)
+
+	for _, method := range impl.Methods {
+		recv := method.receiver(typeName)
+		fmt.Fprintf(&buf, "\nfunc (%s *%s) %s%s {\n", recv, typeName, method.Name, method.signature())
+		fmt.Fprintf(&buf, "%s%s.%s.%s(%s)\n", method.returnPrefix(), recv, field, method.Name, method.args())
+		buf.WriteString("}\n")
+	}
+
+	return buf.String()
+}
+
+// UpperFirst returns s with its first letter in upper case
+func UpperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// lowerFirst returns s with its first letter in lower case
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
+
+// receiver returns the conventional name of a receiver of the named type, its
+// first letter in lower case, unless one of the method's parameters has that name
+func (m Method) receiver(typeName string) string {
+	name := strings.ToLower(typeName[:1])
+	for _, param := range m.Params {
+		if param.Name == name {
+			return receiverName
+		}
+	}
+
+	return name
+}
+
+// signature returns the method's parameters and results
+//
+//	(key string, opts ...Option) ([]byte, error)
+func (m Method) signature() string {
+	var buf bytes.Buffer
+	buf.WriteString("(")
+	for i, param := range m.Params {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+
+		if m.Variadic && i == len(m.Params)-1 {
+			fmt.Fprintf(&buf, "%s ...%s", param.Name, param.Type)
+		} else {
+			fmt.Fprintf(&buf, "%s %s", param.Name, param.Type)
+		}
+	}
+	buf.WriteString(")")
+
+	switch len(m.Results) {
+	case 0:
+	case 1:
+		buf.WriteString(" " + m.Results[0].Type)
+	default:
+		buf.WriteString(" (")
+		for i, result := range m.Results {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(result.Type)
+		}
+		buf.WriteString(")")
+	}
+
+	return buf.String()
+}
+
+// args returns the method's parameters as the arguments of a call passing them on
+//
+//	key, opts...
+func (m Method) args() string {
+	var buf bytes.Buffer
+	for i, param := range m.Params {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+
+		buf.WriteString(param.Name)
+		if m.Variadic && i == len(m.Params)-1 {
+			buf.WriteString("...")
+		}
+	}
+
+	return buf.String()
+}
+
+// returnPrefix returns "return " for a method with results, which
+// is what a call passing on the method's results is prefixed with
+func (m Method) returnPrefix() string {
+	if len(m.Results) == 0 {
+		return ""
+	}
+
+	return "return "
+}
@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// Package is the parsed files of a package and its import path
+type Package struct {
+	Path  string
+	Files []*ast.File
+}
+
+// Implementer is a type that implements an interface or nearly does
+type Implementer struct {
+	Type    string   // qualified by its import path and prefixed with * when only the pointer implements the interface
+	Missing []string // why the type doesn't implement the interface, empty when it does
+}
+
+// Implementers type checks pkgs and returns the named types declared in them that implement
+// the interface named interfaceName declared in the package with the import path ifacePath.
+// Types missing no more than near of the interface's methods, and having at least one of
+// them, are returned as near misses
+func Implementers(fset *token.FileSet, pkgs []Package, ifacePath, interfaceName string, near int) ([]Implementer, error) {
+	// the importer is shared so every package sees the same imported types
+	imp := importer.ForCompiler(fset, "source", nil)
+
+	checked := []*types.Package{}
+	var iface *types.Interface
+	for _, pkg := range pkgs {
+		conf := types.Config{
+			Importer:    imp,
+			Error:       func(error) {}, // keep going to resolve as much as possible
+			FakeImportC: true,           // accept the import "C" of cgo files
+		}
+
+		checkedPkg, _ := conf.Check(pkg.Path, fset, pkg.Files, nil)
+		checked = append(checked, checkedPkg)
+
+		if pkg.Path == ifacePath {
+			iface = lookupInterface(checkedPkg, interfaceName)
+		}
+	}
+
+	if iface == nil {
+		ifacePkg, err := imp.Import(ifacePath)
+		if err != nil {
+			return nil, err
+		}
+
+		iface = lookupInterface(ifacePkg, interfaceName)
+	}
+
+	if iface == nil {
+		return nil, newDiagnostic(CodeInterfaceNotFound, interfaceName, "interface %s not found in %s", interfaceName, ifacePath)
+	}
+
+	implementers := []Implementer{}
+	for _, pkg := range checked {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || obj.IsAlias() || types.IsInterface(obj.Type()) {
+				continue
+			}
+
+			qualified := pkg.Path() + "." + name
+			if types.Implements(obj.Type(), iface) {
+				implementers = append(implementers, Implementer{Type: qualified})
+				continue
+			}
+
+			ptr := types.NewPointer(obj.Type())
+			if types.Implements(ptr, iface) {
+				implementers = append(implementers, Implementer{Type: "*" + qualified})
+				continue
+			}
+
+			missing := missingMethods(ptr, iface)
+			if len(missing) <= near && len(missing) < iface.NumMethods() {
+				implementers = append(implementers, Implementer{Type: qualified, Missing: missing})
+			}
+		}
+	}
+
+	return implementers, nil
+}
+
+// lookupInterface returns the named interface declared in the package or nil if there is none
+func lookupInterface(pkg *types.Package, interfaceName string) *types.Interface {
+	obj, ok := pkg.Scope().Lookup(interfaceName).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+
+	iface, _ := obj.Type().Underlying().(*types.Interface)
+	return iface
+}
+
+// missingMethods explains which of the interface's methods the type lacks or has with the wrong signature
+func missingMethods(typ types.Type, iface *types.Interface) []string {
+	missing := []string{}
+	for i := 0; i < iface.NumMethods(); i++ {
+		want := iface.Method(i)
+		obj, _, _ := types.LookupFieldOrMethod(typ, false, want.Pkg(), want.Name())
+
+		have, ok := obj.(*types.Func)
+		switch {
+		case !ok:
+			missing = append(missing, "missing method "+want.Name()+methodSignature(want))
+		case !types.Identical(have.Type(), want.Type()):
+			missing = append(missing, fmt.Sprintf("wrong signature for %s: have %s, want %s", want.Name(), methodSignature(have), methodSignature(want)))
+		}
+	}
+
+	return missing
+}
+
+// methodSignature returns the signature of the method without the func keyword
+func methodSignature(fn *types.Func) string {
+	qualifier := func(pkg *types.Package) string { return pkg.Name() }
+	return strings.TrimPrefix(types.TypeString(fn.Type(), qualifier), "func")
+}
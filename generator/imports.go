@@ -0,0 +1,217 @@
+package generator
+
+import (
+	"go/ast"
+	"go/build"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Import is an import of a package, named when the importing file renames it
+type Import struct {
+	Name string
+	Path string
+}
+
+// MethodImports returns the imports, as imported by files, of the packages referenced
+// by the parameter and result types of the methods, sorted by path. A package that none
+// of the files import is assumed to be the standard library package of that name, such
+// as context, and is left out when there is none
+func MethodImports(fset *token.FileSet, files []*ast.File, methods []*ast.FuncDecl) []Import {
+	types := []ast.Node{}
+	for _, method := range methods {
+		types = append(types, method.Type)
+	}
+	referenced := referencedNames(types...)
+
+	imports := []Import{}
+	found := make(map[string]bool)
+	for _, file := range files {
+		dir := filepath.Dir(fset.Position(file.Package).Filename)
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+
+			imp := Import{Path: path}
+			name := ""
+			if spec.Name != nil {
+				imp.Name, name = spec.Name.Name, spec.Name.Name
+			} else {
+				name = packageName(path, dir)
+			}
+
+			if referenced[name] && !found[name] {
+				found[name] = true
+				imports = append(imports, imp)
+			}
+		}
+	}
+
+	// such as the packages of a method synthesized from another package's type
+	for name := range referenced {
+		if found[name] {
+			continue
+		}
+
+		if pkg, err := build.Import(name, "", build.FindOnly); err == nil && pkg.Goroot {
+			imports = append(imports, Import{Path: name})
+		}
+	}
+
+	sort.Slice(imports, func(i, j int) bool { return imports[i].Path < imports[j].Path })
+	return imports
+}
+
+// UsedImports returns the imports of the file referenced by the nodes, sorted by path
+func UsedImports(fset *token.FileSet, file *ast.File, nodes ...ast.Node) []Import {
+	referenced := referencedNames(nodes...)
+
+	imports := []Import{}
+	dir := filepath.Dir(fset.Position(file.Package).Filename)
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		imp := Import{Path: path}
+		name := ""
+		if spec.Name != nil {
+			imp.Name, name = spec.Name.Name, spec.Name.Name
+		} else {
+			name = packageName(path, dir)
+		}
+
+		if referenced[name] {
+			imports = append(imports, imp)
+		}
+	}
+
+	sort.Slice(imports, func(i, j int) bool { return imports[i].Path < imports[j].Path })
+	return imports
+}
+
+// referencedNames returns the names qualifying identifiers in the nodes, such as http in http.Request
+func referencedNames(nodes ...ast.Node) map[string]bool {
+	referenced := make(map[string]bool)
+	for _, node := range nodes {
+		ast.Inspect(node, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if ident, ok := sel.X.(*ast.Ident); ok {
+					referenced[ident.Name] = true
+				}
+			}
+
+			return true
+		})
+	}
+
+	return referenced
+}
+
+// packageName returns the name of the package with the import path as imported from dir.
+// A package that can't be found is assumed to be named after the last element of its
+// path without any version, such as yaml for gopkg.in/yaml.v3 and pgx for github.com/jackc/pgx/v5
+func packageName(path, dir string) string {
+	if pkg, err := build.Import(path, dir, 0); err == nil && pkg.Name != "" {
+		return pkg.Name
+	}
+
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && isMajorVersion(name) {
+		name = elems[len(elems)-2]
+	}
+
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(name, "go-"), "-go")
+}
+
+// isMajorVersion reports whether the path element is a major version suffix such as v2
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+
+	_, err := strconv.Atoi(elem[1:])
+	return err == nil
+}
+
+// AddImport adds an import of the package with the import path to the file
+// and returns the resulting file parsed into fset. A file that already
+// imports the package under the same name is returned as is
+func AddImport(fset *token.FileSet, file *ast.File, path string) (*ast.File, error) {
+	return AddNamedImport(fset, file, "", path)
+}
+
+// AddNamedImport is AddImport renaming the package to name unless name is empty. A package
+// imported under another name is imported again, as methods may refer to it by both names
+func AddNamedImport(fset *token.FileSet, file *ast.File, name, path string) (*ast.File, error) {
+	dir := filepath.Dir(fset.Position(file.Package).Filename)
+	localName := func(name string) string {
+		if name == "" {
+			return packageName(path, dir)
+		}
+		return name
+	}
+
+	for _, spec := range file.Imports {
+		existing, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || existing != path {
+			continue
+		}
+
+		existingName := ""
+		if spec.Name != nil {
+			existingName = spec.Name.Name
+		}
+		if localName(existingName) == localName(name) {
+			return file, nil
+		}
+	}
+
+	file, origSrc, err := source(fset, file)
+	if err != nil {
+		return nil, err
+	}
+
+	// add to the first import declaration or below the package clause when there is none
+	var newSrc string
+	spec := strconv.Quote(path)
+	if name != "" {
+		spec = name + " " + spec
+	}
+	switch decl := firstImportDecl(file); {
+	case decl == nil:
+		at := fset.Position(file.Name.End()).Offset
+		newSrc = origSrc[:at] + "\n\nimport " + spec + origSrc[at:]
+	case decl.Lparen.IsValid():
+		at := fset.Position(decl.Rparen).Offset
+		newSrc = origSrc[:at] + spec + "\n" + origSrc[at:]
+	default:
+		start, end := fset.Position(decl.Specs[0].Pos()).Offset, fset.Position(decl.End()).Offset
+		newSrc = origSrc[:start] + "(\n" + origSrc[start:end] + "\n" + spec + "\n)" + origSrc[end:]
+	}
+
+	filename := fset.Position(file.Package).Filename
+	return ParseFile(fset, filename, []byte(newSrc))
+}
+
+// firstImportDecl returns the file's first import declaration or nil if it has none
+func firstImportDecl(file *ast.File) *ast.GenDecl {
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			return genDecl
+		}
+	}
+
+	return nil
+}
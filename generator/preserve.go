@@ -0,0 +1,316 @@
+package generator
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+)
+
+// PreserveFormatting returns newSrc, the formatted source of a file generated from origSrc,
+// with the declarations it leaves unchanged, and the text between them, as they were written
+// in origSrc. Only what was generated differs from origSrc, even when origSrc isn't gofmt'd.
+// What was generated takes the line endings and byte order mark of origSrc, which gofmt drops.
+// A nil origSrc, a file that doesn't exist yet, returns newSrc as is
+func PreserveFormatting(origSrc, newSrc []byte) ([]byte, error) {
+	if origSrc == nil {
+		return newSrc, nil
+	}
+
+	style := styleOf(origSrc)
+
+	// the generated source is compared to the formatted original to find what changed
+	fmtSrc, err := format.Source(origSrc)
+	if err != nil {
+		return nil, err
+	}
+
+	orig, err := parseSpans(origSrc)
+	if err != nil {
+		return nil, err
+	}
+
+	// most files are formatted already
+	formatted := &spans{src: fmtSrc, decls: orig.decls}
+	if !bytes.Equal(fmtSrc, origSrc) {
+		formatted, err = parseSpans(fmtSrc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	generated, err := parseSpans(newSrc)
+	if err != nil {
+		return nil, err
+	}
+
+	// formatting doesn't add, remove or reorder declarations
+	if len(orig.decls) != len(formatted.decls) {
+		return style.apply(newSrc), nil
+	}
+
+	matches := matchDecls(generated, formatted)
+
+	b := bytes.NewBuffer(style.bom())
+
+	// the package clause and anything else before the first declaration
+	if bytes.Equal(generated.gap(-1), formatted.gap(-1)) {
+		b.Write(bytes.TrimPrefix(orig.gap(-1), byteOrderMark))
+	} else {
+		b.Write(style.lines(generated.gap(-1)))
+	}
+
+	for i := range generated.decls {
+		j, matched := matches[i]
+		if matched {
+			b.Write(orig.decl(j))
+		} else {
+			b.Write(style.lines(generated.decl(i)))
+		}
+
+		// the text up to the next declaration or the end of the file
+		next, nextMatched := matches[i+1]
+		last := i == len(generated.decls)-1
+		sameNeighbours := matched && (last && j == len(formatted.decls)-1 || !last && nextMatched && next == j+1)
+		if sameNeighbours && bytes.Equal(generated.gap(i), formatted.gap(j)) {
+			b.Write(orig.gap(j))
+		} else {
+			b.Write(style.lines(generated.gap(i)))
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// byteOrderMark is the UTF-8 encoding of U+FEFF, which some editors start files with
+var byteOrderMark = []byte("\ufeff")
+
+// sourceStyle is how a file ends its lines and whether it starts with a byte order mark
+type sourceStyle struct {
+	crlf          bool // lines end with \r\n
+	byteOrderMark bool
+}
+
+// styleOf returns the style of src. Its lines end with \r\n when its first line does
+func styleOf(src []byte) sourceStyle {
+	line := src
+	if i := bytes.IndexByte(src, '\n'); i >= 0 {
+		line = src[:i+1]
+	}
+
+	return sourceStyle{
+		crlf:          bytes.HasSuffix(line, []byte("\r\n")),
+		byteOrderMark: bytes.HasPrefix(src, byteOrderMark),
+	}
+}
+
+// bom returns the byte order mark the file starts with, if it does
+func (s sourceStyle) bom() []byte {
+	if !s.byteOrderMark {
+		return nil
+	}
+
+	return append([]byte{}, byteOrderMark...)
+}
+
+// lines returns gofmt'd source with the file's line endings
+func (s sourceStyle) lines(src []byte) []byte {
+	if !s.crlf {
+		return src
+	}
+
+	return bytes.ReplaceAll(src, []byte("\n"), []byte("\r\n"))
+}
+
+// apply returns a whole gofmt'd file in the file's style
+func (s sourceStyle) apply(src []byte) []byte {
+	return append(s.bom(), s.lines(src)...)
+}
+
+// spans is the source of a file split into its top-level declarations
+type spans struct {
+	src   []byte
+	decls [][2]int // the start and end offsets of each declaration
+}
+
+// parseSpans parses src and finds the extent of each of its declarations. A declaration
+// starts at its doc comment and ends at the end of its last line, taking in any comment there
+func parseSpans(src []byte) (*spans, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// offsets are looked up in the file itself, which unlike fset.Position doesn't find their lines
+	tokFile := fset.File(file.Package)
+
+	s := &spans{src: src}
+	for _, decl := range file.Decls {
+		start := decl.Pos()
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+		}
+
+		end := tokFile.Offset(decl.End())
+		if i := bytes.IndexByte(src[end:], '\n'); i >= 0 {
+			end += i
+		} else {
+			end = len(src)
+		}
+
+		s.decls = append(s.decls, [2]int{tokFile.Offset(start), end})
+	}
+
+	// a line shared by several declarations belongs to the first
+	for i := 1; i < len(s.decls); i++ {
+		if s.decls[i][0] < s.decls[i-1][1] {
+			s.decls[i-1][1] = s.decls[i][0]
+		}
+	}
+
+	return s, nil
+}
+
+// decl returns the source of the ith declaration
+func (s *spans) decl(i int) []byte {
+	return s.src[s.decls[i][0]:s.decls[i][1]]
+}
+
+// gap returns the source between the ith declaration and the next one or, after the
+// last declaration, the end of the file. Before the first declaration i is -1
+func (s *spans) gap(i int) []byte {
+	start, end := 0, len(s.src)
+	if i >= 0 {
+		start = s.decls[i][1]
+	}
+
+	if i+1 < len(s.decls) {
+		end = s.decls[i+1][0]
+	}
+
+	return s.src[start:end]
+}
+
+// matchDecls returns the longest common subsequence of the declarations of a and b
+// with the same source, mapping the index of each declaration in a to its match in b
+func matchDecls(a, b *spans) map[int]int {
+	// declarations are compared by number, the same number for the same source
+	ids := make(map[string]int)
+	number := func(s *spans) []int {
+		numbers := make([]int, len(s.decls))
+		for i := range s.decls {
+			src := string(s.decl(i))
+			id, ok := ids[src]
+			if !ok {
+				id = len(ids)
+				ids[src] = id
+			}
+			numbers[i] = id
+		}
+
+		return numbers
+	}
+	x, y := number(a), number(b)
+
+	// most declarations are left as they were, around a few generated ones
+	matches := make(map[int]int)
+	start := 0
+	for start < len(x) && start < len(y) && x[start] == y[start] {
+		matches[start] = start
+		start++
+	}
+
+	endX, endY := len(x), len(y)
+	for endX > start && endY > start && x[endX-1] == y[endY-1] {
+		endX--
+		endY--
+		matches[endX] = endY
+	}
+
+	for i, j := range commonSubsequence(x[start:endX], y[start:endY]) {
+		matches[start+i] = start + j
+	}
+
+	return matches
+}
+
+// commonSubsequence returns the longest common subsequence of x and y, mapping the index
+// of each element of x in it to its index in y. It is found by Myers' algorithm, whose
+// cost grows with the number of differences rather than the lengths of x and y
+func commonSubsequence(x, y []int) map[int]int {
+	n, m := len(x), len(y)
+	offset := n + m + 1
+
+	// furthest[offset+k] is the furthest index of x reached on diagonal k, where the
+	// index of y is that of x minus k, with as many differences as the current step.
+	// Each step's diagonals are kept in trace to walk back through them
+	furthest := make([]int, 2*offset+1)
+	trace := [][]int{}
+	for d := 0; ; d++ {
+		for k := -d; k <= d; k += 2 {
+			i := furthest[offset+k-1] + 1
+			if k == -d || k != d && furthest[offset+k-1] < furthest[offset+k+1] {
+				i = furthest[offset+k+1]
+			}
+
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			furthest[offset+k] = i
+		}
+		trace = append(trace, append([]int{}, furthest[offset-d:offset+d+1]...))
+
+		if furthest[offset+n-m] >= n && (n-m+d)%2 == 0 && n-m >= -d && n-m <= d {
+			break
+		}
+	}
+
+	// walk back from the end, matching the elements along each diagonal
+	matches := make(map[int]int)
+	i, j := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		previous := trace[d-1]
+		at := func(k int) int { return previous[k+d-1] }
+
+		k := i - j
+		prevK := k - 1
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prevK = k + 1
+		}
+
+		// the step leads from the previous point to the start of a diagonal run of matches
+		prevI := at(prevK)
+		prevJ := prevI - prevK
+		runI, runJ := prevI+1, prevJ
+		if prevK == k+1 {
+			runI, runJ = prevI, prevJ+1
+		}
+
+		for i > runI && j > runJ {
+			i--
+			j--
+			matches[i] = j
+		}
+
+		i, j = prevI, prevJ
+	}
+
+	for i > 0 && j > 0 {
+		i--
+		j--
+		matches[i] = j
+	}
+
+	return matches
+}
@@ -0,0 +1,216 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+)
+
+// Unqualifiable is a method that can't be declared by an interface in another package
+type Unqualifiable struct {
+	Method *ast.FuncDecl
+	Ident  string // the method's name when unexported, or the first unexported identifier or cgo type it references
+}
+
+// Qualify returns copies of the methods whose parameter and result types reference the
+// types, and constants, of their package qualified by pkgName so the methods can be
+// declared by an interface in another package. Config becomes pkgName.Config.
+// Unexported methods, which the type can't implement an interface of another package
+// with, and methods referencing unexported identifiers or cgo types are returned separately
+func Qualify(methods []*ast.FuncDecl, pkgName string) ([]*ast.FuncDecl, []Unqualifiable, error) {
+	qualified := []*ast.FuncDecl{}
+	unqualifiable := []Unqualifiable{}
+	for _, method := range methods {
+		if !method.Name.IsExported() {
+			unqualifiable = append(unqualifiable, Unqualifiable{Method: method, Ident: method.Name.Name})
+			continue
+		}
+
+		q := &qualifier{pkgName: pkgName, typeParams: make(map[string]bool)}
+		for _, name := range receiverTypeParams(method) {
+			q.typeParams[name] = true
+		}
+
+		funcType, err := dupFuncType(method.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("method %s: %v", method.Name.Name, err)
+		}
+
+		q.fieldList(funcType.Params)
+		q.fieldList(funcType.Results)
+		if q.unexported != "" {
+			unqualifiable = append(unqualifiable, Unqualifiable{Method: method, Ident: q.unexported})
+			continue
+		}
+
+		copy := *method
+		copy.Type = funcType
+		qualified = append(qualified, &copy)
+	}
+
+	return qualified, unqualifiable, nil
+}
+
+// Declarable returns the methods, whose types are already qualified, that can be declared
+// outside of their package, and those that are unexported or reference unexported
+// identifiers of another package, such as sql.driverConn, separately
+func Declarable(methods []*ast.FuncDecl) ([]*ast.FuncDecl, []Unqualifiable) {
+	declarable := []*ast.FuncDecl{}
+	unqualifiable := []Unqualifiable{}
+	for _, method := range methods {
+		if !method.Name.IsExported() {
+			unqualifiable = append(unqualifiable, Unqualifiable{Method: method, Ident: method.Name.Name})
+			continue
+		}
+
+		if ident := unexportedSelector(method.Type); ident != "" {
+			unqualifiable = append(unqualifiable, Unqualifiable{Method: method, Ident: ident})
+			continue
+		}
+
+		declarable = append(declarable, method)
+	}
+
+	return declarable, unqualifiable
+}
+
+// RenamePackage returns copies of the methods with the identifiers qualified by from,
+// such as sql.DB, qualified by to instead
+func RenamePackage(methods []*ast.FuncDecl, from, to string) ([]*ast.FuncDecl, error) {
+	renamed := []*ast.FuncDecl{}
+	for _, method := range methods {
+		funcType, err := dupFuncType(method.Type)
+		if err != nil {
+			return nil, fmt.Errorf("method %s: %v", method.Name.Name, err)
+		}
+
+		ast.Inspect(funcType, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == from {
+					sel.X = ast.NewIdent(to)
+				}
+			}
+
+			return true
+		})
+
+		copy := *method
+		copy.Type = funcType
+		renamed = append(renamed, &copy)
+	}
+
+	return renamed, nil
+}
+
+// References reports whether any of the methods refers to an identifier qualified by pkgName
+func References(methods []*ast.FuncDecl, pkgName string) bool {
+	for _, method := range methods {
+		found := false
+		ast.Inspect(method.Type, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == pkgName {
+					found = true
+				}
+			}
+
+			return !found
+		})
+
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unexportedSelector returns the first qualified identifier in the node
+// that is unexported, such as sql.driverConn, or "" if there is none
+func unexportedSelector(node ast.Node) string {
+	found := ""
+	ast.Inspect(node, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && found == "" && !sel.Sel.IsExported() {
+			found = types.ExprString(sel)
+		}
+
+		return found == ""
+	})
+
+	return found
+}
+
+// qualifier qualifies the identifiers declared by a package in type expressions
+type qualifier struct {
+	pkgName    string
+	typeParams map[string]bool // the receiver's type parameters, which are left as is
+	unexported string          // the first unexported identifier found
+}
+
+// expr returns the type expression with the package's identifiers qualified.
+// Composite expressions are qualified in place
+func (q *qualifier) expr(expr ast.Expr) ast.Expr {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if q.typeParams[t.Name] || types.Universe.Lookup(t.Name) != nil {
+			return t
+		}
+
+		if !t.IsExported() && q.unexported == "" {
+			q.unexported = t.Name
+		}
+
+		return &ast.SelectorExpr{X: ast.NewIdent(q.pkgName), Sel: t}
+	case *ast.SelectorExpr:
+		// cgo's types belong to the package using them
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "C" && q.unexported == "" {
+			q.unexported = types.ExprString(t)
+		}
+	case *ast.FuncType:
+		q.fieldList(t.Params)
+		q.fieldList(t.Results)
+	case *ast.Ellipsis:
+		t.Elt = q.expr(t.Elt)
+	case *ast.StarExpr:
+		t.X = q.expr(t.X)
+	case *ast.ArrayType:
+		if t.Len != nil {
+			t.Len = q.expr(t.Len)
+		}
+		t.Elt = q.expr(t.Elt)
+	case *ast.MapType:
+		t.Key, t.Value = q.expr(t.Key), q.expr(t.Value)
+	case *ast.ChanType:
+		t.Value = q.expr(t.Value)
+	case *ast.ParenExpr:
+		t.X = q.expr(t.X)
+	case *ast.IndexExpr:
+		t.X, t.Index = q.expr(t.X), q.expr(t.Index)
+	case *ast.IndexListExpr:
+		t.X = q.expr(t.X)
+		for i, index := range t.Indices {
+			t.Indices[i] = q.expr(index)
+		}
+	case *ast.UnaryExpr:
+		t.X = q.expr(t.X)
+	case *ast.BinaryExpr:
+		t.X, t.Y = q.expr(t.X), q.expr(t.Y)
+	case *ast.InterfaceType:
+		q.fieldList(t.Methods)
+	case *ast.StructType:
+		q.fieldList(t.Fields)
+	}
+
+	// already qualified identifiers and literals
+	return expr
+}
+
+// fieldList qualifies the types of the fields in place
+func (q *qualifier) fieldList(fl *ast.FieldList) {
+	if fl == nil {
+		return
+	}
+
+	for _, field := range fl.List {
+		field.Type = q.expr(field.Type)
+	}
+}
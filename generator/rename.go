@@ -0,0 +1,109 @@
+package generator
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// Rename renames the interface named interfaceName declared in files, the files of a single
+// package, to newName along with every reference to it in files, such as fields, parameters
+// and assertions, found by type checking them. The doc comment of the interface is updated
+// when it starts with the interface's name. It returns the files that changed, in the order
+// of files, parsed anew into fset. References in other packages are left as they are
+func Rename(fset *token.FileSet, files []*ast.File, interfaceName, newName string) ([]*ast.File, error) {
+	if !token.IsIdentifier(newName) {
+		return nil, newDiagnostic(CodeWrongKind, newName, "%s is not a valid name", newName)
+	}
+
+	files, srcs, err := sourceFiles(fset, files)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{
+		Importer:    importer.ForCompiler(fset, "source", nil),
+		Error:       func(error) {}, // keep going to resolve as much as possible
+		FakeImportC: true,           // accept the import "C" of cgo files
+	}
+	pkg, _ := conf.Check("", fset, files, info)
+
+	obj, ok := pkg.Scope().Lookup(interfaceName).(*types.TypeName)
+	if !ok {
+		return nil, newDiagnostic(CodeInterfaceNotFound, interfaceName, "interface %s not found", interfaceName)
+	}
+
+	if !types.IsInterface(obj.Type()) {
+		return nil, diagnosticAt(fset, obj.Pos(), CodeWrongKind, interfaceName, "%s is not an interface", interfaceName)
+	}
+
+	if taken := pkg.Scope().Lookup(newName); taken != nil {
+		return nil, diagnosticAt(fset, taken.Pos(), CodeNameTaken, newName, "%s is already declared", newName)
+	}
+
+	// the positions of the identifiers to rename, a reference must
+	// not end up referring to a declaration of the new name in its scope
+	renamed := []token.Pos{}
+	for ident, o := range info.Defs {
+		if o == obj {
+			renamed = append(renamed, ident.Pos())
+		}
+	}
+	for ident, o := range info.Uses {
+		if o != obj {
+			continue
+		}
+
+		if scope := pkg.Scope().Innermost(ident.Pos()); scope != nil {
+			if _, shadow := scope.LookupParent(newName, ident.Pos()); shadow != nil && shadow.Parent() != types.Universe {
+				return nil, diagnosticAt(fset, shadow.Pos(), CodeNameTaken, newName, "%s is declared where %s is referenced", newName, interfaceName)
+			}
+		}
+		renamed = append(renamed, ident.Pos())
+	}
+
+	for _, file := range files {
+		if decl, err := FindInterface(file, interfaceName); err == nil && decl.Doc != nil {
+			if doc := decl.Doc.List[0]; strings.HasPrefix(doc.Text, "// "+interfaceName+" ") {
+				renamed = append(renamed, doc.Slash+token.Pos(len("// ")))
+			}
+		}
+	}
+
+	changed := []*ast.File{}
+	for i, file := range files {
+		tokFile := fset.File(file.Package)
+		offsets := []int{}
+		for _, pos := range renamed {
+			if fset.File(pos) == tokFile {
+				offsets = append(offsets, tokFile.Offset(pos))
+			}
+		}
+
+		if len(offsets) == 0 {
+			continue
+		}
+
+		// renamed from the end so the offsets before stay put
+		sort.Sort(sort.Reverse(sort.IntSlice(offsets)))
+		src := srcs[i]
+		for _, offset := range offsets {
+			src = src[:offset] + newName + src[offset+len(interfaceName):]
+		}
+
+		renamedFile, err := ParseFile(fset, tokFile.Name(), []byte(src))
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, renamedFile)
+	}
+
+	return changed, nil
+}
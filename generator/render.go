@@ -0,0 +1,281 @@
+package generator
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"strings"
+)
+
+// The interface declarations are rendered here rather than by go/printer alone.
+// Generated methods have no position information, and go/printer relies on
+// positions to place comments, so their doc comments would end up in odd places.
+
+// renderInterfaceDecl renders a declaration built by BuildInterface
+func renderInterfaceDecl(decl *ast.GenDecl, fset *token.FileSet) (string, error) {
+	tSpec := decl.Specs[0].(*ast.TypeSpec)
+
+	var b strings.Builder
+	b.WriteString(renderCommentGroup(decl.Doc))
+	b.WriteString("type ")
+
+	spec, err := renderInterfaceSpec(tSpec, fset)
+	if err != nil {
+		return "", err
+	}
+	b.WriteString(spec)
+
+	return b.String(), nil
+}
+
+// renderInterfaceSpec renders the type spec of an interface, the declaration
+// without its type keyword or doc comment as it is written in a group
+func renderInterfaceSpec(tSpec *ast.TypeSpec, fset *token.FileSet) (string, error) {
+	typeParams, err := renderTypeParams(tSpec.TypeParams, fset)
+	if err != nil {
+		return "", err
+	}
+
+	methods, err := renderInterfaceType(tSpec.Type.(*ast.InterfaceType).Methods, fset, "", interior{})
+	if err != nil {
+		return "", err
+	}
+
+	return tSpec.Name.Name + typeParams + " " + methods, nil
+}
+
+// renderInterfaceType renders an interface type with the given methods. Methods
+// from an existing interface in src are copied from it as is, comments included,
+// and the interior of the existing interface is laid out around them as it was
+func renderInterfaceType(methods *ast.FieldList, fset *token.FileSet, src string, in interior) (string, error) {
+	if (methods == nil || len(methods.List) == 0) && in.trailing.comments == "" {
+		return "interface{}", nil
+	}
+
+	gaps := in.gaps(methods)
+
+	var b strings.Builder
+	b.WriteString("interface {\n")
+	prev := -1
+	for i, field := range methods.List {
+		method, err := renderInterfaceMethod(field, fset, src)
+		if err != nil {
+			return "", err
+		}
+
+		// the layout of the existing interface wins over the sections of the type
+		g, existing := gaps[fieldKey(field)]
+		if s, ok := sectionOf(field); ok && !existing {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(renderCommentGroup(s.comment))
+		} else if existing {
+			// a blank line is only kept between methods in the order they were written
+			if i > 0 && g.blank && prev < g.order {
+				b.WriteString("\n")
+			}
+			b.WriteString(g.comments)
+		}
+
+		prev = -1
+		if existing {
+			prev = g.order
+		}
+
+		b.WriteString(method + "\n")
+	}
+
+	if trailing := gaps[""]; trailing.comments != "" {
+		if len(methods.List) > 0 && trailing.blank {
+			b.WriteString("\n")
+		}
+		b.WriteString(trailing.comments)
+	}
+	b.WriteString("}")
+
+	return b.String(), nil
+}
+
+// interior is the layout of an existing interface between its methods
+type interior struct {
+	above    []gap // above each method, in the order they are written
+	trailing gap   // between the last method and the closing brace
+}
+
+// gap is what lies between a method of an interface and the one above it,
+// apart from the method's own comments
+type gap struct {
+	key      string // the method's fieldKey
+	order    int    // the method's index in the interface
+	blank    bool   // a blank line is left above the comments, or above the method without any
+	comments string // comments documenting no method, as written, up to the method
+}
+
+// interiorOf returns the interior of the interface in src
+func interiorOf(iface *ast.InterfaceType, fset *token.FileSet, src string) interior {
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+
+	in := interior{}
+	from := offset(iface.Methods.Opening) + 1
+	for i, field := range iface.Methods.List {
+		start, end := fieldExtent(field)
+		in.above = append(in.above, gapOf(src[from:offset(start)], fieldKey(field), i))
+		from = offset(end)
+	}
+	in.trailing = gapOf(src[from:offset(iface.Methods.Closing)], "", len(iface.Methods.List))
+
+	return in
+}
+
+// gapOf returns the gap of whitespace and comments s
+func gapOf(s string, key string, order int) gap {
+	g := gap{key: key, order: order, blank: strings.Contains(s, "\n\n")}
+	if i := strings.Index(s, "/"); i >= 0 {
+		g.blank = strings.Contains(s[:i], "\n\n")
+		g.comments = strings.TrimRight(s[i:], " \t")
+		if !strings.HasSuffix(g.comments, "\n") {
+			g.comments += "\n"
+		}
+	}
+
+	return g
+}
+
+// gaps returns the gaps above the methods by their fieldKey, the trailing gap by the
+// empty key. The comments and blank line above a method that is gone go to the next method that isn't
+func (in interior) gaps(methods *ast.FieldList) map[string]gap {
+	kept := make(map[string]bool)
+	if methods != nil {
+		for _, field := range methods.List {
+			kept[fieldKey(field)] = true
+		}
+	}
+
+	gaps := make(map[string]gap)
+	orphaned := gap{}
+	for _, g := range append(in.above, in.trailing) {
+		if !kept[g.key] && g.key != "" {
+			orphaned.blank = orphaned.blank || g.blank && orphaned.comments == ""
+			orphaned.comments += g.comments
+			continue
+		}
+
+		g.blank = g.blank || orphaned.blank
+		g.comments = orphaned.comments + g.comments
+		orphaned = gap{}
+		gaps[g.key] = g
+	}
+
+	return gaps
+}
+
+// renderInterfaceMethod renders a method (or embedded interface) of an interface including its comments
+func renderInterfaceMethod(field *ast.Field, fset *token.FileSet, src string) (string, error) {
+	// an existing method
+	if field.Pos().IsValid() {
+		start, end := fieldExtent(field)
+		return src[fset.Position(start).Offset:fset.Position(end).Offset], nil
+	}
+
+	// a generated method
+	typ, err := renderNode(field.Type, fset)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(renderCommentGroup(field.Doc))
+	if len(field.Names) > 0 {
+		b.WriteString(field.Names[0].Name + strings.TrimPrefix(typ, "func"))
+	} else {
+		b.WriteString(typ)
+	}
+
+	if field.Comment != nil {
+		b.WriteString(" " + strings.TrimSuffix(renderCommentGroup(field.Comment), "\n"))
+	}
+
+	return b.String(), nil
+}
+
+// section is the section of the interface a generated method starts
+type section struct {
+	comment *ast.CommentGroup // nil when the section is only set off by a blank line
+}
+
+// sectionOf returns the section a generated method starts
+func sectionOf(field *ast.Field) (section, bool) {
+	if field.Pos().IsValid() || len(field.Names) == 0 || field.Names[0].Obj == nil {
+		return section{}, false
+	}
+
+	s, ok := field.Names[0].Obj.Data.(section)
+	return s, ok
+}
+
+// fieldExtent returns where a method of an existing interface starts
+// and ends, including its doc comment and trailing comment
+func fieldExtent(field *ast.Field) (token.Pos, token.Pos) {
+	start, end := field.Pos(), field.End()
+	if field.Doc != nil {
+		start = field.Doc.Pos()
+	}
+
+	if field.Comment != nil {
+		end = field.Comment.End()
+	}
+
+	return start, end
+}
+
+// renderTypeParams renders a type parameter list such as [K comparable, V any]
+func renderTypeParams(typeParams *ast.FieldList, fset *token.FileSet) (string, error) {
+	if typeParams == nil || len(typeParams.List) == 0 {
+		return "", nil
+	}
+
+	params := []string{}
+	for _, field := range typeParams.List {
+		constraint, err := renderNode(field.Type, fset)
+		if err != nil {
+			return "", err
+		}
+
+		names := []string{}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+
+		params = append(params, strings.Join(names, ", ")+" "+constraint)
+	}
+
+	return "[" + strings.Join(params, ", ") + "]", nil
+}
+
+// renderCommentGroup renders the comments one per line
+func renderCommentGroup(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, c := range cg.List {
+		b.WriteString(c.Text + "\n")
+	}
+
+	return b.String()
+}
+
+// renderNode renders the node using go/format
+func renderNode(node ast.Node, fset *token.FileSet) (string, error) {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, node); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,435 @@
+package generator
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// ReplaceType switches the declarations of files, the files of a single package, from the named
+// type to the named interface, declared in the package, wherever the value declared is only used
+// as the interface: its methods of the interface are called, it's assigned, or it's passed on to
+// declarations switched too or to interfaces it implements. The declarations are the parameters of
+// functions and methods, other than those of the type, the struct fields and the variables declared
+// of a pointer to the type, and the parameters declared of the type itself. A function used as a
+// value keeps its parameters. It returns the files that changed, in the order of files, parsed anew
+// into fset
+func ReplaceType(fset *token.FileSet, files []*ast.File, typeName, interfaceName string) ([]*ast.File, error) {
+	files, srcs, err := sourceFiles(fset, files)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{
+		Importer:    importer.ForCompiler(fset, "source", nil),
+		Error:       func(error) {}, // keep going to resolve as much as possible
+		FakeImportC: true,           // accept the import "C" of cgo files
+	}
+	pkg, _ := conf.Check("", fset, files, info)
+
+	typeObj, ok := pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil, newDiagnostic(CodeTypeNotFound, typeName, "type %s not found", typeName)
+	}
+
+	ifaceObj, ok := pkg.Scope().Lookup(interfaceName).(*types.TypeName)
+	if !ok || !types.IsInterface(ifaceObj.Type()) {
+		return nil, newDiagnostic(CodeInterfaceNotFound, interfaceName, "interface %s not found", interfaceName)
+	}
+
+	if named, ok := ifaceObj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+		return nil, diagnosticAt(fset, ifaceObj.Pos(), CodeGeneric, interfaceName, "cannot switch to generic interface %s", interfaceName)
+	}
+
+	r := &replacer{
+		info:       info,
+		pkg:        pkg,
+		typ:        typeObj.Type(),
+		iface:      ifaceObj.Type(),
+		parents:    make(map[ast.Node]ast.Node),
+		uses:       make(map[types.Object][]*ast.Ident),
+		candidates: make(map[types.Object]*candidate),
+	}
+
+	for ident, obj := range info.Uses {
+		r.uses[obj] = append(r.uses[obj], ident)
+	}
+
+	for _, file := range files {
+		stack := []ast.Node{}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if n == nil {
+				stack = stack[:len(stack)-1]
+				return true
+			}
+
+			if len(stack) > 0 {
+				r.parents[n] = stack[len(stack)-1]
+			}
+			stack = append(stack, n)
+
+			r.declare(n)
+			return true
+		})
+	}
+
+	r.resolve()
+
+	// the declarations switched, by file
+	edits := make(map[*token.File][]ast.Expr)
+	seen := make(map[ast.Expr]bool)
+	for _, c := range r.candidates {
+		if !c.ok || c.typeExpr == nil || seen[c.typeExpr] {
+			continue
+		}
+
+		seen[c.typeExpr] = true
+		tokFile := fset.File(c.typeExpr.Pos())
+		edits[tokFile] = append(edits[tokFile], c.typeExpr)
+	}
+
+	changed := []*ast.File{}
+	for i, file := range files {
+		tokFile := fset.File(file.Package)
+		exprs := edits[tokFile]
+		if len(exprs) == 0 {
+			continue
+		}
+
+		// switched from the end so the offsets before stay put
+		sort.Slice(exprs, func(i, j int) bool { return exprs[i].Pos() > exprs[j].Pos() })
+		src := srcs[i]
+		for _, expr := range exprs {
+			src = src[:tokFile.Offset(expr.Pos())] + interfaceName + src[tokFile.Offset(expr.End()):]
+		}
+
+		switched, err := ParseFile(fset, tokFile.Name(), []byte(src))
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, switched)
+	}
+
+	return changed, nil
+}
+
+// candidate is a declaration that may be switched to the interface
+type candidate struct {
+	typeExpr ast.Expr       // the type of the declaration, nil when it is inferred from its value
+	flows    []types.Object // the declarations the value is passed on to, which must be switched too
+	ok       bool           // whether it's switched
+}
+
+// replacer finds the declarations ReplaceType switches to the interface
+type replacer struct {
+	info       *types.Info
+	pkg        *types.Package
+	typ        types.Type // the named type
+	iface      types.Type
+	parents    map[ast.Node]ast.Node
+	uses       map[types.Object][]*ast.Ident
+	candidates map[types.Object]*candidate
+}
+
+// declare adds the declarations of the node that are of the type or a pointer to it as candidates
+func (r *replacer) declare(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.FuncDecl:
+		// the type's methods are what the interface is generated from
+		if n.Recv != nil && len(n.Recv.List) > 0 {
+			recv := r.info.TypeOf(n.Recv.List[0].Type)
+			if ptr, ok := recv.(*types.Pointer); ok {
+				recv = ptr.Elem()
+			}
+			if recv != nil && types.Identical(recv, r.typ) {
+				return
+			}
+		}
+
+		for _, field := range n.Type.Params.List {
+			r.declareFields(field.Names, field.Type, true)
+		}
+	case *ast.StructType:
+		for _, field := range n.Fields.List {
+			r.declareFields(field.Names, field.Type, false)
+		}
+	case *ast.ValueSpec:
+		if n.Type != nil {
+			r.declareFields(n.Names, n.Type, false)
+		}
+	}
+}
+
+// declareFields adds the names declared of typeExpr as candidates when typeExpr is a
+// pointer to the type or, with value, the type itself and the interface may take its place
+func (r *replacer) declareFields(names []*ast.Ident, typeExpr ast.Expr, value bool) {
+	typ := r.info.TypeOf(typeExpr)
+	if typ == nil || !types.AssignableTo(typ, r.iface) {
+		return
+	}
+
+	ptr, isPtr := typ.(*types.Pointer)
+	switch {
+	case isPtr && types.Identical(ptr.Elem(), r.typ):
+	case value && types.Identical(typ, r.typ):
+	default:
+		return
+	}
+
+	for _, name := range names {
+		if obj := r.info.Defs[name]; obj != nil && name.Name != "_" {
+			r.candidates[obj] = &candidate{typeExpr: typeExpr, ok: true}
+		}
+	}
+}
+
+// resolve decides which candidates are switched, those whose values are only used as the interface
+func (r *replacer) resolve() {
+	// the candidates inferred from others are found along the way
+	checked := make(map[types.Object]bool)
+	for {
+		pending := []types.Object{}
+		for obj := range r.candidates {
+			if !checked[obj] {
+				pending = append(pending, obj)
+			}
+		}
+
+		if len(pending) == 0 {
+			break
+		}
+
+		for _, obj := range pending {
+			checked[obj] = true
+			c := r.candidates[obj]
+			for _, ident := range r.uses[obj] {
+				if !r.usedAsInterface(ident, c) {
+					c.ok = false
+				}
+			}
+		}
+	}
+
+	// a function used as a value must keep its signature
+	for obj, idents := range r.uses {
+		fn, ok := obj.(*types.Func)
+		if !ok {
+			continue
+		}
+
+		for _, ident := range idents {
+			if !r.called(ident) {
+				params := fn.Type().(*types.Signature).Params()
+				for i := 0; i < params.Len(); i++ {
+					if c, ok := r.candidates[params.At(i)]; ok {
+						c.ok = false
+					}
+				}
+			}
+		}
+	}
+
+	// a value passed on to a declaration that isn't switched keeps its type, as do
+	// the other names declared along with a name that keeps its type
+	for changed := true; changed; {
+		changed = false
+		kept := make(map[ast.Expr]bool)
+		for _, c := range r.candidates {
+			if !c.ok && c.typeExpr != nil {
+				kept[c.typeExpr] = true
+			}
+		}
+
+		for _, c := range r.candidates {
+			if !c.ok {
+				continue
+			}
+
+			keep := c.typeExpr != nil && kept[c.typeExpr]
+			for _, to := range c.flows {
+				if target, ok := r.candidates[to]; ok && !target.ok {
+					keep = true
+				}
+			}
+
+			if keep {
+				c.ok, changed = false, true
+			}
+		}
+	}
+}
+
+// usedAsInterface reports whether the use of the candidate c is one the interface allows,
+// recording the declarations its value is passed on to in c
+func (r *replacer) usedAsInterface(ident *ast.Ident, c *candidate) bool {
+	var node ast.Expr = ident
+	if sel, ok := r.parents[ident].(*ast.SelectorExpr); ok && sel.Sel == ident {
+		node = sel // a field is used through its selector
+	}
+
+	parent := r.parents[node]
+	for {
+		paren, ok := parent.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		node, parent = paren, r.parents[paren]
+	}
+
+	switch p := parent.(type) {
+	case *ast.SelectorExpr:
+		// only the methods of the interface are called
+		obj, _, _ := types.LookupFieldOrMethod(r.iface, false, r.pkg, p.Sel.Name)
+		_, isMethod := obj.(*types.Func)
+		return p.X == node && isMethod
+	case *ast.AssignStmt:
+		if p.Tok != token.ASSIGN && p.Tok != token.DEFINE {
+			return false
+		}
+
+		for i, lhs := range p.Lhs {
+			if lhs == node {
+				// it is assigned whatever the interface can hold
+				typ := r.assigned(p.Rhs, len(p.Lhs), i)
+				return typ != nil && types.AssignableTo(typ, r.iface)
+			}
+		}
+
+		for i, rhs := range p.Rhs {
+			if rhs == node && len(p.Lhs) == len(p.Rhs) {
+				return r.flowsTo(r.objectOf(p.Lhs[i]), c)
+			}
+		}
+	case *ast.ValueSpec:
+		for i, value := range p.Values {
+			if value == node && len(p.Names) == len(p.Values) {
+				return r.flowsTo(r.info.Defs[p.Names[i]], c)
+			}
+		}
+	case *ast.KeyValueExpr:
+		// a field is set by a composite literal to whatever the interface can hold
+		if p.Key == node {
+			typ := r.info.TypeOf(p.Value)
+			return typ != nil && types.AssignableTo(typ, r.iface)
+		}
+
+		if key, ok := p.Key.(*ast.Ident); ok && p.Value == node {
+			return r.flowsTo(r.info.Uses[key], c)
+		}
+	case *ast.CallExpr:
+		sig, ok := r.info.TypeOf(p.Fun).(*types.Signature)
+		if !ok {
+			return false // a conversion
+		}
+
+		for i, arg := range p.Args {
+			if arg != node {
+				continue
+			}
+
+			params := sig.Params()
+			if sig.Variadic() && i >= params.Len()-1 {
+				if p.Ellipsis.IsValid() {
+					return false
+				}
+				return r.holds(params.At(params.Len() - 1).Type().(*types.Slice).Elem())
+			}
+
+			return r.flowsTo(params.At(i), c)
+		}
+	}
+
+	return false
+}
+
+// flowsTo reports whether the value of the candidate c may be passed on to the
+// declaration to, which it then depends on, or which can hold the interface
+func (r *replacer) flowsTo(to types.Object, c *candidate) bool {
+	v, ok := to.(*types.Var)
+	if !ok {
+		return false
+	}
+
+	if _, ok := r.candidates[v]; ok {
+		c.flows = append(c.flows, v)
+		return true
+	}
+
+	// a variable declared with the value is inferred to be of the interface
+	if r.inferred(v) {
+		r.candidates[v] = &candidate{ok: true}
+		c.flows = append(c.flows, v)
+		return true
+	}
+
+	return r.holds(v.Type())
+}
+
+// holds reports whether a declaration of type typ, another interface, can hold the interface
+func (r *replacer) holds(typ types.Type) bool {
+	return types.IsInterface(typ) && types.AssignableTo(r.iface, typ)
+}
+
+// inferred reports whether the variable is declared without its type
+func (r *replacer) inferred(v *types.Var) bool {
+	for ident, obj := range r.info.Defs {
+		if obj != v {
+			continue
+		}
+
+		switch p := r.parents[ident].(type) {
+		case *ast.AssignStmt:
+			return p.Tok == token.DEFINE
+		case *ast.ValueSpec:
+			return p.Type == nil
+		}
+	}
+
+	return false
+}
+
+// assigned returns the type of the i-th of n values assigned by rhs
+func (r *replacer) assigned(rhs []ast.Expr, n, i int) types.Type {
+	if len(rhs) == n {
+		return r.info.TypeOf(rhs[i])
+	}
+
+	if tuple, ok := r.info.TypeOf(rhs[0]).(*types.Tuple); ok && i < tuple.Len() {
+		return tuple.At(i).Type()
+	}
+
+	return nil
+}
+
+// objectOf returns the object the expression assigned to refers to, if any
+func (r *replacer) objectOf(expr ast.Expr) types.Object {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if obj := r.info.Defs[expr]; obj != nil {
+			return obj
+		}
+		return r.info.Uses[expr]
+	case *ast.SelectorExpr:
+		return r.info.Uses[expr.Sel]
+	}
+
+	return nil
+}
+
+// called reports whether the use of a function calls it
+func (r *replacer) called(ident *ast.Ident) bool {
+	var node ast.Node = ident
+	if sel, ok := r.parents[ident].(*ast.SelectorExpr); ok && sel.Sel == ident {
+		node = sel
+	}
+
+	call, ok := r.parents[node].(*ast.CallExpr)
+	return ok && call.Fun == node
+}
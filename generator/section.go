@@ -0,0 +1,149 @@
+package generator
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Sections returns the methods that start a section of the methods in files,
+// by name, with the section's comment. A section starts at a comment that
+// documents no declaration, such as
+//
+//	// queries
+//
+//	func (s *Store) Get(id int) Item
+//
+// and, when some of the methods are written one after the other, at a blank
+// line between them, in which case its comment is nil. The methods are
+// expected in the order they are declared in
+func Sections(fset *token.FileSet, files []*ast.File, methods []*ast.FuncDecl) map[string]*ast.CommentGroup {
+	// blank lines only set off sections of one-liners, not every method
+	adjacent := false
+	for i := 1; i < len(methods); i++ {
+		prev, method := methods[i-1], methods[i]
+		if fset.File(prev.Pos()) == fset.File(method.Pos()) && line(fset, declStart(method))-line(fset, prev.End()) == 1 {
+			adjacent = true
+			break
+		}
+	}
+
+	sections := make(map[string]*ast.CommentGroup)
+	var prev *ast.FuncDecl
+	for _, method := range methods {
+		file := fileOf(fset, files, method)
+		if file == nil {
+			prev = method
+			continue
+		}
+
+		// methods left out of the interface may lie between a method and the one
+		// before it, a method of another file is separated from the declaration above it
+		from := file.Name.End()
+		sameFile := prev != nil && fset.File(prev.Pos()) == fset.File(method.Pos())
+		if sameFile {
+			from = prev.End()
+		} else if above := declAbove(file, method); above != nil {
+			from = above.End()
+		}
+
+		if comment := sectionComment(fset, file, from, declStart(method)); comment != nil {
+			sections[method.Name.Name] = comment
+		} else if adjacent && prev != nil && (!sameFile || blankLineBetween(fset, file, from, declStart(method))) {
+			sections[method.Name.Name] = nil
+		}
+
+		prev = method
+	}
+
+	return sections
+}
+
+// fileOf returns the file of files the declaration is in, or nil if it isn't in any of them
+func fileOf(fset *token.FileSet, files []*ast.File, decl ast.Decl) *ast.File {
+	for _, file := range files {
+		if fset.File(file.Package) == fset.File(decl.Pos()) {
+			return file
+		}
+	}
+
+	return nil
+}
+
+// declAbove returns the declaration of the file above decl, or nil if decl comes first
+func declAbove(file *ast.File, decl ast.Decl) ast.Decl {
+	var above ast.Decl
+	for _, d := range file.Decls {
+		if d == decl {
+			break
+		}
+		above = d
+	}
+
+	return above
+}
+
+// sectionComment returns the last comment of the file between from and to
+// that documents no declaration, nor follows one on its last line, or nil if
+// there isn't one. Directives, such as //go:generate, don't start a section
+func sectionComment(fset *token.FileSet, file *ast.File, from, to token.Pos) *ast.CommentGroup {
+	var comment *ast.CommentGroup
+	for _, cg := range file.Comments {
+		if cg.Pos() < from || cg.End() > to || cg.Text() == "" {
+			continue
+		}
+
+		documents := false
+		for _, decl := range file.Decls {
+			inside := decl.Pos() <= cg.Pos() && cg.End() <= decl.End()
+			if inside || declStart(decl) == cg.Pos() || line(fset, decl.End()) == line(fset, cg.Pos()) {
+				documents = true
+				break
+			}
+		}
+
+		if !documents {
+			comment = cg
+		}
+	}
+
+	return comment
+}
+
+// blankLineBetween reports whether a blank line is left between from
+// and to by the declarations of the file in between
+func blankLineBetween(fset *token.FileSet, file *ast.File, from, to token.Pos) bool {
+	end := line(fset, from)
+	for _, decl := range file.Decls {
+		if decl.Pos() < from || decl.End() > to {
+			continue
+		}
+
+		if line(fset, declStart(decl))-end > 1 {
+			return true
+		}
+		end = line(fset, decl.End())
+	}
+
+	return line(fset, to)-end > 1
+}
+
+// declStart returns the position the declaration starts at, including its doc comment
+func declStart(decl ast.Decl) token.Pos {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Doc != nil {
+			return decl.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if decl.Doc != nil {
+			return decl.Doc.Pos()
+		}
+	}
+
+	return decl.Pos()
+}
+
+// line returns the line of the position
+func line(fset *token.FileSet, pos token.Pos) int {
+	return fset.Position(pos).Line
+}
@@ -0,0 +1,245 @@
+package generator
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sync"
+)
+
+// The interface is spliced into the formatted source as text rather than inserted into the ast.
+// go/ast keeps a file's comments in a list ordered by position instead of attaching them to nodes,
+// so a node inserted without positions has its comments printed out of place. astutil.Apply
+// replaces nodes but leaves the comment list as it is, so it doesn't help. Splicing the rendered
+// interface at an offset keeps every comment where it belongs, at the cost of parsing the result
+// once. Doing without the parse needs a decorated ast such as dave/dst, converting each file to
+// and from it, which isn't done here.
+
+// newSourceByAppendingInterface generates new sourcecode by appending the interface to the end of origSrc
+func newSourceByAppendingInterface(interfaceDecl *ast.GenDecl, origSrc string, fset *token.FileSet) (string, error) {
+	iSrc, err := renderInterfaceDecl(interfaceDecl, fset)
+	if err != nil {
+		return "", err
+	}
+
+	return origSrc + "\n" + iSrc + "\n", nil
+}
+
+// newSourceByInsertingInterfaceAboveType generates new sourcecode by inserting the interface
+// into origSrc above the specified type (or the type's comments)
+func newSourceByInsertingInterfaceAboveType(interfaceDecl *ast.GenDecl, aboveType string, file *ast.File, origSrc string, fset *token.FileSet) (string, error) {
+	pos, err := firstLineOfTypeIncludingComments(aboveType, file)
+	if err != nil {
+		return "", err
+	}
+
+	iSrc, err := renderInterfaceDecl(interfaceDecl, fset)
+	if err != nil {
+		return "", err
+	}
+
+	// top-level declarations start their line in formatted source
+	at := fset.Position(pos).Offset
+	return origSrc[:at] + iSrc + "\n\n" + origSrc[at:], nil
+}
+
+// newSourceByInsertingInterfaceIntoGroup generates new sourcecode by inserting the interface into
+// origSrc above typeSpec (or its comments) within the type ( ... ) group declaring it. A type declared
+// on its own is grouped with the interface, its doc comment moving into the group with it. The
+// interface is left unindented for the result to be formatted
+func newSourceByInsertingInterfaceIntoGroup(interfaceDecl *ast.GenDecl, typeSpec *ast.TypeSpec, file *ast.File, origSrc string, fset *token.FileSet) (string, error) {
+	genDecl := findTopLevelGenDeclForTypeSpec(typeSpec, file)
+	if genDecl == nil {
+		return "", newDiagnostic(CodeNotTopLevel, typeSpec.Name.Name, "type %s is not declared at the top level", typeSpec.Name.Name)
+	}
+
+	spec, err := renderInterfaceSpec(interfaceDecl.Specs[0].(*ast.TypeSpec), fset)
+	if err != nil {
+		return "", err
+	}
+	iSrc := renderCommentGroup(interfaceDecl.Doc) + spec
+
+	if genDecl.Lparen.IsValid() {
+		pos := typeSpec.Pos()
+		if typeSpec.Doc != nil {
+			pos = typeSpec.Doc.Pos()
+		}
+
+		// from the start of the line of the type's spec
+		at := fset.Position(pos).Offset
+		for at > 0 && origSrc[at-1] != '\n' {
+			at--
+		}
+
+		return origSrc[:at] + iSrc + "\n\n" + origSrc[at:], nil
+	}
+
+	start := fset.Position(genDecl.Pos()).Offset
+	if genDecl.Doc != nil {
+		start = fset.Position(genDecl.Doc.Pos()).Offset
+	}
+	end := fset.Position(genDecl.End()).Offset
+	if typeSpec.Comment != nil {
+		// the comment on the type's line stays with it
+		end = fset.Position(typeSpec.Comment.End()).Offset
+	}
+	doc := origSrc[start:fset.Position(genDecl.Pos()).Offset]
+	typeSrc := origSrc[fset.Position(typeSpec.Pos()).Offset:end]
+
+	return origSrc[:start] + "type (\n" + iSrc + "\n\n" + doc + typeSrc + "\n)" + origSrc[end:], nil
+}
+
+// newSourceByReplacingInterfaceType generates new sourcecode by replacing the interface type declared by
+// tSpec in origSrc with an interface type with the given methods. When typeParams is given, it is added to
+// the declaration as well. The rest of the declaration, such as its comments, is left untouched, and so are
+// the comments inside the interface that document none of its methods
+func newSourceByReplacingInterfaceType(tSpec *ast.TypeSpec, methods *ast.FieldList, typeParams *ast.FieldList, origSrc string, fset *token.FileSet) (string, error) {
+	iSrc, err := renderInterfaceType(methods, fset, origSrc, interiorOf(tSpec.Type.(*ast.InterfaceType), fset, origSrc))
+	if err != nil {
+		return "", err
+	}
+
+	start := fset.Position(tSpec.Type.Pos()).Offset
+	end := fset.Position(tSpec.Type.End()).Offset
+	newSrc := origSrc[:start] + iSrc + origSrc[end:]
+
+	// the type parameters follow the name which comes before the interface
+	// type so its offset is unaffected by replacing the interface type
+	if typeParams != nil {
+		tpSrc, err := renderTypeParams(typeParams, fset)
+		if err != nil {
+			return "", err
+		}
+
+		at := fset.Position(tSpec.Name.End()).Offset
+		newSrc = newSrc[:at] + tpSrc + newSrc[at:]
+	}
+
+	return newSrc, nil
+}
+
+// source returns the formatted source of the file along with the file whose positions its offsets
+// match: the file itself when it is as ParseFile returned it, or the file parsed anew from its source
+// into fset when it has been edited since, so what's spliced into the source is what the file holds.
+// The source remembered for the file is used while the file still matches it, saving printing the
+// whole file before each splice
+func source(fset *token.FileSet, file *ast.File) (*ast.File, string, error) {
+	if src, tokens, ok := sources.lookup(file); ok {
+		if n, matches := matchesPositions(fset, file, src); matches && n == tokens {
+			return file, src, nil
+		}
+	}
+
+	src, err := renderNode(file, fset)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, matches := matchesPositions(fset, file, src); !matches {
+		filename := fset.Position(file.Package).Filename
+		file, err = parser.ParseFile(fset, filename, src, parser.ParseComments)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	sources.remember(fset, file, src)
+	return file, src, nil
+}
+
+// sources remembers the formatted source of the files parsed or spliced into last
+var sources recentSources
+
+// recentSources are the sources of a few files, the least recently remembered forgotten first.
+// Along with each source is the number of identifiers, literals and comments of the file found
+// at their positions in it, which an edit removing or adding any of them changes
+type recentSources struct {
+	mu      sync.Mutex
+	files   [16]*ast.File
+	sources [16]string
+	tokens  [16]int
+	next    int
+}
+
+// remember remembers the source of the file, whose positions it matches
+func (r *recentSources) remember(fset *token.FileSet, file *ast.File, src string) {
+	tokens, _ := matchesPositions(fset, file, src)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.files[r.next], r.sources[r.next], r.tokens[r.next] = file, src, tokens
+	r.next = (r.next + 1) % len(r.files)
+}
+
+// lookup returns the source of the file and its number of tokens if it is remembered
+func (r *recentSources) lookup(file *ast.File) (string, int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, f := range r.files {
+		if f == file {
+			return r.sources[i], r.tokens[i], true
+		}
+	}
+
+	return "", 0, false
+}
+
+// sourceFiles is source for each of the files of a package, in the order of files
+func sourceFiles(fset *token.FileSet, files []*ast.File) ([]*ast.File, []string, error) {
+	parsed := []*ast.File{}
+	srcs := []string{}
+	for _, file := range files {
+		file, src, err := source(fset, file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		parsed = append(parsed, file)
+		srcs = append(srcs, src)
+	}
+
+	return parsed, srcs, nil
+}
+
+// matchesPositions reports whether the identifiers, literals and comments of the file are
+// found in src at their positions and src is as long as the file was, which an edit of
+// the file since it was parsed, such as renaming a method, leaves untrue. It returns how
+// many were found
+func matchesPositions(fset *token.FileSet, file *ast.File, src string) (int, bool) {
+	tokFile := fset.File(file.Package)
+	if tokFile == nil || tokFile.Size() != len(src) {
+		return 0, false
+	}
+
+	n := 0
+	at := func(pos token.Pos, text string) bool {
+		if !pos.IsValid() || fset.File(pos) != tokFile {
+			return false
+		}
+
+		n++
+		offset := tokFile.Offset(pos)
+		return offset+len(text) <= len(src) && src[offset:offset+len(text)] == text
+	}
+
+	matches := true
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			matches = matches && at(n.Pos(), n.Name)
+		case *ast.BasicLit:
+			matches = matches && at(n.Pos(), n.Value)
+		}
+		return matches
+	})
+
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			matches = matches && at(c.Pos(), c.Text)
+		}
+	}
+
+	return n, matches
+}
@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// stdInterface is a well-known interface of the standard library
+type stdInterface struct {
+	path    string            // import path of the package, empty for predeclared interfaces
+	name    string            // the interface as embedded, such as io.Reader
+	methods map[string]string // the methods' signatures keyed by name
+}
+
+// stdInterfaces are the interfaces recognized by EmbedStd. Larger interfaces
+// come first so io.ReadCloser is preferred over io.Reader and io.Closer
+var stdInterfaces = []stdInterface{
+	{"io", "io.ReadWriteCloser", map[string]string{"Read": "([]byte) (int, error)", "Write": "([]byte) (int, error)", "Close": "() error"}},
+	{"io", "io.ReadWriteSeeker", map[string]string{"Read": "([]byte) (int, error)", "Write": "([]byte) (int, error)", "Seek": "(int64, int) (int64, error)"}},
+	{"io", "io.ReadSeekCloser", map[string]string{"Read": "([]byte) (int, error)", "Seek": "(int64, int) (int64, error)", "Close": "() error"}},
+	{"io", "io.ReadCloser", map[string]string{"Read": "([]byte) (int, error)", "Close": "() error"}},
+	{"io", "io.WriteCloser", map[string]string{"Write": "([]byte) (int, error)", "Close": "() error"}},
+	{"io", "io.ReadWriter", map[string]string{"Read": "([]byte) (int, error)", "Write": "([]byte) (int, error)"}},
+	{"io", "io.ReadSeeker", map[string]string{"Read": "([]byte) (int, error)", "Seek": "(int64, int) (int64, error)"}},
+	{"io", "io.WriteSeeker", map[string]string{"Write": "([]byte) (int, error)", "Seek": "(int64, int) (int64, error)"}},
+	{"io", "io.ByteScanner", map[string]string{"ReadByte": "() (byte, error)", "UnreadByte": "() error"}},
+	{"io", "io.RuneScanner", map[string]string{"ReadRune": "() (rune, int, error)", "UnreadRune": "() error"}},
+	{"sort", "sort.Interface", map[string]string{"Len": "() int", "Less": "(int, int) bool", "Swap": "(int, int)"}},
+	{"flag", "flag.Value", map[string]string{"String": "() string", "Set": "(string) error"}},
+	{"io", "io.Reader", map[string]string{"Read": "([]byte) (int, error)"}},
+	{"io", "io.Writer", map[string]string{"Write": "([]byte) (int, error)"}},
+	{"io", "io.Closer", map[string]string{"Close": "() error"}},
+	{"io", "io.Seeker", map[string]string{"Seek": "(int64, int) (int64, error)"}},
+	{"io", "io.ReaderAt", map[string]string{"ReadAt": "([]byte, int64) (int, error)"}},
+	{"io", "io.WriterAt", map[string]string{"WriteAt": "([]byte, int64) (int, error)"}},
+	{"io", "io.ReaderFrom", map[string]string{"ReadFrom": "(io.Reader) (int64, error)"}},
+	{"io", "io.WriterTo", map[string]string{"WriteTo": "(io.Writer) (int64, error)"}},
+	{"io", "io.ByteReader", map[string]string{"ReadByte": "() (byte, error)"}},
+	{"io", "io.ByteWriter", map[string]string{"WriteByte": "(byte) error"}},
+	{"io", "io.RuneReader", map[string]string{"ReadRune": "() (rune, int, error)"}},
+	{"io", "io.StringWriter", map[string]string{"WriteString": "(string) (int, error)"}},
+	{"net/http", "http.Handler", map[string]string{"ServeHTTP": "(http.ResponseWriter, *http.Request)"}},
+	{"encoding/json", "json.Marshaler", map[string]string{"MarshalJSON": "() ([]byte, error)"}},
+	{"encoding/json", "json.Unmarshaler", map[string]string{"UnmarshalJSON": "([]byte) error"}},
+	{"encoding", "encoding.TextMarshaler", map[string]string{"MarshalText": "() ([]byte, error)"}},
+	{"encoding", "encoding.TextUnmarshaler", map[string]string{"UnmarshalText": "([]byte) error"}},
+	{"encoding", "encoding.BinaryMarshaler", map[string]string{"MarshalBinary": "() ([]byte, error)"}},
+	{"encoding", "encoding.BinaryUnmarshaler", map[string]string{"UnmarshalBinary": "([]byte) error"}},
+	{"fmt", "fmt.Stringer", map[string]string{"String": "() string"}},
+	{"", "error", map[string]string{"Error": "() string"}},
+}
+
+// Embed is an interface to embed and the import path of its package,
+// empty for predeclared interfaces
+type Embed struct {
+	Path string
+	Name string // such as io.Reader
+}
+
+// EmbedStd finds the well-known standard library interfaces, such as io.Reader
+// or fmt.Stringer, whose methods are all among methods. It returns the interfaces
+// to embed in place of those methods and the methods that remain
+func EmbedStd(methods []*ast.FuncDecl) ([]Embed, []*ast.FuncDecl) {
+	available := make(map[string]string)
+	for _, method := range methods {
+		available[method.Name.Name] = signature(method)
+	}
+
+	embeds := []Embed{}
+	for _, std := range stdInterfaces {
+		matches := true
+		for name, sig := range std.methods {
+			if available[name] != sig {
+				matches = false
+				break
+			}
+		}
+
+		if !matches {
+			continue
+		}
+
+		for name := range std.methods {
+			delete(available, name)
+		}
+
+		embeds = append(embeds, Embed{Path: std.path, Name: std.name})
+	}
+
+	rest := Filter(methods, func(method *ast.FuncDecl) bool {
+		_, ok := available[method.Name.Name]
+		return ok
+	})
+
+	return embeds, rest
+}
+
+// embedExpr returns the expression of an embedded interface such as io.Reader
+func embedExpr(name string) ast.Expr {
+	if i := strings.Index(name, "."); i >= 0 {
+		return &ast.SelectorExpr{X: ast.NewIdent(name[:i]), Sel: ast.NewIdent(name[i+1:])}
+	}
+
+	return ast.NewIdent(name)
+}
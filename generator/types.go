@@ -0,0 +1,206 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// ResolveOptions control how ResolveMethods resolves the methods of a type
+type ResolveOptions struct {
+	Promoted bool // include the methods promoted from embedded fields
+	Value    bool // resolve the method set of T instead of *T, leaving out pointer receiver methods
+
+	Checker *Checker // type checks the package along with those of other calls, nil to check it on its own
+}
+
+// ResolveMethods returns the methods of the named type by type checking files,
+// the files of a single package. Unlike ExtractMethods, receivers and aliases are
+// resolved the way the compiler resolves them so the type may also be an alias of
+// a type declared in another package. Errors elsewhere in the package are ignored
+// as long as the type itself can be resolved.
+//
+// Promoted methods follow the type's own methods. Those not declared in files,
+// such as the methods of embedded interfaces or of types from other packages,
+// are synthesized from their signatures.
+func ResolveMethods(fset *token.FileSet, files []*ast.File, typeName string, opts ResolveOptions) ([]*ast.FuncDecl, error) {
+	pkg, named, err := lookupNamed(fset, files, typeName, opts.Checker)
+	if err != nil {
+		return nil, err
+	}
+
+	// the method set of *T includes all of the methods of T while the method
+	// set of T leaves out the methods with pointer receivers, including those
+	// promoted from embedded values
+	var recv types.Type = types.NewPointer(named)
+	if opts.Value || types.IsInterface(named) {
+		recv = named
+	}
+
+	mset := types.NewMethodSet(recv)
+	decls := funcDeclsByPos(files)
+
+	methods := []*ast.FuncDecl{}
+	for i := 0; i < named.NumMethods(); i++ {
+		fn := named.Method(i)
+		if mset.Lookup(fn.Pkg(), fn.Name()) == nil {
+			continue
+		}
+
+		if decl, ok := decls[fn.Pos()]; ok {
+			methods = append(methods, decl)
+			continue
+		}
+
+		// the type is an alias of a type declared in another package
+		if fn.Pkg() != pkg && fn.Exported() {
+			decl, err := synthesizeFuncDecl(fn, pkg)
+			if err != nil {
+				return nil, err
+			}
+
+			methods = append(methods, decl)
+		}
+	}
+
+	if !opts.Promoted {
+		return methods, nil
+	}
+
+	for i := 0; i < mset.Len(); i++ {
+		sel := mset.At(i)
+		fn := sel.Obj().(*types.Func)
+		if len(sel.Index()) == 1 || !fn.Exported() && fn.Pkg() != pkg {
+			continue // declared on the type itself or inaccessible
+		}
+
+		if decl, ok := decls[fn.Pos()]; ok {
+			methods = append(methods, decl)
+			continue
+		}
+
+		decl, err := synthesizeFuncDecl(fn, pkg)
+		if err != nil {
+			return nil, err
+		}
+
+		methods = append(methods, decl)
+	}
+
+	return methods, nil
+}
+
+// synthesizeFuncDecl returns a declaration of the method fn without a receiver or body.
+// Types from packages other than pkg are qualified by their package names
+func synthesizeFuncDecl(fn *types.Func, pkg *types.Package) (*ast.FuncDecl, error) {
+	qualifier := func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+
+		return other.Name()
+	}
+
+	expr, err := parser.ParseExpr(types.TypeString(fn.Type(), qualifier))
+	if err != nil {
+		return nil, fmt.Errorf("method %s: %v", fn.Name(), err)
+	}
+
+	return &ast.FuncDecl{Name: ast.NewIdent(fn.Name()), Type: expr.(*ast.FuncType)}, nil
+}
+
+// lookupNamed type checks files with the checker, which may be nil, and returns
+// the package and the named type declared in it
+func lookupNamed(fset *token.FileSet, files []*ast.File, typeName string, checker *Checker) (*types.Package, *types.Named, error) {
+	pkg := checker.check(fset, files)
+
+	found := pkg.Scope().Lookup(typeName)
+	if found == nil {
+		return nil, nil, newDiagnostic(CodeTypeNotFound, typeName, "type %s not found", typeName)
+	}
+
+	obj, ok := found.(*types.TypeName)
+	if !ok {
+		return nil, nil, diagnosticAt(fset, found.Pos(), CodeWrongKind, typeName, "%s is not a type", typeName)
+	}
+
+	named, ok := types.Unalias(obj.Type()).(*types.Named)
+	if !ok {
+		return nil, nil, diagnosticAt(fset, obj.Pos(), CodeWrongKind, typeName, "%s is not a named type", typeName)
+	}
+
+	return pkg, named, nil
+}
+
+// funcDeclsByPos returns the function declarations of files keyed by the position of their names
+func funcDeclsByPos(files []*ast.File) map[token.Pos]*ast.FuncDecl {
+	decls := make(map[token.Pos]*ast.FuncDecl)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+				decls[funcDecl.Name.Pos()] = funcDecl
+			}
+		}
+	}
+
+	return decls
+}
+
+// UsedMethods returns the names of the methods of the named type that are called
+// by files, the files of a consuming package, or only by the function or method
+// named funcName when it isn't empty. The type is matched by its name and the
+// name of the package declaring it, pkgName
+func UsedMethods(fset *token.FileSet, files []*ast.File, pkgName, typeName, funcName string) (map[string]bool, error) {
+	conf := types.Config{
+		Importer:    importer.ForCompiler(fset, "source", nil),
+		Error:       func(error) {}, // keep going to resolve as much as possible
+		FakeImportC: true,           // accept the import "C" of cgo files
+	}
+
+	info := &types.Info{Selections: make(map[*ast.SelectorExpr]*types.Selection)}
+	conf.Check("", fset, files, info)
+
+	// limit the search to the function's body
+	var within ast.Node
+	if funcName != "" {
+		for _, file := range files {
+			for _, decl := range file.Decls {
+				if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Name.Name == funcName {
+					within = funcDecl
+				}
+			}
+		}
+
+		if within == nil {
+			return nil, newDiagnostic(CodeFuncNotFound, funcName, "function %s not found", funcName)
+		}
+	}
+
+	used := make(map[string]bool)
+	for expr, sel := range info.Selections {
+		if sel.Kind() == types.FieldVal {
+			continue
+		}
+
+		if within != nil && (expr.Pos() < within.Pos() || expr.End() > within.End()) {
+			continue
+		}
+
+		recv := sel.Recv()
+		if ptr, ok := recv.(*types.Pointer); ok {
+			recv = ptr.Elem()
+		}
+
+		named, ok := types.Unalias(recv).(*types.Named)
+		if !ok || named.Obj().Name() != typeName || named.Obj().Pkg() == nil || named.Obj().Pkg().Name() != pkgName {
+			continue
+		}
+
+		used[sel.Obj().Name()] = true
+	}
+
+	return used, nil
+}
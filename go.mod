@@ -0,0 +1,10 @@
+module github.com/hankjacobs/gointerfacegen
+
+go 1.26.0
+
+require golang.org/x/tools v0.50.0
+
+require (
+	github.com/jackc/pgx/v5 v5.11.0 // indirect
+	golang.org/x/sync v0.23.0 // indirect
+)
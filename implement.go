@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// runMock runs the mock subcommand, generating a mock implementation of an interface
+func runMock(args []string) error {
+	flags := newFlagSet("mock")
+	nameFlag := flags.String("name", "", "Name of the mock type. Defaults to Mock followed by the interface name")
+	outputFlag := flags.String("o", "", "Write the mock to this file instead of standard out")
+	pkgFlag := flags.String("pkg", "", "Package of the generated file. Defaults to the interface's package")
+	testFlag := flags.Bool("test", false, "Write to a _test.go file, by default named after the file and mock, keeping it out of the production build")
+	parseFlags(flags, args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: gointerfacegen mock [-name type] [-o file] [-pkg name] [-test] <interface> <file>")
+	}
+	interfaceName, filename := flags.Arg(0), flags.Arg(1)
+
+	output, err := outputFile(*outputFlag, *testFlag, filename, "mock")
+	if err != nil {
+		return err
+	}
+
+	impl, err := resolveInterface(interfaceName, filename, *pkgFlag)
+	if err != nil {
+		return err
+	}
+
+	name := *nameFlag
+	if name == "" {
+		name = "Mock" + generator.UpperFirst(interfaceName)
+	}
+
+	src, err := impl.Source(generatedHeader, impl.Mock(name))
+	if err != nil {
+		return err
+	}
+
+	return writeOrPrint(output, src)
+}
+
+// runStub runs the stub subcommand, generating a skeleton implementation of an interface
+func runStub(args []string) error {
+	flags := newFlagSet("stub")
+	outputFlag := flags.String("o", "", "Write the stub to this new file instead of standard out. An existing file is never overwritten")
+	pkgFlag := flags.String("pkg", "", "Package of the generated file. Defaults to the interface's package")
+	testFlag := flags.Bool("test", false, "Write to a _test.go file, by default named after the file and stub, keeping it out of the production build")
+	parseFlags(flags, args)
+
+	if flags.NArg() != 3 {
+		return fmt.Errorf("usage: gointerfacegen stub [-o file] [-pkg name] [-test] <interface> <type> <file>")
+	}
+	interfaceName, typeName, filename := flags.Arg(0), flags.Arg(1), flags.Arg(2)
+
+	output, err := outputFile(*outputFlag, *testFlag, filename, "stub")
+	if err != nil {
+		return err
+	}
+
+	// the stub is filled in by hand afterwards so don't throw that work away
+	if output != "" {
+		if _, err := os.Stat(output); err == nil {
+			return fmt.Errorf("%s already exists", output)
+		}
+	}
+
+	impl, err := resolveInterface(interfaceName, filename, *pkgFlag)
+	if err != nil {
+		return err
+	}
+
+	src, err := impl.Source("", impl.Stub(typeName))
+	if err != nil {
+		return err
+	}
+
+	return writeOrPrint(output, src)
+}
+
+// writeNoop writes a no-op implementation of the interface declared in file, which
+// replaces the file named filename in its package, to the configured file
+func writeNoop(fset *token.FileSet, file *ast.File, filename string, c config) error {
+	files, err := parsePackage(fset, file, filename, c.overlay)
+	if err != nil {
+		return err
+	}
+
+	impl, err := generator.ResolveInterface(fset, files, c.interfaceName, generator.ImplOptions{})
+	if err != nil {
+		return err
+	}
+
+	header, err := newFileHeader(c, file.Name.Name, c.noopFilename)
+	if err != nil {
+		return err
+	}
+
+	src, err := impl.Source(header, impl.Noop("Noop"+generator.UpperFirst(c.interfaceName)))
+	if err != nil {
+		return err
+	}
+
+	return writeFile(c.noopFilename, src, c.backup)
+}
+
+// runSpy runs the spy subcommand, generating an implementation of an interface that records its calls
+func runSpy(args []string) error {
+	flags := newFlagSet("spy")
+	nameFlag := flags.String("name", "", "Name of the spy type. Defaults to Spy followed by the interface name")
+	outputFlag := flags.String("o", "", "Write the spy to this file instead of standard out")
+	pkgFlag := flags.String("pkg", "", "Package of the generated file. Defaults to the interface's package")
+	testFlag := flags.Bool("test", false, "Write to a _test.go file, by default named after the file and spy, keeping it out of the production build")
+	parseFlags(flags, args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: gointerfacegen spy [-name type] [-o file] [-pkg name] [-test] <interface> <file>")
+	}
+	interfaceName, filename := flags.Arg(0), flags.Arg(1)
+
+	output, err := outputFile(*outputFlag, *testFlag, filename, "spy")
+	if err != nil {
+		return err
+	}
+
+	impl, err := resolveInterface(interfaceName, filename, *pkgFlag)
+	if err != nil {
+		return err
+	}
+
+	name := *nameFlag
+	if name == "" {
+		name = "Spy" + generator.UpperFirst(interfaceName)
+	}
+
+	src, err := impl.Source(generatedHeader, impl.Spy(name))
+	if err != nil {
+		return err
+	}
+
+	return writeOrPrint(output, src)
+}
+
+// runDecorator runs the decorator subcommand, generating a type that wraps an
+// implementation of an interface and passes every call on to it
+func runDecorator(args []string) error {
+	flags := newFlagSet("decorator")
+	outputFlag := flags.String("o", "", "Write the decorator to this new file instead of standard out. An existing file is never overwritten")
+	pkgFlag := flags.String("pkg", "", "Package of the generated file. Defaults to the interface's package")
+	testFlag := flags.Bool("test", false, "Write to a _test.go file, by default named after the file and decorator, keeping it out of the production build")
+	parseFlags(flags, args)
+
+	if flags.NArg() != 3 {
+		return fmt.Errorf("usage: gointerfacegen decorator [-o file] [-pkg name] [-test] <interface> <type> <file>")
+	}
+	interfaceName, typeName, filename := flags.Arg(0), flags.Arg(1), flags.Arg(2)
+
+	output, err := outputFile(*outputFlag, *testFlag, filename, "decorator")
+	if err != nil {
+		return err
+	}
+
+	// the methods of interest are overridden by hand afterwards
+	if output != "" {
+		if _, err := os.Stat(output); err == nil {
+			return fmt.Errorf("%s already exists", output)
+		}
+	}
+
+	impl, err := resolveInterface(interfaceName, filename, *pkgFlag)
+	if err != nil {
+		return err
+	}
+
+	src, err := impl.Source("", impl.Decorator(typeName))
+	if err != nil {
+		return err
+	}
+
+	return writeOrPrint(output, src)
+}
+
+// resolveInterface resolves the named interface declared in the package of the file.
+// When generating into another package the interface's package is imported
+func resolveInterface(interfaceName, filename, pkgName string) (*generator.Impl, error) {
+	srcBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	file, err := generator.ParseFile(fset, filename, srcBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := parsePackage(fset, file, filename, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := generator.ImplOptions{Package: pkgName}
+	if pkgName != "" && pkgName != file.Name.Name {
+		opts.ImportPath, err = importPath(filepath.Dir(filename))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return generator.ResolveInterface(fset, files, interfaceName, opts)
+}
+
+// importPath returns the import path of the package in dir
+func importPath(dir string) (string, error) {
+	cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}")
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("finding the import path of %s: %v", dir, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// outputFile returns the file generated code of the kind, such as mock, is written to. With
+// test, the code is kept out of the production build in a _test.go file, by default named
+// after the interface's file and the kind: store.go's mock goes to store_mock_test.go
+func outputFile(output string, test bool, filename, kind string) (string, error) {
+	if !test {
+		return output, nil
+	}
+
+	if output == "" {
+		if kind != "" {
+			kind = "_" + kind
+		}
+
+		return strings.TrimSuffix(filename, ".go") + kind + "_test.go", nil
+	}
+
+	if !strings.HasSuffix(output, "_test.go") {
+		return "", fmt.Errorf("-test requires the output file to be a _test.go file, not %s", output)
+	}
+
+	return output, nil
+}
+
+// writeOrPrint writes src to the file or prints it to standard out when there is no file
+func writeOrPrint(filename string, src []byte) error {
+	if filename == "" {
+		_, err := os.Stdout.Write(src)
+		return err
+	}
+
+	return writeFile(filename, src, false)
+}
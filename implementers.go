@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// runImplementers runs the implementers subcommand, listing the types that implement an interface
+func runImplementers(args []string) error {
+	flags := newFlagSet("implementers")
+	nearFlag := flags.Int("near", 1, "Also list the types missing no more than this many of the interface's methods and why")
+	parseFlags(flags, args)
+
+	if flags.NArg() < 2 {
+		return fmt.Errorf("usage: gointerfacegen implementers [-near n] <interface> <file> [packages]")
+	}
+	interfaceName, filename := flags.Arg(0), flags.Arg(1)
+
+	// a relative directory is a package pattern only when it starts with ./
+	patterns := flags.Args()[2:]
+	if len(patterns) == 0 {
+		dir := filepath.Dir(filename)
+		if !filepath.IsAbs(dir) {
+			dir = "./" + filepath.ToSlash(dir)
+		}
+		patterns = []string{dir}
+	}
+
+	ifacePath, err := importPath(filepath.Dir(filename))
+	if err != nil {
+		return err
+	}
+
+	dirs, err := listPackages(patterns)
+	if err != nil {
+		return err
+	}
+
+	fset := token.NewFileSet()
+	pkgs := []generator.Package{}
+	for _, path := range sortedKeys(dirs) {
+		files, err := parseDir(fset, dirs[path], nil, "")
+		if err != nil {
+			return err
+		}
+
+		pkgs = append(pkgs, generator.Package{Path: path, Files: files})
+	}
+
+	implementers, err := generator.Implementers(fset, pkgs, ifacePath, interfaceName, *nearFlag)
+	if err != nil {
+		return err
+	}
+
+	for _, implementer := range implementers {
+		if len(implementer.Missing) == 0 {
+			fmt.Println(implementer.Type)
+			continue
+		}
+
+		fmt.Printf("%s (near miss)\n", implementer.Type)
+		for _, reason := range implementer.Missing {
+			fmt.Printf("\t%s\n", reason)
+		}
+	}
+
+	return nil
+}
+
+// listPackages returns the directories of the packages matching the
+// patterns, such as ./..., keyed by their import paths
+func listPackages(patterns []string) (map[string]string, error) {
+	cmd := exec.Command("go", append([]string{"list", "-f", "{{.ImportPath}} {{.Dir}}"}, patterns...)...)
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing packages %s: %v", strings.Join(patterns, " "), err)
+	}
+
+	dirs := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) == 2 {
+			dirs[fields[0]] = fields[1]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no packages match %s", strings.Join(patterns, " "))
+	}
+
+	return dirs, nil
+}
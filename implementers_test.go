@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunImplementersRelativeFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go.mod": "module example.com\n",
+		"d/d.go": `package d
+
+type Storer interface {
+	Get() int
+}
+
+type Store struct{}
+
+func (s *Store) Get() int { return 0 }
+
+type Cache struct{}
+`,
+	})
+	t.Chdir(dir)
+
+	got, err := captureStdout(t, func() error {
+		return runImplementers([]string{"Storer", "d/d.go"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the type is qualified by its import path, which depends on how the go command is set up
+	if !strings.HasPrefix(got, "*") || !strings.HasSuffix(got, "/d.Store\n") || strings.Count(got, "\n") != 1 {
+		t.Errorf("got %q, want *<import path>/d.Store", got)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// selectMethods lists the methods of the type with checkboxes, all checked, on out and reads
+// the numbers of those to toggle from in until an empty line confirms the selection, such as
+//
+//	Methods of Store:
+//	  1 [x] Get(id string) (*User, error)
+//	  2 [ ] Put(u *User) error
+//	Toggle by number or range (1 3-5), a for all, n for none, enter to generate, q to quit:
+func selectMethods(in io.Reader, out io.Writer, typeName string, methods []*ast.FuncDecl) ([]*ast.FuncDecl, error) {
+	selected := make([]bool, len(methods))
+	for i := range selected {
+		selected[i] = true
+	}
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(out, "Methods of %s:\n", typeName)
+		for i, method := range methods {
+			check := " "
+			if selected[i] {
+				check = "x"
+			}
+
+			signature := strings.TrimPrefix(types.ExprString(method.Type), "func")
+			fmt.Fprintf(out, "%3d [%s] %s%s\n", i+1, check, method.Name.Name, signature)
+		}
+		fmt.Fprint(out, "Toggle by number or range (1 3-5), a for all, n for none, enter to generate, q to quit: ")
+
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+
+			return nil, fmt.Errorf("-interactive: no selection was confirmed")
+		}
+
+		answer := strings.TrimSpace(scanner.Text())
+		switch answer {
+		case "":
+			chosen := []*ast.FuncDecl{}
+			for i, method := range methods {
+				if selected[i] {
+					chosen = append(chosen, method)
+				}
+			}
+
+			return chosen, nil
+		case "q":
+			return nil, fmt.Errorf("-interactive: quit without generating")
+		case "a", "n":
+			for i := range selected {
+				selected[i] = answer == "a"
+			}
+			continue
+		}
+
+		toggled, err := methodRanges(answer, len(methods))
+		if err != nil {
+			fmt.Fprintf(out, "%v\n", err)
+			continue
+		}
+
+		for _, i := range toggled {
+			selected[i-1] = !selected[i-1]
+		}
+	}
+}
+
+// methodRanges returns the numbers of the methods in every method or range of methods of
+// the answer, separated by commas or spaces, or an error for the first that isn't valid
+func methodRanges(answer string, n int) ([]int, error) {
+	var numbers []int
+	for _, field := range strings.Fields(strings.Replace(answer, ",", " ", -1)) {
+		from, to, err := methodRange(field, n)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := from; i <= to; i++ {
+			numbers = append(numbers, i)
+		}
+	}
+
+	return numbers, nil
+}
+
+// methodRange returns the first and last numbers of a method or range of methods,
+// such as 3 or 3-5, out of n methods numbered from 1
+func methodRange(field string, n int) (int, int, error) {
+	first, last := field, field
+	if i := strings.Index(field, "-"); i >= 0 {
+		first, last = field[:i], field[i+1:]
+	}
+
+	from, err := strconv.Atoi(first)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q is not a number or range of numbers", field)
+	}
+
+	to, err := strconv.Atoi(last)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q is not a number or range of numbers", field)
+	}
+
+	if from < 1 || to > n || from > to {
+		return 0, 0, fmt.Errorf("%s is not between 1 and %d", field, n)
+	}
+
+	return from, to, nil
+}
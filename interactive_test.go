@@ -0,0 +1,48 @@
+package main
+
+import (
+	"go/ast"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectMethods(t *testing.T) {
+	methods := []*ast.FuncDecl{}
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		methods = append(methods, &ast.FuncDecl{Name: ast.NewIdent(name), Type: &ast.FuncType{Params: &ast.FieldList{}}})
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"all by default", "\n", []string{"A", "B", "C", "D", "E"}},
+		{"toggle", "1,3 5\n\n", []string{"B", "D"}},
+		{"range", "n\n2-4\n\n", []string{"B", "C", "D"}},
+		{"toggled twice", "2\n2\n\n", []string{"A", "B", "C", "D", "E"}},
+		{"out of range leaves the line unapplied", "1,3 7\n\n", []string{"A", "B", "C", "D", "E"}},
+		{"not a number leaves the line unapplied", "n\n1 x\n\n", []string{}},
+		{"backwards range leaves the line unapplied", "2 4-3\n\n", []string{"A", "B", "C", "D", "E"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chosen, err := selectMethods(strings.NewReader(test.input), io.Discard, "Store", methods)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names := []string{}
+			for _, method := range chosen {
+				names = append(names, method.Name.Name)
+			}
+
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("chose %v, want %v", names, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// Package diff produces unified diffs of text files.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change
+const context = 3
+
+// op is a single line of an edit script turning old into new
+type op struct {
+	kind byte // ' ' for unchanged, '-' for removed and '+' for added lines
+	text string
+}
+
+// Unified returns a unified diff turning old into new, suitable for
+// patch or git apply, or nil if they are equal. A file that does not
+// exist should be named /dev/null
+func Unified(oldName, newName string, old, new []byte) []byte {
+	if bytes.Equal(old, new) {
+		return nil
+	}
+
+	ops := edits(splitLines(old), splitLines(new))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	// line numbers of ops[i] in old and new
+	oldLine, newLine := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	oldLine[0], newLine[0] = 1, 1
+	for i, o := range ops {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if o.kind != '+' {
+			oldLine[i+1]++
+		}
+		if o.kind != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// a hunk starts with context before the first change and
+		// extends while changes are close enough to share context
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		end := i
+		for j := i; j < len(ops) && j <= end+2*context; j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			}
+		}
+
+		i = end + 1
+		end += context
+		if end >= len(ops) {
+			end = len(ops) - 1
+		}
+
+		oldCount, newCount := 0, 0
+		for _, o := range ops[start : end+1] {
+			if o.kind != '+' {
+				oldCount++
+			}
+			if o.kind != '-' {
+				newCount++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldLine[start], oldCount), hunkRange(newLine[start], newCount))
+		for _, o := range ops[start : end+1] {
+			out.WriteByte(o.kind)
+			out.WriteString(o.text)
+			if !strings.HasSuffix(o.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+
+	return out.Bytes()
+}
+
+// hunkRange formats the range of lines of a hunk
+func hunkRange(line, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", line-1) // an empty range refers to the line before it
+	case 1:
+		return fmt.Sprintf("%d", line)
+	}
+
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// splitLines splits text into lines keeping their line endings
+func splitLines(text []byte) []string {
+	lines := []string{}
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, '\n') + 1
+		if i == 0 {
+			i = len(text)
+		}
+
+		lines = append(lines, string(text[:i]))
+		text = text[i:]
+	}
+
+	return lines
+}
+
+// edits returns an edit script turning a into b. Common leading and trailing lines
+// are skipped before computing the longest common subsequence of what's left,
+// which keeps the typically small changes made by the generator cheap to diff
+func edits(a, b []string) []op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := []op{}
+	for _, line := range a[:prefix] {
+		ops = append(ops, op{' ', line})
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// lcs[i][j] is the length of the longest common subsequence of ma[i:] and mb[j:]
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			ops = append(ops, op{' ', ma[i]})
+			i++
+			j++
+		case i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', ma[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', mb[j]})
+			j++
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, op{' ', line})
+	}
+
+	return ops
+}
+
+// Edit replaces the bytes old[Start:End] with New
+type Edit struct {
+	Start int
+	End   int
+	New   string
+}
+
+// Edits returns the edits turning old into new, or nil if they are equal.
+// Each edit covers a run of changed lines and its offsets refer to old, so
+// applying them in reverse order never invalidates the offsets of the rest
+func Edits(old, new []byte) []Edit {
+	if bytes.Equal(old, new) {
+		return nil
+	}
+
+	var result []Edit
+	var current *Edit
+	offset := 0
+	for _, o := range edits(splitLines(old), splitLines(new)) {
+		if o.kind == ' ' {
+			current = nil
+			offset += len(o.text)
+			continue
+		}
+
+		if current == nil {
+			result = append(result, Edit{Start: offset, End: offset})
+			current = &result[len(result)-1]
+		}
+
+		if o.kind == '-' {
+			offset += len(o.text)
+			current.End = offset
+		} else {
+			current.New += o.text
+		}
+	}
+
+	return result
+}
+
+// ANSI escapes of the colors of a terminal
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	red    = "\x1b[31m"
+	green  = "\x1b[32m"
+	yellow = "\x1b[33m"
+	cyan   = "\x1b[36m"
+)
+
+// Color returns text colored for a terminal by kind: '+' for added, '-' for
+// removed and '~' for changed. Text of another kind is returned as it is
+func Color(kind byte, text string) string {
+	switch kind {
+	case '+':
+		return green + text + reset
+	case '-':
+		return red + text + reset
+	case '~':
+		return yellow + text + reset
+	}
+
+	return text
+}
+
+// Colorize returns the unified diff colored for a terminal the way git colors
+// diffs: file headers in bold, hunk headers in cyan, removed lines in red and
+// added lines in green
+func Colorize(unified []byte) []byte {
+	var out bytes.Buffer
+	for _, line := range splitLines(unified) {
+		text := strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(text, "--- ") || strings.HasPrefix(text, "+++ "):
+			out.WriteString(bold + text + reset)
+		case strings.HasPrefix(text, "@@"):
+			out.WriteString(cyan + text + reset)
+		case strings.HasPrefix(text, "-") || strings.HasPrefix(text, "+"):
+			out.WriteString(Color(text[0], text))
+		default:
+			out.WriteString(text)
+		}
+
+		if strings.HasSuffix(line, "\n") {
+			out.WriteByte('\n')
+		}
+	}
+
+	return out.Bytes()
+}
@@ -0,0 +1,99 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	old := "package p\n\ntype T struct{}\n\nfunc (t T) A() {}\n"
+	new := "package p\n\ntype I interface {\n\tA()\n}\n\ntype T struct{}\n\nfunc (t T) A() {}\n"
+
+	want := `--- a/p.go
++++ b/p.go
+@@ -1,5 +1,9 @@
+ package p
+ 
++type I interface {
++	A()
++}
++
+ type T struct{}
+ 
+ func (t T) A() {}
+`
+
+	if got := string(Unified("a/p.go", "b/p.go", []byte(old), []byte(new))); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnifiedEqual(t *testing.T) {
+	if got := Unified("a", "b", []byte("same\n"), []byte("same\n")); got != nil {
+		t.Errorf("expected no diff, got:\n%s", got)
+	}
+}
+
+func TestUnifiedNewFile(t *testing.T) {
+	want := `--- /dev/null
++++ b/p.go
+@@ -0,0 +1,2 @@
++package p
++
+`
+
+	if got := string(Unified("/dev/null", "b/p.go", nil, []byte("package p\n\n"))); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnifiedNoNewlineAtEnd(t *testing.T) {
+	want := `--- a/p
++++ b/p
+@@ -1 +1 @@
+-old
+\ No newline at end of file
++new
+`
+
+	if got := string(Unified("a/p", "b/p", []byte("old"), []byte("new\n"))); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEdits(t *testing.T) {
+	old := "package p\n\ntype T struct{}\n\nfunc (t T) A() {}\n"
+	new := "package p\n\ntype I interface {\n\tA()\n}\n\ntype T struct{}\n\nfunc (t T) A() {}\n\nfunc (t T) B() {}\n"
+
+	got := Edits([]byte(old), []byte(new))
+	want := []Edit{
+		{Start: 11, End: 11, New: "type I interface {\n\tA()\n}\n\n"},
+		{Start: 46, End: 46, New: "\nfunc (t T) B() {}\n"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d edits %+v, want %d", len(got), got, len(want))
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("edit %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// applying the edits in reverse order must produce new
+	result := old
+	for i := len(got) - 1; i >= 0; i-- {
+		result = result[:got[i].Start] + got[i].New + result[got[i].End:]
+	}
+
+	if result != new {
+		t.Errorf("applied edits:\n%s\nwant:\n%s", result, new)
+	}
+}
+
+func TestColorize(t *testing.T) {
+	unified := "--- a/p.go\n+++ b/p.go\n@@ -1,2 +1,2 @@\n package p\n-type A int\n+type B int\n"
+	want := "\x1b[1m--- a/p.go\x1b[0m\n\x1b[1m+++ b/p.go\x1b[0m\n\x1b[36m@@ -1,2 +1,2 @@\x1b[0m\n package p\n\x1b[31m-type A int\x1b[0m\n\x1b[32m+type B int\x1b[0m\n"
+
+	if got := string(Colorize([]byte(unified))); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"strings"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// listedType is a named type and its methods as printed by the list subcommand
+type listedType struct {
+	Name    string         `json:"name"`
+	Methods []listedMethod `json:"methods"`
+}
+
+// listedMethod is a method of a listed type
+type listedMethod struct {
+	Name      string `json:"name"`
+	Signature string `json:"signature"` // the method as it would appear in an interface
+	Exported  bool   `json:"exported"`
+}
+
+// runList runs the list subcommand, printing the named types of a package with their methods
+func runList(args []string) error {
+	flags := newFlagSet("list")
+	jsonFlag := flags.Bool("json", false, "Print the types as json")
+	parseFlags(flags, args)
+
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+
+	fset := token.NewFileSet()
+	files, err := parseDir(fset, dir, nil, "")
+	if err != nil {
+		return err
+	}
+
+	listed, err := listTypes(fset, files)
+	if err != nil {
+		return err
+	}
+
+	if *jsonFlag {
+		out, err := json.MarshalIndent(listed, "", "\t")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(out))
+		return nil
+	}
+
+	for _, t := range listed {
+		fmt.Println(t.Name)
+		for _, method := range t.Methods {
+			fmt.Printf("\t%s\n", method.Signature)
+		}
+	}
+
+	return nil
+}
+
+// listTypes returns the named types declared in files with their methods in source order
+func listTypes(fset *token.FileSet, files []*ast.File) ([]listedType, error) {
+	listed := []listedType{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if typeSpec.Assign.IsValid() {
+					continue // aliases don't have methods of their own
+				}
+
+				t := listedType{Name: typeSpec.Name.Name, Methods: []listedMethod{}}
+				for _, method := range generator.ExtractMethods(files, t.Name) {
+					var buf bytes.Buffer
+					if err := format.Node(&buf, fset, method.Type); err != nil {
+						return nil, err
+					}
+
+					t.Methods = append(t.Methods, listedMethod{
+						Name:      method.Name.Name,
+						Signature: method.Name.Name + strings.TrimPrefix(buf.String(), "func"),
+						Exported:  method.Name.IsExported(),
+					})
+				}
+
+				listed = append(listed, t)
+			}
+		}
+	}
+
+	return listed, nil
+}
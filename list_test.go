@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestRunList(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"store/store.go": "package store\n\ntype Store struct{}\n\nfunc (s *Store) Get(id string) (int, error) { return 0, nil }\n\nfunc (s Store) reset() {}\n\ntype ID = string\n",
+		"store/user.go":  "package store\n\ntype User struct{}\n",
+	})
+	t.Chdir(dir)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			args: []string{"store"},
+			want: "Store\n\tGet(id string) (int, error)\n\treset()\nUser\n",
+		},
+		{
+			args: []string{"-json", "store"},
+			want: `[
+	{
+		"name": "Store",
+		"methods": [
+			{
+				"name": "Get",
+				"signature": "Get(id string) (int, error)",
+				"exported": true
+			},
+			{
+				"name": "reset",
+				"signature": "reset()",
+				"exported": false
+			}
+		]
+	},
+	{
+		"name": "User",
+		"methods": []
+	}
+]
+`,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := captureStdout(t, func() error { return runList(test.args) })
+		if err != nil {
+			t.Fatalf("list %q: %v", test.args, err)
+		}
+
+		if got != test.want {
+			t.Errorf("list %q printed:\n%s\nwant:\n%s", test.args, got, test.want)
+		}
+	}
+}
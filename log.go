@@ -0,0 +1,46 @@
+package main
+
+import (
+	"go/ast"
+	"log/slog"
+	"os"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// logger logs what the tool decides, such as the methods it matched and where it put the
+// interface, to standard error at the level of -v or -vv. Standard out is left to what's generated
+var logger = slog.New(slog.DiscardHandler)
+
+// setVerbosity logs what's decided with verbose and, with veryVerbose, how it was decided
+func setVerbosity(verbose, veryVerbose bool) {
+	level := slog.LevelInfo
+	switch {
+	case veryVerbose:
+		level = slog.LevelDebug
+	case !verbose:
+		return
+	}
+
+	logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+}
+
+// filterMethods returns the methods that keep reports true for and logs those left out by the filter
+func filterMethods(methods []*ast.FuncDecl, filter string, keep func(*ast.FuncDecl) bool) []*ast.FuncDecl {
+	kept := generator.Filter(methods, keep)
+	if len(kept) < len(methods) {
+		logger.Debug("left out methods", "filter", filter, "methods", methodNames(generator.Filter(methods, generator.Not(keep))))
+	}
+
+	return kept
+}
+
+// methodNames returns the names of the methods
+func methodNames(methods []*ast.FuncDecl) []string {
+	names := []string{}
+	for _, method := range methods {
+		names = append(names, method.Name.Name)
+	}
+
+	return names
+}
@@ -2,495 +2,2220 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/format"
 	"go/parser"
 	"go/token"
 	"io/ioutil"
 	"os"
-	"reflect"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+	"github.com/hankjacobs/gointerfacegen/internal/diff"
 )
 
-const usage = `gointefacegen <type> <interface> <file>
+const usage = `gointefacegen <type>[,<type>...] <interface> <file> [files]
+
+Generates an interface from the type's methods found in the specified file. File must be valid go source. 
+If the interface already exists in the file, or in another file of its package, it is updated in place.
+Given several comma separated types, the interface has the methods of all of them or, with -common, the methods they share.
+Default behavior prints the resulting file with the new or updated interface to standard out. 
+If the file is - or -stdin is given, the source is read from standard input and the result is printed to standard out.
+Given more files of the package, the methods are gathered from them along with the file, and only from them with -types,
+leaving out the rest of the package such as generated files. The interface still goes into the first file unless -o names another.
+
+When run by go generate, the file defaults to $GOFILE and the result is written to it.
+If the type is also omitted, the type declared below the go:generate directive is used.
+A type qualified by the import path of its package, such as github.com/me/proj/internal/db.Store,
+is read from that package like with -pkg and the file is given by -o instead.
+
+gointefacegen [-check] [-dry-run] [-j n] <packages>
+
+Generates the interface described by every marker in the doc comment of a type in the packages, such as ./...
+A marker's options are those of a .gointerfacegen.json entry and its output is relative to the type's directory.
+
+	//gointerfacegen:interface=UserStore output=store_iface.go exported=true
+	type PostgresUserStore struct{}
+
+Examples:
+gointefacegen somecustomtype somecustominterface src.go
+gointefacegen -o ifaces.go somecustomtype somecustominterface src.go
+gointefacegen -pkg database/sql -o db_iface.go DB DBIface
+gointefacegen -o store_iface.go github.com/me/proj/internal/db.Store StoreIface
+gointefacegen UserStore,OrderStore Store src.go
+gointefacegen -types UserStore Store store.go queries.go
+gointefacegen -gen UserStore:UserStorer -gen OrderStore:OrderStorer src.go
+gointefacegen -common PostgresStore,MemoryStore Store src.go
+gointefacegen -groups User User src.go
+gointefacegen -used-by ./handlers -used-in ServeUser Client UserFetcher client.go
+cat src.go | gointefacegen -stdin somecustomtype somecustominterface
+cat src.go | gointefacegen somecustomtype somecustominterface -
+//go:generate gointefacegen somecustomtype somecustominterface
+//go:generate gointefacegen somecustominterface
+
+gointefacegen watch [-interval duration] [flags] <type>[,<type>...] <interface> <file>
+
+Regenerates the interface, written with -w or -o, whenever a go file in the directory of the file changes.
+
+gointefacegen generate [-config file] [-check] [-dry-run] [-j n] [packages]
+gointefacegen generate -all [-suffix suffix] [-o file] [-check] [-dry-run] [-j n] <packages>
+
+Generates all of the interfaces listed in the nearest .gointerfacegen.json
+found in the current directory or one of its parents or, given packages such as ./internal/...,
+those of the types in the packages.
+With -all, generates an interface named after the type with the suffix, Iface by default,
+for every exported type with exported methods in the packages.
+Packages are resolved by go list, the way go build resolves them.
+
+gointefacegen sync [-check] [-dry-run] [-since ref] [-j n] <packages>
+
+Regenerates every interface in the packages whose doc comment records its source in a marker, as -mark does,
+from the type and with the options recorded. The marker's file is relative to the interface's directory.
+
+	//gointerfacegen:source file=../postgres/user.go type=PostgresUserStore exported=true
+	type UserStore interface {
+
+With -since, only the interfaces whose file, or whose type's file or type checked package,
+changed since the git ref are regenerated, such as -since origin/main before pushing.
+
+gointefacegen serve [-socket path]
+
+Answers JSON-RPC requests, gointerfacegen.Generate, gointerfacegen.Check and gointerfacegen.List,
+over standard in and out or a unix socket, caching parsed files between requests.
+
+gointefacegen list [-json] [dir]
+
+Lists the named types of the package in dir, the current directory by default, with their methods.
+
+gointefacegen mock [-name type] [-o file] [-pkg name] [-test] <interface> <file>
+
+Generates a mock implementation of the interface whose methods call function fields set by tests.
+
+gointefacegen stub [-o file] [-pkg name] [-test] <interface> <type> <file>
+
+Generates a type implementing the interface with methods that panic, a skeleton to fill in.
+
+gointefacegen spy [-name type] [-o file] [-pkg name] [-test] <interface> <file>
+
+Generates an implementation of the interface that records its calls, returned by methods such as GetCalls for a method Get, and passes them on to another implementation.
+
+gointefacegen decorator [-o file] [-pkg name] [-test] <interface> <type> <file>
+
+Generates a type embedding the interface whose methods pass every call on to it, ready for some to be overridden.
+
+gointefacegen implementers [-near n] <interface> <file> [packages]
+
+Lists the types in the packages, the package of the file by default, that implement the interface
+declared in the package of the file along with the near misses and what they are missing.
+
+gointefacegen diff <interface> <package> <interface> <package>
+
+Prints the methods added, removed and changed from the first interface to the second, with their signatures,
+such as between two versions of a package. Either may be a type instead, compared by the methods of its pointer.
+Parameter names aside, signatures are compared with types named by their package's name, not its import path.
+
+gointefacegen rename [-d] <interface> <newName> <file>
+
+Renames the interface declared in the package of the file along with every reference to it in the package,
+its tests included, such as fields, parameters and assertions, and its name starting its doc comment.
+References in other packages are left to update.
+
+gointefacegen help [subcommand]
+
+Prints the usage of the subcommand, with its flags, or of the tool.
+
+gointefacegen completion bash|zsh|fish
+
+Prints the script completing flags, subcommands, and type and interface names parsed from
+the package for the shell. For example, add to ~/.bashrc:
+
+	source <(gointerfacegen completion bash)
+`
+
+// generatedHeader marks files created by the tool as generated (see https://golang.org/s/generatedcode)
+const generatedHeader = "// Code generated by gointerfacegen. DO NOT EDIT."
+
+type config struct {
+	typeNames       []string
+	interfaceName   string
+	filename        string
+	files           []string // other files of the package considered along with filename in place of the rest of it
+	outputFilename  string
+	generateLine    int // line of the go:generate directive when the type is omitted
+	typeCheck       bool
+	promoted        bool
+	common          bool
+	groups          bool
+	cluster         bool // split the methods by the fields they use instead of group directives
+	valueMethodSet  bool
+	usedBy          string // directory of a consuming package
+	usedIn          string // function of the consuming package
+	exportedOnly    bool
+	include         string
+	exclude         string
+	returning       string // type of the last result of the methods included
+	firstParam      string // type of the first parameter of the methods included
+	maxParams       *int   // most parameters of the methods included, no limit when nil
+	ignoreTag       string
+	docs            bool
+	skipDeprecated  bool
+	embedStd        bool
+	assert          bool
+	noopFilename    string
+	assertTestFile  string // test file asserting that the types implement the interfaces, in the package of the interfaces
+	check           bool
+	dryRun          *dryRunReport // records what writing would change in place of writing anything
+	diff            bool
+	jsonEdits       bool
+	prune           bool
+	declGroup       bool // insert a new interface into the type's type ( ... ) group
+	keepResultNames bool
+	stripParamNames bool
+	order           generator.Order
+	overlay         overlay // replacement contents of unsaved files
+	printInterface  bool
+	writeToFile     bool
+	backup          bool // keep the previous contents of a written file in a .orig file
+	externalTest    bool // a new output file belongs to the external test package
+	skipUnexported  bool // leave out methods that can't be declared in another package instead of failing
+	force           bool // replace a generated declaration that isn't an interface taking the interface's name
+	renameConflict  bool // name the interface differently when its name is taken instead of failing
+	conflictSuffix  string
+	outPackage      string             // package clause of a new output file
+	importAlias     string             // name of the type's package imported into another package
+	template        *template.Template // renders the output file in place of merging into it
+	headerTemplate  string             // text/template of the comments heading a new output file
+	pkg             string             // import path of the package declaring the types in place of the file
+	describe        bool               // print the method set as json instead of generating anything
+	markdown        bool               // print the method set as markdown instead of json
+	pairs           []pair             // the types and interfaces of -gen flags, generated in place of typeNames and interfaceName
+	interactive     bool               // pick the methods from a list on the terminal
+	constraint      bool               // generate a constraint whose type set is that of the types
+	tilde           bool               // the constraint's terms are the underlying types of the types
+	embedInto       string             // existing interface the generated interfaces are embedded into
+	mark            bool               // record the source of the interfaces in markers for sync
+	postprocess     string             // command the source of the file is piped through before it is written
+	replace         bool               // switch the declarations of the package from the type to the interface once written
+	validate        bool               // type check the file with its package before writing it
+}
+
+// generated is the file with the interfaces generated into it, the source file or the output file
+type generated struct {
+	fset           *token.FileSet
+	file           *ast.File
+	filename       string
+	src            []byte // the file's contents before generating, nil when it doesn't exist yet
+	interfaceNames []string
+	descriptions   []description // with -format json, in place of the file
+	assertTest     []byte        // the source of the -assert-test file
+	assertTestFile string        // the path of the -assert-test file, in the directory of the file
+}
+
+func main() {
+	// the shell completion scripts run the tool with the words typed so far
+	if len(os.Args) > 1 && os.Args[1] == "__complete" {
+		completing = true
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
+	// a subcommand's name is completed like the arguments it takes the place of
+	if len(os.Args) > 2 || len(os.Args) > 1 && !completing {
+		subcommands := map[string]func(args []string) error{
+			"help":         runHelp,
+			"completion":   runCompletion,
+			"generate":     runGenerate,
+			"sync":         runSync,
+			"list":         runList,
+			"mock":         runMock,
+			"stub":         runStub,
+			"spy":          runSpy,
+			"decorator":    runDecorator,
+			"implementers": runImplementers,
+			"diff":         runDiff,
+			"rename":       runRename,
+			"serve":        runServe,
+		}
+
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			if err := subcommand(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+
+			return
+		}
+	}
+
+	// watch takes the same flags and arguments as generating a single interface
+	watching := len(os.Args) > 1 && os.Args[1] == "watch" && (len(os.Args) > 2 || !completing)
+	if watching {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
+	printInterfaceFlag := flag.Bool("i", false, "Print only interface to standard out. This takes precedence over -w flag")
+	writeFlag := flag.Bool("w", false, "Write result to file instead of stdout")
+	interactiveFlag := flag.Bool("interactive", false, "Pick the methods that go into the interface from a list of the type's methods, then write the result as with -w unless printing it with -i or -d")
+	testFlag := flag.Bool("test", false, "Write the interface to a _test.go file, the -o file or else the file's _test.go counterpart, keeping it out of the production build")
+	xtestFlag := flag.Bool("xtest", false, "Like -test, but a new file belongs to the external test package, the package's name followed by _test")
+	backupFlag := flag.Bool("backup", false, "Keep the previous contents of a written file in a copy with the .orig extension")
+	outputFlag := flag.String("o", "", "Write the interface to this file instead of the source file. The file is created if it does not exist. In another package, the identifiers of the type's package are qualified and the package imported")
+	typesFlag := flag.Bool("types", false, "Resolve the type's methods by type checking the package of the file instead of matching receivers by name")
+	promotedFlag := flag.Bool("promoted", false, "Include the methods promoted from embedded fields. Implies -types")
+	methodSetFlag := flag.String("methodset", "pointer", "Method set to generate the interface from: pointer|value. value leaves out pointer receiver methods and implies -types")
+	commonFlag := flag.Bool("common", false, "Given several types, include only the methods they all have with the same signature")
+	usedByFlag := flag.String("used-by", "", "Include only the methods called by the package in this directory")
+	usedInFlag := flag.String("used-in", "", "Include only the methods called by this function or method of the -used-by package")
+	clusterFlag := flag.Bool("cluster", false, "Generate an interface for each cluster of methods using the same fields of the type, printed to standard error first. Each is named the interface followed by the field most of its methods use, and the methods using none keep the interface's name")
+	groupsFlag := flag.Bool("groups", false, "Generate an interface for each group named by //gointerfacegen:group directives on the methods. Each is named the interface followed by the group")
+	exportedFlag := flag.Bool("exported", false, "Include only exported methods in the interface")
+	includeFlag := flag.String("include", "", "Include only methods whose entire name matches this regular expression")
+	excludeFlag := flag.String("exclude", "", "Exclude methods whose entire name matches this regular expression")
+	onlyReturningFlag := flag.String("only-returning", "", "Include only methods whose last result is of this type, such as error")
+	firstParamFlag := flag.String("first-param", "", "Include only methods whose first parameter is of this type, such as context.Context, written as in the methods' declarations")
+	maxParamsFlag := flag.Int("max-params", -1, "Include only methods taking at most this many parameters, a variadic parameter counting as one. Negative for no limit")
+	embedStdFlag := flag.Bool("embed-std", false, "Embed well-known standard library interfaces, such as io.Reader, in place of their methods")
+	ignoreTagFlag := flag.String("ignore-tag", generator.DefaultIgnoreTag, "Exclude methods whose doc comment has this directive. Empty to include them")
+	docFlag := flag.Bool("doc", true, "Copy method doc comments onto the interface methods. Without them, the deprecation notices of deprecated methods are still copied")
+	skipDeprecatedFlag := flag.Bool("skip-deprecated", false, "Exclude methods whose doc comment has a Deprecated: paragraph")
+	assertFlag := flag.Bool("assert", false, "Also insert a compile-time assertion that the type implements the interface")
+	assertTestFlag := flag.String("assert-test", "", "Also write a _test.go file to this path in the package of the interface asserting, at compile time and in a test, that the types implement the interface")
+	constraintFlag := flag.Bool("constraint", false, "Generate a constraint for type parameters, whose type set is made of the types, in place of an interface they implement")
+	tildeFlag := flag.Bool("tilde", false, "With -constraint, make the type set that of every type whose underlying type is that of one of the types, such as ~float64 for type Celsius float64")
+	postprocessFlag := flag.String("postprocess", "", "Pipe the generated file through this command, a program and its arguments separated by spaces, and write its output instead, such as a custom formatter or license tool. It runs in the directory of the file")
+	validateFlag := flag.Bool("validate", false, "Type check the file with the rest of its package before writing it and write nothing if it would not compile")
+	replaceFlag := flag.Bool("replace", false, "Once the interface is written, switch the parameters, struct fields and variables of the package declared of the type, or a pointer to it, to the interface wherever only the interface's methods are used")
+	markFlag := flag.Bool("mark", false, "Record the type and options the interface is generated from in a marker in its doc comment, for the sync subcommand to regenerate it from")
+	embedIntoFlag := flag.String("embed-into", "", "Also embed the interface into this existing interface of the file, in place of the methods of the existing interface that it provides")
+	noopFlag := flag.String("noop", "", "Also write a no-op implementation of the interface named Noop<interface> to this file in the same package")
+	checkFlag := flag.Bool("check", false, "Check that the interface on disk is up to date and exit non-zero if it is not. Nothing is written")
+	dryRunFlag := flag.Bool("dry-run", false, "Print whether each interface would be created, updated, with the methods added, changed and removed, or left unchanged, such as before generating packages. Nothing is written")
+	diffFlag := flag.Bool("d", false, "Print a unified diff of the changes instead of the resulting file. Nothing is written")
+	jsonEditsFlag := flag.Bool("json-edits", false, "Print the changes as a json list of text edits instead of the resulting file. Nothing is written")
+	declGroupFlag := flag.Bool("decl-group", false, "Insert a new interface into the type ( ... ) group declaring the type, above the type, instead of on its own. A type declared on its own is grouped with the interface")
+	pruneFlag := flag.Bool("prune", false, "Remove methods from an existing interface that the type no longer has")
+	keepResultNamesFlag := flag.Bool("keep-result-names", false, "Keep the names of named results instead of stripping them")
+	paramNamesFlag := flag.String("param-names", "keep", "Whether to keep or strip parameter names: keep|strip")
+	stdinFlag := flag.Bool("stdin", false, "Read the source from standard input instead of a file. The result is printed to standard out")
+	overlayFlag := flag.String("overlay", "", "Read replacement file contents from this go build -overlay json file")
+	tagsFlag := flag.String("tags", "", "Comma separated build tags satisfied when reading the package, like go build -tags. GOOS and GOARCH are taken from the environment")
+	jobsFlag := flag.Int("j", runtime.NumCPU(), "With packages, the number of interfaces to generate at once")
+	intervalFlag := flag.Duration("interval", time.Second, "With watch, how often to check the package for changes")
+	unexportedFlag := flag.String("unexported", "error", "In another package, what to do with unexported methods and methods referencing unexported identifiers: error|skip. skip leaves them out with a warning")
+	forceFlag := flag.Bool("force", false, "Replace a declaration taking the interface's name that isn't an interface when it is in a generated file, one with a Code generated header")
+	renameFlag := flag.Bool("rename-on-conflict", false, "When the interface's name is taken by something other than an interface, or by anything in another file of the package, name it with -conflict-suffix, then followed by 2, 3 and so on, instead of failing")
+	conflictSuffixFlag := flag.String("conflict-suffix", "", "With -rename-on-conflict, the suffix appended to the interface's name. Empty to number it, as in Store2")
+	packageFlag := flag.String("package", "", "Package clause of a new -o file. Defaults to the package of the files in its directory, or the directory's name")
+	importAliasFlag := flag.String("import-alias", "", "In another package, import the type's package under this name instead of its own")
+	headerFlag := flag.String("header", "", "File with the text/template of the comments heading a new -o file and the -noop and -assert-test files, given .Interface, .Types, .Package, .Year and .Author, the git user.name. Plain text, such as a license, is commented. Defaults to a Code generated header")
+	templateFlag := flag.String("template", "", "File with the text/template rendering the whole output file from the generated interfaces, given .Package, .Imports, .Interfaces and the first interface's .Name, .Doc, .TypeParams, .Embeds, .Methods and .Decl. Requires -o to write")
+	pkgFlag := flag.String("pkg", "", "Generate the interface from a type of the package with this import path, such as database/sql or a dependency, found like the output file would import it. Requires -o unless run by go generate")
+	var pairs pairsFlag
+	flag.Var(&pairs, "gen", "Generate the interface from the type, given as Type:Interface, in place of the type and interface arguments. Repeat it to generate several interfaces into the file, written once")
+	diagnosticsFlag := flag.String("diagnostics", "text", "How to print errors: text|json. json prints an object with the file, line, column, error code, identifier and message of the error for editors and tools")
+	verboseFlag := flag.Bool("v", false, "Log what's decided, such as the methods matched and where the interface goes, to standard error")
+	veryVerboseFlag := flag.Bool("vv", false, "Log how it's decided too, such as the methods found and those each filter left out")
+	colorFlag := flag.String("color", "auto", "When to color -d diffs and -check output: auto|always|never. auto colors a terminal unless NO_COLOR is set")
+	reportFlag := flag.String("report", "text", "With -check, how to report the interfaces out of date: text|json|sarif. json and sarif are printed to standard out for CI systems and code review bots")
+	formatFlag := flag.String("format", "go", "Output format: go|json|markdown. json prints a description of the extracted methods, their parameters, results, doc comments and receivers, instead of go source. markdown prints a summary of the interface, each method's signature and doc comment, for design docs and pull requests. Nothing is written")
+	sortFlag := flag.String("sort", "none", "Order of the interface methods: source|alpha|none. none keeps the order of an existing interface, updating its methods in place and appending new ones")
+
+	parseFlags(flag.CommandLine, os.Args[1:])
+	setVerbosity(*verboseFlag, *veryVerboseFlag)
+
+	// the package's files, and those of the packages it imports when type checking,
+	// are those go build would compile for the build tags, GOOS and GOARCH
+	if *tagsFlag != "" {
+		build.Default.BuildTags = strings.Split(*tagsFlag, ",")
+	}
+
+	c := config{}
+	c.printInterface = *printInterfaceFlag
+	c.writeToFile = *writeFlag
+	c.backup = *backupFlag
+	c.outputFilename = *outputFlag
+	c.typeCheck = *typesFlag || *promotedFlag
+	c.promoted = *promotedFlag
+	c.common = *commonFlag
+	c.groups = *groupsFlag
+	c.cluster = *clusterFlag
+	c.usedBy = *usedByFlag
+	c.usedIn = *usedInFlag
+	c.exportedOnly = *exportedFlag
+	c.include = *includeFlag
+	c.exclude = *excludeFlag
+	c.returning = *onlyReturningFlag
+	c.firstParam = *firstParamFlag
+	if *maxParamsFlag >= 0 {
+		c.maxParams = maxParamsFlag
+	}
+	c.ignoreTag = *ignoreTagFlag
+	c.docs = *docFlag
+	c.skipDeprecated = *skipDeprecatedFlag
+	c.embedStd = *embedStdFlag
+	c.assert = *assertFlag
+	c.noopFilename = *noopFlag
+	c.assertTestFile = *assertTestFlag
+	c.check = *checkFlag
+	c.declGroup = *declGroupFlag
+	c.diff = *diffFlag
+	c.jsonEdits = *jsonEditsFlag
+	c.prune = *pruneFlag
+	c.keepResultNames = *keepResultNamesFlag
+	c.force = *forceFlag
+	c.outPackage = *packageFlag
+	c.importAlias = *importAliasFlag
+	c.renameConflict = *renameFlag
+	c.conflictSuffix = *conflictSuffixFlag
+	c.interactive = *interactiveFlag
+	c.constraint = *constraintFlag
+	c.tilde = *tildeFlag
+	c.embedInto = *embedIntoFlag
+	c.mark = *markFlag
+	c.replace = *replaceFlag
+	c.validate = *validateFlag
+	c.postprocess = *postprocessFlag
+
+	switch *paramNamesFlag {
+	case "keep":
+	case "strip":
+		c.stripParamNames = true
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -param-names %q: must be keep or strip\n", *paramNamesFlag)
+		os.Exit(2)
+	}
+
+	switch *methodSetFlag {
+	case "pointer":
+	case "value":
+		c.valueMethodSet = true
+		c.typeCheck = true
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -methodset %q: must be pointer or value\n", *methodSetFlag)
+		os.Exit(2)
+	}
+
+	switch *formatFlag {
+	case "go":
+	case "json":
+		c.describe = true
+	case "markdown":
+		c.describe, c.markdown = true, true
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -format %q: must be go, json or markdown\n", *formatFlag)
+		os.Exit(2)
+	}
+
+	if err := validReport(*reportFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	if err := validColor(*colorFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	colorMode = *colorFlag
+
+	if *diagnosticsFlag != "text" && *diagnosticsFlag != "json" {
+		fmt.Fprintf(os.Stderr, "invalid -diagnostics %q: must be text or json\n", *diagnosticsFlag)
+		os.Exit(2)
+	}
+
+	switch *unexportedFlag {
+	case "error":
+	case "skip":
+		c.skipUnexported = true
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -unexported %q: must be error or skip\n", *unexportedFlag)
+		os.Exit(2)
+	}
+
+	order, err := generator.ParseOrder(*sortFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	c.order = order
+
+	if *headerFlag != "" {
+		header, err := ioutil.ReadFile(*headerFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+
+		c.headerTemplate = string(header)
+	}
+
+	if *templateFlag != "" {
+		text, err := ioutil.ReadFile(*templateFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+
+		c.template, err = parseTemplate(*templateFlag, text)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if *overlayFlag != "" {
+		o, err := loadOverlay(*overlayFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+
+		c.overlay = o
+	}
+
+	// go generate sets GOFILE and GOLINE to the location of the directive
+	goFile, goLine := os.Getenv("GOFILE"), os.Getenv("GOLINE")
+
+	// Types qualified by the import path of their package, such as github.com/me/proj/internal/db.Store,
+	// are looked up in the package like -pkg looks them up
+	args := flag.Args()
+	pkgPath, err := qualifyingPackage(*pkgFlag, pairs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	switch {
+	case pkgPath != "" && len(pairs) > 0 && len(args) == 0:
+		c.pkg = pkgPath
+		c.pairs = pairs
+	case pkgPath != "" && len(args) == 2:
+		c.pkg = pkgPath
+		c.typeNames = strings.Split(args[0], ",")
+		c.interfaceName = args[1]
+	case len(pairs) > 0 && len(args) == 1:
+		c.pairs = pairs
+		c.filename = args[0]
+	case len(pairs) > 0 && len(args) == 0 && goFile != "":
+		c.pairs = pairs
+		c.filename = goFile
+		c.writeToFile = true
+	case len(args) == 3:
+		c.typeNames = strings.Split(args[0], ",")
+		c.interfaceName = args[1]
+		c.filename = args[2]
+	case len(args) > 3 && goFiles(args[2:]):
+		c.typeNames = strings.Split(args[0], ",")
+		c.interfaceName = args[1]
+		c.filename = args[2]
+		c.files = args[3:]
+	case len(args) == 2 && *stdinFlag:
+		c.typeNames = strings.Split(args[0], ",")
+		c.interfaceName = args[1]
+		c.filename = "-"
+	case len(args) == 2 && goFile != "":
+		c.typeNames = strings.Split(args[0], ",")
+		c.interfaceName = args[1]
+		c.filename = goFile
+		c.writeToFile = true
+	case len(args) == 1 && goFile != "" && goLine != "":
+		line, err := strconv.Atoi(goLine)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid GOLINE: %v\n", err)
+			os.Exit(1)
+		}
+
+		c.interfaceName = args[0]
+		c.filename = goFile
+		c.generateLine = line
+		c.writeToFile = true
+	case len(args) >= 1 && goFile == "" && packagePatterns(args):
+		if err := runMarkers(args, c.check, *dryRunFlag, *jobsFlag, *reportFlag); err != nil {
+			printError(*diagnosticsFlag, err)
+			os.Exit(1)
+		}
+		return
+	default:
+		fmt.Printf("%s\n", usage)
+		flag.PrintDefaults()
+		return
+	}
+
+	if c.groups && c.noopFilename != "" {
+		fmt.Fprintln(os.Stderr, "-noop cannot be used with -groups")
+		os.Exit(2)
+	}
+
+	// clusters are groups found without directives
+	if c.cluster && (c.groups || c.noopFilename != "" || c.constraint || c.mark || c.replace) {
+		fmt.Fprintln(os.Stderr, "-cluster cannot be used with -groups, -noop, -constraint, -mark or -replace")
+		os.Exit(2)
+	}
+
+	if c.pkg != "" {
+		// run by go generate, the interface is generated into the file of the directive
+		if c.outputFilename == "" && goFile != "" {
+			c.outputFilename = goFile
+		}
+
+		if c.outputFilename == "" && !c.describe || *stdinFlag || *xtestFlag {
+			fmt.Fprintln(os.Stderr, "-pkg requires -o, unless describing the methods with -format json, and cannot be used with -stdin or -xtest")
+			os.Exit(2)
+		}
+	}
+
+	// the template's output would replace the source file
+	if c.template != nil && c.writeToFile && c.outputFilename == "" {
+		fmt.Fprintln(os.Stderr, "-template requires -o to write")
+		os.Exit(2)
+	}
+
+	if c.assertTestFile != "" && !strings.HasSuffix(c.assertTestFile, "_test.go") {
+		fmt.Fprintf(os.Stderr, "-assert-test requires a _test.go file, not %s\n", c.assertTestFile)
+		os.Exit(2)
+	}
+
+	if len(c.pairs) > 0 && (c.noopFilename != "" || c.assertTestFile != "") {
+		fmt.Fprintln(os.Stderr, "-noop and -assert-test cannot be used with -gen")
+		os.Exit(2)
+	}
+
+	if c.tilde && !c.constraint {
+		fmt.Fprintln(os.Stderr, "-tilde requires -constraint")
+		os.Exit(2)
+	}
+
+	// a constraint can only be used as a type parameter's bound, which no type implements
+	if c.constraint && (c.assert || c.noopFilename != "" || c.assertTestFile != "" || c.embedInto != "") {
+		fmt.Fprintln(os.Stderr, "-constraint cannot be used with -assert, -noop, -assert-test or -embed-into")
+		os.Exit(2)
+	}
+
+	// a marker records what sync can regenerate the interface from on its own
+	if c.mark && (c.groups || c.constraint || len(c.files) > 0 || c.filename == "-" || *stdinFlag || c.interactive || c.template != nil || c.postprocess != "") {
+		fmt.Fprintln(os.Stderr, "-mark cannot be used with -groups, -constraint, -interactive, -template, -postprocess, more files or standard input")
+		os.Exit(2)
+	}
+
+	if len(c.pairs) > 0 && c.pkg == "" && (c.filename == "-" || *stdinFlag) {
+		fmt.Fprintln(os.Stderr, "-gen requires a file")
+		os.Exit(2)
+	}
+
+	if c.interactive {
+		if c.filename == "-" || *stdinFlag || c.check || watching {
+			fmt.Fprintln(os.Stderr, "-interactive reads the selection from standard input and cannot be used with -stdin, -check or watch")
+			os.Exit(2)
+		}
+
+		c.writeToFile = !c.printInterface && !c.diff && !c.jsonEdits && !c.describe
+	}
+
+	if c.usedIn != "" && c.usedBy == "" {
+		fmt.Fprintln(os.Stderr, "-used-in requires -used-by")
+		os.Exit(2)
+	}
+
+	if c.filename == "-" && c.writeToFile {
+		fmt.Fprintln(os.Stderr, "cannot write the result back to standard input")
+		os.Exit(2)
+	}
+
+	// the package's declarations are switched to the interface written into it
+	if c.replace && (c.filename == "-" || *stdinFlag || c.pkg != "" || len(c.typeNames) != 1 || c.groups || c.constraint) {
+		fmt.Fprintln(os.Stderr, "-replace requires a single type of a file and cannot be used with -gen, -pkg, -groups or -constraint")
+		os.Exit(2)
+	}
+
+	if c.replace && (!c.writeToFile && c.outputFilename == "" || c.check || c.diff || c.jsonEdits || c.printInterface || c.describe) {
+		fmt.Fprintln(os.Stderr, "-replace requires -w or -o")
+		os.Exit(2)
+	}
+
+	if *testFlag || *xtestFlag {
+		if c.filename == "-" && c.outputFilename == "" {
+			fmt.Fprintln(os.Stderr, "-test requires -o when reading standard input")
+			os.Exit(2)
+		}
+
+		output, err := outputFile(c.outputFilename, true, c.filename, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+
+		c.outputFilename = output
+		c.externalTest = *xtestFlag
+	}
+
+	// the files of the interfaces of -gen are parsed and type checked once
+	cached = newFileCache(true)
+
+	if watching {
+		if c.filename == "-" || !c.writeToFile && c.outputFilename == "" {
+			fmt.Fprintln(os.Stderr, "watch requires a file and -w or -o")
+			os.Exit(2)
+		}
+
+		if err := watch(c, *intervalFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *dryRunFlag {
+		if c.check || c.diff || c.jsonEdits {
+			fmt.Fprintln(os.Stderr, "-dry-run cannot be used with -check, -d or -json-edits")
+			os.Exit(2)
+		}
+
+		c.dryRun = &dryRunReport{}
+	}
+
+	_, err = run(c)
+	if c.dryRun != nil && err == nil {
+		c.dryRun.print(os.Stdout)
+	}
+
+	if c.check && *reportFlag != "text" {
+		errs := []error{}
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if reportErr := printReport(*reportFlag, errs); reportErr != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", reportErr)
+			os.Exit(1)
+		}
+	} else if err != nil {
+		printError(*diagnosticsFlag, err)
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// generate generates the interface into the file it is to be written to without writing anything
+func generate(c config) (*generated, error) {
+	fset := cached.fileSet()
+
+	var (
+		srcBytes   []byte
+		file       *ast.File   // the file declaring the type, nil for a type of another package
+		files      []*ast.File // the files declaring the imports the methods refer to
+		srcPkgName string
+		typeParams *ast.FieldList
+		methods    []*ast.FuncDecl
+		err        error
+	)
+
+	// A type of another package, such as sql.DB, is resolved by the import
+	// path of its package and generated into the output file
+	if c.pkg != "" {
+		srcPkgName, methods, files, err = externalMethods(c, fset)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		srcBytes, err = readSource(c.filename, c.overlay)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err = cached.parse(fset, sourceName(c.filename), srcBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		// The type was omitted from the go:generate directive
+		if len(c.typeNames) == 0 {
+			typeName, err := typeDeclaredAfterLine(fset, file, c.generateLine, c.interfaceName)
+			if err != nil {
+				return nil, err
+			}
+
+			c.typeNames = []string{typeName}
+		}
+
+		// The type parameters of a generic type are carried over to the interface.
+		// The type may be declared in another file so its absence is not an error
+		files = []*ast.File{file}
+		if len(c.files) > 0 {
+			files, err = parseFiles(fset, file, c.filename, c.files, c.overlay)
+			if err != nil {
+				return nil, err
+			}
+		} else if c.typeCheck {
+			files, err = parsePackage(fset, file, c.filename, c.overlay)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if typeSpec := generator.FindType(files, c.typeNames[0]); typeSpec != nil {
+			typeParams = typeSpec.TypeParams
+		}
+
+		// The interface of several types has the methods of all of them
+		// or, when looking for their common methods, those they share
+		methodSets := [][]*ast.FuncDecl{}
+		for _, name := range c.typeNames {
+			methods, err := typeMethods(c, fset, files, name)
+			if err != nil {
+				return nil, generator.Place(err, fset, file, sourceName(c.filename))
+			}
+
+			logger.Debug("found methods", "type", name, "methods", methodNames(methods))
+			methodSets = append(methodSets, methods)
+		}
+
+		if c.common {
+			methods = generator.Intersect(methodSets...)
+		} else {
+			methods, err = generator.Union(methodSets...)
+			if err != nil {
+				return nil, generator.Place(err, fset, file, sourceName(c.filename))
+			}
+		}
+
+		srcPkgName = file.Name.Name
+	}
+
+	// The interface is placed alongside the first of several types
+	typeName := c.typeNames[0]
+
+	// all of the methods, which the interface is checked against once merged
+	declared := methods
+
+	// Derive the interface from what a consumer actually calls
+	if c.usedBy != "" {
+		consumer, err := parseDir(fset, c.usedBy, c.overlay, "")
+		if err != nil {
+			return nil, err
+		}
+
+		used, err := generator.UsedMethods(fset, consumer, srcPkgName, typeName, c.usedIn)
+		if err != nil {
+			return nil, generator.Place(err, fset, nil, c.usedBy)
+		}
+
+		methods = filterMethods(methods, "-used-by", func(method *ast.FuncDecl) bool {
+			return used[method.Name.Name]
+		})
+	}
+
+	methods, err = generator.FilterMethods(methods, generator.FilterOptions{
+		IgnoreTag:      c.ignoreTag,
+		SkipDeprecated: c.skipDeprecated,
+		Exported:       c.exportedOnly,
+		Include:        c.include,
+		Exclude:        c.exclude,
+		Returning:      c.returning,
+		FirstParam:     c.firstParam,
+		MaxParams:      c.maxParams,
+		Log:            logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Let the user pick the methods
+	if c.interactive {
+		methods, err = selectMethods(os.Stdin, os.Stderr, generator.JoinNames(c.typeNames), methods)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The interface is divided into sections as the methods are unless they are sorted by name
+	var sections map[string]*ast.CommentGroup
+	if c.order != generator.OrderAlpha {
+		sections = generator.Sections(fset, files, methods)
+	}
+
+	logger.Info("matched methods", "types", c.typeNames, "interface", c.interfaceName, "methods", methodNames(methods))
+
+	// Describe the method set instead of generating anything
+	if c.describe {
+		descriptions, err := describe(c, srcPkgName, typeParams, methods)
+		if err != nil {
+			return nil, err
+		}
+
+		return &generated{descriptions: descriptions}, nil
+	}
+
+	// The packages the methods refer to are imported by the
+	// file the interface is generated into when it doesn't already
+	imports := generator.MethodImports(fset, files, methods)
+
+	// An interface generated into another package refers to the
+	// types of the type's package, and the type itself, by its name
+	outPkgName, err := outputPackageName(c, srcPkgName)
+	if err != nil {
+		return nil, err
+	}
+
+	qualify := outPkgName != srcPkgName || c.pkg != ""
+	implementers := c.typeNames
+	if qualify {
+		var unqualifiable []generator.Unqualifiable
+		if c.pkg != "" {
+			methods, unqualifiable = generator.Declarable(methods)
+			if c.importAlias != "" {
+				if methods, err = generator.RenamePackage(methods, srcPkgName, c.importAlias); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			if methods, unqualifiable, err = generator.Qualify(methods, srcQualifier(c, srcPkgName)); err != nil {
+				return nil, err
+			}
+		}
+		if len(unqualifiable) > 0 {
+			lines := []string{}
+			for _, u := range unqualifiable {
+				if u.Ident == u.Method.Name.Name {
+					lines = append(lines, fmt.Sprintf("\t%s is unexported", u.Ident))
+				} else {
+					lines = append(lines, fmt.Sprintf("\t%s references %s", u.Method.Name.Name, u.Ident))
+				}
+			}
+
+			if !c.skipUnexported {
+				first := unqualifiable[0]
+				return nil, &generator.Diagnostic{
+					Pos:   fset.Position(first.Method.Pos()),
+					Code:  generator.CodeUnexported,
+					Ident: first.Ident,
+					Msg:   fmt.Sprintf("unexported methods and methods referencing unexported identifiers can't be declared in package %s:\n%s", outPkgName, strings.Join(lines, "\n")),
+				}
+			}
+
+			fmt.Fprintf(os.Stderr, "leaving out the unexported methods and methods referencing unexported identifiers, which can't be declared in package %s:\n%s\n", outPkgName, strings.Join(lines, "\n"))
+		}
+
+		implementers = []string{}
+		for _, name := range c.typeNames {
+			implementers = append(implementers, srcQualifier(c, srcPkgName)+"."+name)
+		}
+	}
+
+	// The interface is generated into the source file unless a separate output file
+	// was requested. targetSrc is nil when the file doesn't exist yet
+	targetFilename, targetSrc := c.filename, srcBytes
+	declaring, err := interfaceFile(c, fset, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if declaring != nil {
+		// but into the file of the package already declaring the interface
+		targetFilename = fset.Position(declaring.Package).Filename
+		logger.Info("updating the interface in the file declaring it", "interface", c.interfaceName, "file", targetFilename)
+
+		targetSrc, err = readSource(targetFilename, c.overlay)
+		if err != nil {
+			return nil, err
+		}
+		file = declaring
+	} else if c.outputFilename != "" {
+		targetFilename = c.outputFilename
+		targetSrc, err = readOutputFile(c.outputFilename, c.overlay)
+		if err != nil {
+			return nil, err
+		}
+
+		outSrc := targetSrc
+		if outSrc == nil {
+			header, err := newFileHeader(c, outPkgName, c.outputFilename)
+			if err != nil {
+				return nil, err
+			}
+
+			outSrc = []byte(header + "\n\npackage " + outPkgName + "\n")
+		}
+
+		file, err = generator.ParseFile(fset, c.outputFilename, outSrc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	logger.Debug("generating into file", "file", sourceName(targetFilename), "exists", targetSrc != nil)
+
+	// One interface is generated unless the methods are split by their group
+	// directives into several interfaces named after the groups
+	groups := []generator.Group{{Methods: methods}}
+	if c.groups {
+		groups = generator.Groups(methods)
+		if len(groups) == 0 {
+			return nil, fmt.Errorf("no methods of %s have group directives", generator.JoinNames(c.typeNames))
+		}
+	}
+
+	// or by the fields of the type they use, which is reported to
+	// be turned into group directives where it isn't quite right
+	if c.cluster && len(methods) > 0 {
+		groups = nil
+		for _, cluster := range generator.Clusters(files, typeName, methods) {
+			groups = append(groups, cluster.Group)
+			if c.check {
+				continue
+			}
+
+			if len(cluster.Fields) == 0 {
+				fmt.Fprintf(os.Stderr, "%s: %s, using no fields\n", c.interfaceName+cluster.Name, generator.JoinNames(methodNames(cluster.Methods)))
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: %s, using %s\n", c.interfaceName+cluster.Name, generator.JoinNames(methodNames(cluster.Methods)), generator.JoinNames(cluster.Fields))
+			}
+		}
+	}
+
+	// A constraint's type set is made of the types, instantiated like the interface
+	var terms []string
+	if c.constraint {
+		terms, err = constraintTerms(c, fset, files, implementers, typeParams, qualify)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	interfaceNames := []string{}
+	ifaces := []*ast.GenDecl{}
+	var embeds []generator.Embed
+	others := packageFiles(c, fset, file, targetFilename)
+	for _, group := range groups {
+		var interfaceName string
+		file, interfaceName, err = resolveConflict(c, fset, file, others, c.interfaceName+group.Name, sourceName(targetFilename))
+		if err != nil {
+			return nil, err
+		}
+		interfaceNames = append(interfaceNames, interfaceName)
+
+		methods := group.Methods
+		embedNames := []string{}
+		if c.embedStd {
+			var groupEmbeds []generator.Embed
+			groupEmbeds, methods = generator.EmbedStd(methods)
+			for _, embed := range groupEmbeds {
+				embedNames = append(embedNames, embed.Name)
+			}
+			embeds = append(embeds, groupEmbeds...)
+		}
+
+		doc := generator.InterfaceDoc(interfaceName, implementers)
+		if c.constraint {
+			doc = fmt.Sprintf("%s is the constraint satisfied by %s.", interfaceName, generator.JoinNames(implementers))
+		}
+
+		iface, err := generator.BuildInterface(interfaceName, methods, typeParams, generator.Options{
+			Doc:  doc,
+			Docs: c.docs,
+
+			// users of the interface are warned even without the doc comments
+			DeprecationNotices: true,
+
+			ResultNames:     c.keepResultNames,
+			StripParamNames: c.stripParamNames,
+
+			Embeds:   embedNames,
+			Terms:    terms,
+			Sections: sections,
+		})
+		if err != nil {
+			return nil, err
+		}
+		ifaces = append(ifaces, iface)
+	}
+
+	// the type's package is only imported when referenced
+	if qualify && (c.assert || c.constraint && !c.tilde || generator.References(methods, srcQualifier(c, srcPkgName))) {
+		path, err := srcImportPath(c)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err = generator.AddNamedImport(fset, file, c.importAlias, path)
+		if err != nil {
+			return nil, err
+		}
+
+		// the file may already import the package under another name
+		if name := importedAs(file, path); c.importAlias != "" && name != c.importAlias {
+			return nil, fmt.Errorf("%s already imports %s as %s, not %s", targetFilename, path, name, c.importAlias)
+		}
+	}
+
+	for _, imp := range imports {
+		file, err = generator.AddNamedImport(fset, file, imp.Name, imp.Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The packages of embedded interfaces are imported first so
+	// their methods are recognized when merging
+	for _, embed := range embeds {
+		if embed.Path == "" {
+			continue
+		}
+
+		file, err = generator.AddImport(fset, file, embed.Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, iface := range ifaces {
+		file, err = generator.MergeInto(fset, file, iface, typeName, generator.MergeOptions{
+			Prune: c.prune,
+			Order: c.order,
+			Group: c.declGroup,
+			Docs:  c.docs,
+			Log:   logger,
+		})
+		if err != nil {
+			return nil, generator.Place(err, fset, file, sourceName(targetFilename))
+		}
+	}
+
+	if c.mark {
+		options, err := sourceOptions(c, targetFilename)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, interfaceName := range interfaceNames {
+			file, err = generator.Mark(fset, file, interfaceName, options)
+			if err != nil {
+				return nil, generator.Place(err, fset, file, sourceName(targetFilename))
+			}
+		}
+	}
+
+	// the interfaces are roles the existing interface is composed of
+	if c.embedInto != "" {
+		for _, interfaceName := range interfaceNames {
+			file, err = generator.EmbedInto(fset, file, interfaceName, c.embedInto)
+			if err != nil {
+				return nil, generator.Place(err, fset, file, sourceName(targetFilename))
+			}
+		}
+	}
+
+	// methods kept from an existing interface may have drifted from the type
+	if !c.prune {
+		for _, interfaceName := range interfaceNames {
+			drifted, err := generator.Drifted(fset, file, interfaceName, declared)
+			if err != nil {
+				return nil, generator.Place(err, fset, file, sourceName(targetFilename))
+			}
+
+			for _, d := range drifted {
+				if d.TypeMethod == "" {
+					fmt.Fprintf(os.Stderr, "%s: %s has %s but %s has no method %s\n", d.Pos, interfaceName, d.Method, generator.JoinNames(c.typeNames), d.Name)
+				} else {
+					fmt.Fprintf(os.Stderr, "%s: %s has %s but %s has %s\n", d.Pos, interfaceName, d.Method, generator.JoinNames(c.typeNames), d.TypeMethod)
+				}
+			}
+		}
+	}
+
+	if c.assert {
+		for _, interfaceName := range interfaceNames {
+			for _, name := range implementers {
+				file, err = generator.AddAssertion(fset, file, interfaceName, name)
+				if err != nil {
+					return nil, generator.Place(err, fset, file, sourceName(targetFilename))
+				}
+			}
+		}
+	}
+
+	// go test catches a type that no longer implements the interface
+	// even without the assertion in the production code
+	var assertTest []byte
+	assertTestFile := c.assertTestFile
+	if assertTestFile != "" {
+		if typeParams != nil {
+			return nil, fmt.Errorf("-assert-test cannot be used with generic type %s", typeName)
+		}
+
+		// a file name alone is placed alongside the interface
+		dir := filepath.Dir(targetFilename)
+		if filepath.Dir(assertTestFile) == "." {
+			assertTestFile = filepath.Join(dir, assertTestFile)
+		} else if !sameDir(filepath.Dir(assertTestFile), dir) {
+			return nil, fmt.Errorf("-assert-test %s must be in the package of %s", assertTestFile, sourceName(targetFilename))
+		}
+
+		imports := []generator.Import{}
+		if qualify {
+			path, err := srcImportPath(c)
+			if err != nil {
+				return nil, err
+			}
+
+			imports = append(imports, generator.Import{Name: importName(srcQualifier(c, srcPkgName), path), Path: path})
+		}
+
+		header, err := newFileHeader(c, file.Name.Name, assertTestFile)
+		if err != nil {
+			return nil, err
+		}
+
+		assertTest, err = generator.AssertionTest(header, file.Name.Name, imports, interfaceNames, implementers, c.valueMethodSet)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &generated{
+		fset:           fset,
+		file:           file,
+		filename:       targetFilename,
+		src:            targetSrc,
+		interfaceNames: interfaceNames,
+		assertTest:     assertTest,
+		assertTestFile: assertTestFile,
+	}, nil
+}
+
+// run generates the interface of c and outputs it as requested, returning the file it is
+// generated into: the output file, the source file or the file declaring the interface
+func run(c config) (string, error) {
+	g, err := generateAll(c)
+	if err != nil {
+		return "", err
+	}
+	if c.describe && c.markdown {
+		return "", printMarkdown(g.descriptions)
+	} else if c.describe {
+		return "", printDescriptions(g.descriptions)
+	}
+
+	fset, file, targetFilename, targetSrc, interfaceNames := g.fset, g.file, g.filename, g.src, g.interfaceNames
+
+	// The template renders the whole file from the generated interfaces
+	if c.template != nil {
+		file, err = applyTemplate(c.template, fset, file, sourceName(targetFilename), interfaceNames)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// The command's output is what's written, and what's on disk is checked against.
+	// The file is parsed from it for everything printed from the file instead
+	var postprocessed []byte
+	if c.postprocess != "" {
+		newSrc, err := newSource(fset, file, targetSrc)
+		if err != nil {
+			return "", err
+		}
+
+		postprocessed, err = postprocess(c.postprocess, targetFilename, newSrc)
+		if err != nil {
+			return "", err
+		}
+
+		file, err = generator.ParseFile(fset, targetFilename, postprocessed)
+		if err != nil {
+			return "", fmt.Errorf("-postprocess %s: %v", c.postprocess, err)
+		}
+	}
+
+	// Check what's on disk instead of outputting anything
+	if c.check {
+		return targetFilename, checkUpToDate(fset, file, sourceName(targetFilename), targetSrc, interfaceNames)
+	}
+
+	// Report what writing would change
+	if c.dryRun != nil {
+		return targetFilename, c.dryRun.add(fset, file, sourceName(targetFilename), targetSrc, interfaceNames)
+	}
+
+	// Print the changes to what's on disk
+	if c.diff {
+		return targetFilename, printDiff(fset, file, sourceName(targetFilename), targetSrc, interfaceNames)
+	}
+
+	// Print the changes for an editor to apply
+	if c.jsonEdits {
+		return targetFilename, printJSONEdits(fset, file, sourceName(targetFilename), targetSrc)
+	}
+
+	// Refuse to leave the package broken
+	if c.validate && (c.writeToFile || c.outputFilename != "") {
+		newSrc, err := newSource(fset, file, targetSrc)
+		if err != nil {
+			return "", err
+		}
+
+		if postprocessed != nil {
+			newSrc = postprocessed
+		}
+
+		if err := validate(c, targetFilename, newSrc, nil); err != nil {
+			return "", err
+		}
+	}
+
+	// Write a no-op implementation alongside
+	if c.noopFilename != "" {
+		err = writeNoop(fset, file, targetFilename, c)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// and the test asserting that the types implement the interface
+	if c.assertTestFile != "" {
+		err = writeFile(g.assertTestFile, g.assertTest, c.backup)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Print only interface
+	if c.printInterface {
+		for i, interfaceName := range interfaceNames {
+			decl, err := generator.FindInterface(file, interfaceName)
+			if err != nil {
+				return "", err
+			}
+
+			var iSrcBuff bytes.Buffer
+			err = format.Node(&iSrcBuff, fset, decl)
+			if err != nil {
+				return "", err
+			}
+
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(iSrcBuff.String())
+		}
+
+		return targetFilename, nil
+	}
+
+	// Generate new source
+	newSrc, err := newSource(fset, file, targetSrc)
+	if err != nil {
+		return "", err
+	}
+
+	if postprocessed != nil {
+		newSrc = postprocessed
+	}
+
+	// Write it to the output file, the source file or the file declaring the interface
+	if c.outputFilename != "" || c.writeToFile {
+		err = writeFile(targetFilename, newSrc, c.backup)
+	} else {
+		// or print it out
+		os.Stdout.Write(newSrc)
+		return targetFilename, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	// then switch the package to the interface
+	if c.replace {
+		err = replaceType(c, targetFilename, interfaceNames[0])
+	}
+
+	return targetFilename, err
+}
+
+// typeMethods returns the methods of the named type, resolved by
+// type checking files when requested and by receiver name otherwise
+func typeMethods(c config, fset *token.FileSet, files []*ast.File, typeName string) ([]*ast.FuncDecl, error) {
+	// an alias of a type declared in another package, such
+	// as type Store = postgres.Store, can only be type checked
+	foreignAlias := false
+	if typeSpec := generator.FindType(files, typeName); typeSpec != nil && typeSpec.Assign.IsValid() {
+		_, local := typeSpec.Type.(*ast.Ident)
+		foreignAlias = !local
+	}
+
+	// the file may be left out of the build configuration and declare the
+	// same methods as a file in it, such as store_linux.go and store_windows.go
+	declared, err := generator.Dedupe(fset, generator.ExtractMethods(files, typeName))
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.typeCheck && !foreignAlias {
+		return declared, nil
+	}
+
+	return generator.ResolveMethods(fset, files, typeName, generator.ResolveOptions{
+		Promoted: c.promoted,
+		Value:    c.valueMethodSet,
+		Checker:  cached.typeChecker(),
+	})
+}
+
+// resolveConflict returns the name to give the interface named name in the file, which is the
+// file with any generated declaration taking the name removed when forced. The name stands
+// unless it is taken by something other than an interface, or by anything in the other files
+// of the package, which is an error unless a generated declaration of the file can be
+// replaced or the interface renamed. What was chosen is reported
+func resolveConflict(c config, fset *token.FileSet, file *ast.File, others []*ast.File, name, filename string) (*ast.File, string, error) {
+	var obj *ast.Object
+	for _, other := range others {
+		if obj = other.Scope.Lookup(name); obj != nil {
+			break
+		}
+	}
+	declaredElsewhere := obj != nil
+
+	if !declaredElsewhere {
+		if !generator.NameTaken(file, name) {
+			return file, name, nil
+		}
+
+		obj = file.Scope.Lookup(name)
+	}
+
+	// the conflict is reported at the declaration taking the name
+	taken := func(format string, args ...interface{}) error {
+		pos := fset.Position(obj.Pos())
+		if !pos.IsValid() {
+			pos = token.Position{Filename: filename}
+		}
+
+		return &generator.Diagnostic{Pos: pos, Code: generator.CodeNameTaken, Ident: name, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	if declaredElsewhere && !c.renameConflict {
+		return nil, "", taken("%s is already declared in %s of the same package. Use -rename-on-conflict to name the interface differently", name, filepath.Base(fset.Position(obj.Pos()).Filename))
+	}
+
+	if c.force && !declaredElsewhere {
+		_, isType := obj.Decl.(*ast.TypeSpec)
+		switch {
+		case !isType:
+			return nil, "", taken("%s is already declared by something other than a type, which -force doesn't replace", name)
+		case !ast.IsGenerated(file):
+			return nil, "", taken("%s is already declared by a type that wasn't generated, which -force doesn't replace", name)
+		}
+
+		for _, typeName := range c.typeNames {
+			if typeName == name {
+				return nil, "", taken("%s is the type the interface is generated from", name)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: replacing the generated declaration of %s with the interface\n", filename, name)
+		file, err := generator.RemoveType(fset, file, name)
+		return file, name, err
+	}
+
+	if c.renameConflict {
+		renamed := generator.FreeName(file, name, c.conflictSuffix, others...)
+		if declaredElsewhere {
+			fmt.Fprintf(os.Stderr, "%s: %s is already declared in %s, naming the interface %s\n", filename, name, filepath.Base(fset.Position(obj.Pos()).Filename), renamed)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s is already declared by something other than an interface, naming the interface %s\n", filename, name, renamed)
+		}
+		return file, renamed, nil
+	}
+
+	return nil, "", taken("%s is already declared by something other than an interface. Use -rename-on-conflict to name the interface differently or, when the declaration is generated, -force to replace it", name)
+}
+
+// sameDir reports whether the directories are the same once made absolute
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// srcImportPath returns the import path of the package declaring the types
+func srcImportPath(c config) (string, error) {
+	if c.pkg != "" {
+		return c.pkg, nil
+	}
+
+	return importPath(filepath.Dir(c.filename))
+}
+
+// importName returns the name to import the package with the path under to refer to it
+// by name, which is empty when the name is the last element of the path
+func importName(name, importPath string) string {
+	if name == path.Base(importPath) {
+		return ""
+	}
+
+	return name
+}
+
+// srcQualifier returns the name the type's package is referred to by from another package
+func srcQualifier(c config, srcPkgName string) string {
+	if c.importAlias != "" {
+		return c.importAlias
+	}
+
+	return srcPkgName
+}
+
+// importedAs returns the name the file imports the package with the import path as
+func importedAs(file *ast.File, path string) string {
+	for _, spec := range file.Imports {
+		if existing, err := strconv.Unquote(spec.Path.Value); err != nil || existing != path {
+			continue
+		}
 
-Generates an interface from the type's methods found in the specified file. File must be valid go source. 
-If the interface already exists, it is updated in place.
-Default behavior prints the resulting file with the new or updated interface to standard out. 
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
 
-Examples:
-gointefacegen somecustomtype somecustominterface src.go
-`
+		return filepath.Base(path)
+	}
 
-type config struct {
-	typeName       string
-	interfaceName  string
-	filename       string
-	printInterface bool
-	writeToFile    bool
+	return ""
 }
 
-func main() {
-
-	printInterfaceFlag := flag.Bool("i", false, "Print only interface to standard out. This takes precedence over -w flag")
-	writeFlag := flag.Bool("w", false, "Write result to file instead of stdout")
+// newFileHeader returns the comments heading a new file of the package, the -header
+// template executed for the interface or a Code generated header. A template of plain
+// text, such as a license, is turned into line comments
+func newFileHeader(c config, pkgName, filename string) (string, error) {
+	if c.headerTemplate == "" {
+		return generatedHeader, nil
+	}
 
-	flag.Parse()
+	tmpl, err := template.New("header").Parse(c.headerTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid -header: %v", err)
+	}
 
-	if len(flag.Args()) != 3 {
-		fmt.Println(usage)
-		flag.PrintDefaults()
-		return
+	var b strings.Builder
+	err = tmpl.Execute(&b, headerData{
+		Interface: c.interfaceName,
+		Types:     generator.JoinNames(c.typeNames),
+		Package:   pkgName,
+		Year:      time.Now().Year(),
+		dir:       filepath.Dir(filename),
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid -header: %v", err)
 	}
 
-	c := config{}
-	c.typeName = flag.Arg(0)
-	c.interfaceName = flag.Arg(1)
-	c.filename = flag.Arg(2)
-	c.printInterface = *printInterfaceFlag
-	c.writeToFile = *writeFlag
+	header := strings.TrimRight(b.String(), "\n")
+	if trimmed := strings.TrimSpace(header); strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*") {
+		return header, nil
+	}
 
-	if err := run(c); err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(1)
+	lines := strings.Split(header, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("// "+line, " ")
 	}
+
+	return strings.Join(lines, "\n"), nil
 }
 
-func run(c config) error {
+// headerData is given to the -header template
+type headerData struct {
+	Interface string
+	Types     string
+	Package   string
+	Year      int
 
-	srcBytes, err := ioutil.ReadFile(c.filename)
-	if err != nil {
-		return err
+	dir string // directory of the new file
+}
+
+// Author returns the git user.name of the repository of the new file, for copyright notices
+func (d headerData) Author() (string, error) {
+	cmd := exec.Command("git", "config", "user.name")
+	if _, err := os.Stat(d.dir); err == nil {
+		cmd.Dir = d.dir
 	}
 
-	// Format the file first. This allows us to
-	// make some assumptions later on
-	srcBytes, err = format.Source(srcBytes)
+	out, err := cmd.Output()
 	if err != nil {
-		return err
+		return "", fmt.Errorf("git config user.name: %v", err)
 	}
 
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, "", srcBytes, parser.ParseComments)
+	return strings.TrimSpace(string(out)), nil
+}
+
+// externalMethods returns the name of the -pkg package and the methods of the types it
+// declares, along with the files their imports are looked up in. The package is found
+// from the directory of the output file, as the file would import it
+func externalMethods(c config, fset *token.FileSet) (string, []*ast.FuncDecl, []*ast.File, error) {
+	dir, err := filepath.Abs(filepath.Dir(c.outputFilename))
 	if err != nil {
-		return err
+		return "", nil, nil, err
 	}
 
-	typeMethods := gatherTypeMethods(c.typeName, file)
-	interfaceMethods := generateInterfaceMethods(typeMethods)
+	pkg, err := build.Import(c.pkg, dir, 0)
+	if err != nil {
+		return "", nil, nil, err
+	}
 
-	if existing := file.Scope.Lookup(c.interfaceName); existing != nil {
-		typ := existing.Decl
-		tSpec, ok := typ.(*ast.TypeSpec)
-		if !ok {
-			return fmt.Errorf("requested interface not of type spec")
+	files := []*ast.File{}
+	methodSets := [][]*ast.FuncDecl{}
+	for _, name := range c.typeNames {
+		methods, typeFiles, err := generator.PackageMethods(fset, c.pkg, dir, name, generator.ResolveOptions{
+			Promoted: c.promoted,
+			Value:    c.valueMethodSet,
+			Checker:  cached.typeChecker(),
+		})
+		if err != nil {
+			return "", nil, nil, err
 		}
 
-		iface, ok := tSpec.Type.(*ast.InterfaceType)
-		if !ok {
-			return fmt.Errorf("desired interface type name already in use")
-		}
+		methodSets = append(methodSets, methods)
+		files = append(files, typeFiles...)
+	}
 
-		iface.Methods = mergeInterfaceMethods(iface.Methods, interfaceMethods)
+	if c.common {
+		return pkg.Name, generator.Intersect(methodSets...), files, nil
+	}
+
+	methods, err := generator.Union(methodSets...)
+	return pkg.Name, methods, files, err
+}
+
+// qualifyingPackage returns the import path of the package of the types, that of -pkg or that
+// qualifying the types of the arguments or -gen pairs, whose names it leaves unqualified
+func qualifyingPackage(pkgPath string, pairs []pair, args []string) (string, error) {
+	paths := []string{}
+	if pkgPath != "" {
+		paths = append(paths, pkgPath)
+	}
 
-		genDecl := findTopLevelGenDeclForTypeSpec(tSpec, file)
-		pos, err := firstLineOfTypeIncludingComments(c.interfaceName, file)
+	// the type and interface arguments, not the packages looked for markers
+	if (len(args) == 2 || len(args) == 3) && token.IsIdentifier(args[1]) {
+		path, typeNames, err := unqualify(strings.Split(args[0], ","))
 		if err != nil {
-			return err
+			return "", err
 		}
-		position := fset.Position(pos)
-		fmt.Println("POS", position)
-		cmap := ast.NewCommentMap(fset, file, file.Comments)
-		genDeclIndex := -1
-		for i, decl := range file.Decls {
-			if decl == genDecl {
-				genDeclIndex = i
+
+		if path != "" {
+			if len(args) == 3 {
+				return "", fmt.Errorf("%s is read from its package, not %s. Generate it into a file with -o", args[0], args[2])
 			}
-		}
 
-		if genDeclIndex == -1 {
-			return fmt.Errorf("interface declaration is not top level")
+			paths = append(paths, path)
+			args[0] = strings.Join(typeNames, ",")
 		}
+	}
 
-		file.Decls = append(file.Decls[:genDeclIndex], file.Decls[genDeclIndex+1:]...)
-		file.Comments = cmap.Filter(file).Comments()
-
-		newSrc, err := newSourceByInsertingInterfaceAtLine(genDecl, position.Line, fset, file)
+	for i, p := range pairs {
+		path, typeNames, err := unqualify(p.typeNames)
 		if err != nil {
-			return err
+			return "", err
 		}
 
-		// parse new source. this feels (and is) grossly
-		// inefficient but will suffice for now
-		fset = token.NewFileSet()
-		file, err = parser.ParseFile(fset, c.filename, newSrc, parser.ParseComments)
-		if err != nil {
-			return err
-		}
-	} else {
-		decl, _ := newInterface(c.interfaceName, interfaceMethods)
-		newSrc, err := newSourceByInsertingInterfaceAboveType(decl, c.typeName, fset, file)
-		if err != nil {
-			return err
+		if path != "" {
+			paths = append(paths, path)
+			pairs[i].typeNames = typeNames
 		}
+	}
 
-		// parse new source. this feels (and is) grossly
-		// inefficient but will suffice for now
-		fset = token.NewFileSet()
-		file, err = parser.ParseFile(fset, c.filename, newSrc, parser.ParseComments)
-		if err != nil {
-			return err
+	for _, path := range paths {
+		if path != paths[0] {
+			return "", fmt.Errorf("the types must be declared in the same package, not in both %s and %s", paths[0], path)
 		}
 	}
 
-	// Print only interface
-	if c.printInterface {
-		ifaceObj := file.Scope.Lookup(c.interfaceName)
-		if ifaceObj == nil {
-			return fmt.Errorf("could not find generated interface")
-		}
+	if len(paths) == 0 {
+		return "", nil
+	}
 
-		typ := ifaceObj.Decl
-		tSpec, ok := typ.(*ast.TypeSpec)
-		if !ok {
-			return fmt.Errorf("unexpected generated interface type")
-		}
+	return paths[0], nil
+}
 
-		decl := findTopLevelGenDeclForTypeSpec(tSpec, file)
-		if decl == nil {
-			return fmt.Errorf("could not find generated interface declaration")
+// unqualify returns the import path qualifying the types, such as github.com/me/proj/internal/db
+// of github.com/me/proj/internal/db.Store, and their names. Types that aren't qualified are
+// returned as they are with an empty path
+func unqualify(typeNames []string) (string, []string, error) {
+	path := ""
+	names := []string{}
+	for i, typeName := range typeNames {
+		dot := strings.LastIndex(typeName, ".")
+		qualified := dot > 0 && token.IsIdentifier(typeName[dot+1:])
+		if i > 0 && qualified != (path != "") || qualified && path != "" && typeName[:dot] != path {
+			return "", nil, fmt.Errorf("%s: the types must all be qualified by the same package or none of them", strings.Join(typeNames, ","))
 		}
 
-		var iSrcBuff bytes.Buffer
-		err = format.Node(&iSrcBuff, fset, decl)
-		if err != nil {
-			return err
+		if !qualified {
+			names = append(names, typeName)
+			continue
 		}
 
-		fmt.Println(iSrcBuff.String())
-		return nil
+		path = typeName[:dot]
+		names = append(names, typeName[dot+1:])
 	}
 
-	// Generate new source
+	return path, names, nil
+}
+
+// checkUpToDate returns an error describing what's out of date when the original
+// source of the file differs from the regenerated file. srcBytes is nil when
+// the file doesn't exist
+func checkUpToDate(fset *token.FileSet, file *ast.File, filename string, srcBytes []byte, interfaceNames []string) error {
 	var newSrcBuff bytes.Buffer
-	err = format.Node(&newSrcBuff, fset, file)
+	err := format.Node(&newSrcBuff, fset, file)
 	if err != nil {
 		return err
 	}
 
-	// Write it to file
-	if c.writeToFile {
-		return ioutil.WriteFile(c.filename, newSrcBuff.Bytes(), 0)
+	if srcBytes == nil {
+		return &staleError{filename: filename, interfaceNames: interfaceNames, noFile: true}
 	}
 
-	// or print it out
-	fmt.Print(newSrcBuff.String())
+	// formatting differences elsewhere in the file don't make the interface stale
+	diskFset := token.NewFileSet()
+	diskFile, err := generator.ParseFile(diskFset, filename, srcBytes)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	var diskSrcBuff bytes.Buffer
+	err = format.Node(&diskSrcBuff, diskFset, diskFile)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(diskSrcBuff.Bytes(), newSrcBuff.Bytes()) {
+		return nil
+	}
+
+	return &staleError{
+		filename:       filename,
+		interfaceNames: interfaceNames,
+		methods:        methodChanges(fset, file, diskFset, diskFile, interfaceNames),
+	}
 }
 
-// newSourceByInsertingInterfaceAboveType generates new sourcecode by inserting the interface above the specified type (or the type's comments)
-func newSourceByInsertingInterfaceAboveType(interfaceDecl *ast.GenDecl, aboveType string, fset *token.FileSet, file *ast.File) (string, error) {
-	pos, err := firstLineOfTypeIncludingComments(aboveType, file)
-	if err != nil {
-		return "", err
+// methodChanges returns the methods of the interfaces that differ between diskFile, which
+// is nil when the file doesn't exist, and the regenerated file
+func methodChanges(fset *token.FileSet, file *ast.File, diskFset *token.FileSet, diskFile *ast.File, interfaceNames []string) []staleMethod {
+	changes := []staleMethod{}
+	for _, interfaceName := range interfaceNames {
+		line := 0
+		diskMethods := map[string]interfaceMethod{}
+		if diskFile != nil {
+			if decl, err := generator.FindInterface(diskFile, interfaceName); err == nil {
+				line = diskFset.Position(decl.Pos()).Line
+			}
+
+			diskMethods = interfaceMethods(diskFset, diskFile, interfaceName)
+		}
+
+		newMethods := interfaceMethods(fset, file, interfaceName)
+		for _, name := range sortedKeys(newMethods) {
+			if disk, ok := diskMethods[name]; !ok {
+				changes = append(changes, staleMethod{interfaceName: interfaceName, problem: "missing", want: newMethods[name].signature, line: line})
+			} else if disk.signature != newMethods[name].signature {
+				changes = append(changes, staleMethod{interfaceName: interfaceName, problem: "outdated", have: disk.signature, want: newMethods[name].signature, line: disk.line})
+			}
+		}
+
+		for _, name := range sortedKeys(diskMethods) {
+			if _, ok := newMethods[name]; !ok {
+				changes = append(changes, staleMethod{interfaceName: interfaceName, problem: "extra", have: diskMethods[name].signature, line: diskMethods[name].line})
+			}
+		}
 	}
 
-	position := fset.Position(pos)
-	return newSourceByInsertingInterfaceAtLine(interfaceDecl, position.Line, fset, file)
+	return changes
 }
 
-// newSourceByInsertingInterfaceAtLine generates new sourcecode by inserting the interface at the specified line
+// outOfDate returns the subject of a message saying the interfaces are out of date
 //
-// *** here be the dragons *** Ideally, we insert the interface declaration node (and it's children) into the ast. Unforuntately,
-// handling comments properly when inserting nodes into the ast is hard. Just inserting the node naively produces some funky results.
-// To avoid all of the headaches associated with that we convert the source into a slice of lines, insert the interface at the proper
-// location and then generate a new source string for the caller to use and parse again if need be.
-func newSourceByInsertingInterfaceAtLine(interfaceDecl *ast.GenDecl, line int, fset *token.FileSet, file *ast.File) (string, error) {
-
-	// Format input file and render to a string
-	var orig bytes.Buffer
-	err := format.Node(&orig, fset, file)
+// [A] becomes "A is" and [A B] becomes "A and B are"
+func outOfDate(interfaceNames []string) string {
+	if len(interfaceNames) == 1 {
+		return interfaceNames[0] + " is"
+	}
+
+	return generator.JoinNames(interfaceNames) + " are"
+}
+
+// printDiff prints a unified diff between the original source of the file and the
+// regenerated file, followed on standard error by a summary of the methods of each
+// interface that change. srcBytes is nil when the file doesn't exist
+func printDiff(fset *token.FileSet, file *ast.File, filename string, srcBytes []byte, interfaceNames []string) error {
+	newSrc, err := newSource(fset, file, srcBytes)
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	oldName, newName := "a/"+filepath.ToSlash(filename), "b/"+filepath.ToSlash(filename)
+	if srcBytes == nil {
+		oldName = "/dev/null"
+	}
+
+	unified := diff.Unified(oldName, newName, srcBytes, newSrc)
+	if colored(os.Stdout) {
+		unified = diff.Colorize(unified)
+	}
+	os.Stdout.Write(unified)
+
+	var diskFset *token.FileSet
+	var diskFile *ast.File
+	if srcBytes != nil {
+		diskFset = token.NewFileSet()
+		if diskFile, err = generator.ParseFile(diskFset, filename, srcBytes); err != nil {
+			return err
+		}
+	}
+
+	printSummary(interfaceNames, methodChanges(fset, file, diskFset, diskFile, interfaceNames))
+
+	return nil
+}
+
+// newSource returns the source of the file generated from srcBytes, its original source.
+// Only what was generated differs from srcBytes, the rest is left as it was written
+func newSource(fset *token.FileSet, file *ast.File, srcBytes []byte) ([]byte, error) {
+	var newSrcBuff bytes.Buffer
+	if err := format.Node(&newSrcBuff, fset, file); err != nil {
+		return nil, err
 	}
-	origSrc := orig.String()
 
-	// Split into lines
-	lines := strings.Split(origSrc, "\n")
+	return generator.PreserveFormatting(srcBytes, newSrcBuff.Bytes())
+}
 
-	// convert to index
-	lineIndex := line - 1
+// textEdit replaces the bytes [Start, End) of File with New
+type textEdit struct {
+	File  string `json:"file"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	New   string `json:"new"`
+}
 
-	// Render our interface into a string
-	var iBuf bytes.Buffer
-	err = format.Node(&iBuf, fset, interfaceDecl)
+// printJSONEdits prints the edits turning the original source of the file into the
+// regenerated file as a json list. srcBytes is nil when the file doesn't exist
+func printJSONEdits(fset *token.FileSet, file *ast.File, filename string, srcBytes []byte) error {
+	newSrc, err := newSource(fset, file, srcBytes)
 	if err != nil {
-		return "", err
+		return err
 	}
-	iSrc := iBuf.String() + "\n"
 
-	if lineIndex > len(lines) { // this should never happen in theory
-		lines = append(lines, iSrc)
-	} else {
-		lines = append(lines[:lineIndex], append([]string{iSrc}, lines[lineIndex:]...)...)
+	edits := []textEdit{}
+	for _, edit := range diff.Edits(srcBytes, newSrc) {
+		edits = append(edits, textEdit{File: filename, Start: edit.Start, End: edit.End, New: edit.New})
+	}
+
+	out, err := json.MarshalIndent(edits, "", "\t")
+	if err != nil {
+		return err
 	}
 
-	newSrc := strings.Join(lines, "\n")
+	fmt.Println(string(out))
 
-	return newSrc, nil
+	return nil
 }
 
-// firstLineOfTypeIncludingComments returns the first line of the type including its comments.
-// for example, given the following type declaration
-//
-// 1: // comment
-// 2: // comment
-// 3: type test string
-//
-// a token.Pos for line 1 would be returned
-func firstLineOfTypeIncludingComments(typeName string, file *ast.File) (token.Pos, error) {
-	// Find the object for the type
-	typeObj := file.Scope.Lookup(typeName)
-	if typeObj == nil || typeObj.Pos().IsValid() == false {
-		return token.NoPos, fmt.Errorf("invalid type")
+// interfaceMethod is a method of an interface
+type interfaceMethod struct {
+	signature string
+	line      int
+}
+
+// interfaceMethods returns the methods of the named interface keyed by method name
+func interfaceMethods(fset *token.FileSet, file *ast.File, interfaceName string) map[string]interfaceMethod {
+	methods := make(map[string]interfaceMethod)
+
+	decl, err := generator.FindInterface(file, interfaceName)
+	if err != nil {
+		return methods
 	}
 
-	// Make sure it's a type
-	typeSpec, ok := typeObj.Decl.(*ast.TypeSpec)
+	iface, ok := decl.Specs[0].(*ast.TypeSpec).Type.(*ast.InterfaceType)
 	if !ok {
-		return token.NoPos, fmt.Errorf("expected a type spec but received %v", reflect.TypeOf(typeObj.Decl))
+		return methods
 	}
 
-	// Find the ast.GenDecl for the type. We do this because
-	// doc comments for a type are associated with the ast.GenDecl for the type
-	genDecl := findTopLevelGenDeclForTypeSpec(typeSpec, file)
-	if genDecl == nil {
-		return token.NoPos, fmt.Errorf("could not find GenDecl for type")
+	for _, field := range iface.Methods.List {
+		if len(field.Names) == 0 {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, field.Type); err != nil {
+			continue
+		}
+
+		name := field.Names[0].Name
+		methods[name] = interfaceMethod{
+			signature: name + strings.TrimPrefix(buf.String(), "func"),
+			line:      fset.Position(field.Pos()).Line,
+		}
 	}
 
-	// The position to insert at is either the line at which type occurs (ast.GenDecl)
-	// or the first line of the comments above the type declaration
-	pos := genDecl.Pos()
-	if genDecl.Doc != nil {
-		pos = genDecl.Doc.Pos()
+	return methods
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	return pos, nil
+	return keys
 }
 
-// Find the top level ast.GenDecl for the given ast.TypeSpec
-func findTopLevelGenDeclForTypeSpec(typeSpec *ast.TypeSpec, file *ast.File) *ast.GenDecl {
-	var genDecl *ast.GenDecl
-	for _, decl := range file.Decls {
-		if gen, ok := decl.(*ast.GenDecl); ok {
-			if gen.Specs[0] == typeSpec {
-				genDecl = gen
-			}
-		}
+// readSource reads the go source file or standard input when the filename is -
+func readSource(filename string, o overlay) ([]byte, error) {
+	if filename == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+
+	return o.readFile(filename)
+}
+
+// sourceName returns the name used for the source file in messages
+func sourceName(filename string) string {
+	if filename == "-" {
+		return "<standard input>"
+	}
+
+	return filename
+}
+
+// parsePackage returns the file along with the other files of its package that
+// match the current build context. A file read from standard input is on its own
+func parsePackage(fset *token.FileSet, file *ast.File, filename string, o overlay) ([]*ast.File, error) {
+	files := []*ast.File{file}
+	if filename == "-" {
+		return files, nil
+	}
+
+	others, err := parseDir(fset, filepath.Dir(filename), o, filepath.Base(filename))
+	if err != nil {
+		return nil, err
 	}
 
-	return genDecl
+	return append(files, others...), nil
 }
 
-// gatherTypeMethods returns all of the *ast.FuncDecl for a given type
-func gatherTypeMethods(typeName string, file *ast.File) []*ast.FuncDecl {
-	methods := []*ast.FuncDecl{}
-	ast.Inspect(file, func(x ast.Node) bool {
-		f, ok := x.(*ast.FuncDecl)
-		if !ok {
-			return true
+// parseFiles returns the file along with the other files given with it, which stand
+// for its package, leaving out the rest of the package such as generated files
+func parseFiles(fset *token.FileSet, file *ast.File, filename string, others []string, o overlay) ([]*ast.File, error) {
+	files := []*ast.File{file}
+	for _, other := range others {
+		if !sameDir(filepath.Dir(other), filepath.Dir(filename)) {
+			return nil, fmt.Errorf("%s is not in the package of %s", other, filename)
 		}
 
-		if f.Recv == nil { //function
-			return false
+		srcBytes, err := o.readFile(other)
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := cached.parse(fset, other, srcBytes)
+		if err != nil {
+			return nil, err
 		}
 
-		if len(f.Recv.List) != 1 {
-			return false // this should never happen, there should only be one receiver
+		if f.Name.Name != file.Name.Name {
+			return nil, fmt.Errorf("%s is in package %s, not %s like %s", other, f.Name.Name, file.Name.Name, filename)
 		}
 
-		typ := f.Recv.List[0].Type
-		ident, ok := typ.(*ast.Ident)
-		if !ok {
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// goFiles reports whether the arguments are all go files
+func goFiles(args []string) bool {
+	for _, arg := range args {
+		if !strings.HasSuffix(arg, ".go") {
 			return false
 		}
+	}
+
+	return true
+}
+
+// packagePatterns reports whether the arguments all look like package patterns, such as
+// ./store/..., rather than the type and interface names of an incomplete command line
+func packagePatterns(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../") || filepath.IsAbs(arg) || strings.Contains(arg, "...") {
+			continue
+		}
 
-		if typeName == ident.String() {
-			methods = append(methods, f)
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			continue
 		}
 
 		return false
-	})
+	}
 
-	return methods
+	return true
 }
 
-// generateInterfaceMethods generates a ast.FieldList suitable for use of as the Methods of an ast.InterfaceType
-func generateInterfaceMethods(funcDecls []*ast.FuncDecl) *ast.FieldList {
-	fl := &ast.FieldList{}
+// parseDir parses the files of the package in dir that match the current
+// build context, leaving out the file named except when it isn't empty
+func parseDir(fset *token.FileSet, dir string, o overlay, except string) ([]*ast.File, error) {
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, decl := range funcDecls {
-		field := &ast.Field{}
-		name := dupIdent(decl.Name)
-		name.Obj = ast.NewObj(ast.Fun, name.Name) // a FuncDecl's name doesn't have an object but a field's name does
-		name.Obj.Decl = field
-		field.Names = append(field.Names, name)
+	files := []*ast.File{}
+	for _, name := range append(pkg.GoFiles, pkg.CgoFiles...) {
+		if name == except {
+			continue
+		}
 
-		funcType := dupFuncType(decl.Type)
+		path := filepath.Join(dir, name)
+		srcBytes, err := o.readFile(path)
+		if err != nil {
+			return nil, err
+		}
 
-		// erase the names of any named returns
-		// since they don't really make
-		// sense for interfaces
-		if funcType.Results != nil {
-			for _, r := range funcType.Results.List {
-				r.Names = nil
-			}
+		f, err := cached.parse(fset, path, srcBytes)
+		if err != nil {
+			return nil, err
 		}
 
-		field.Type = funcType
-		fl.List = append(fl.List, field)
+		files = append(files, f)
 	}
 
-	return fl
+	return files, nil
 }
 
-// mergeInterfaceMethods merges two FieldLists of interface methods
-// into a new FieldList. If a method with the same name exists
-// in both FieldLists, the right one wins.
-//
-func mergeInterfaceMethods(left, right *ast.FieldList) *ast.FieldList {
-	new := &ast.FieldList{}
-	names := make(map[string]bool)
-	for _, field := range right.List {
-		if len(field.Names) == 0 { // shouldn't happen
-			continue
+// interfaceFile returns the file of the type's package declaring the interface other than the
+// file it is generated into, or nil when that file declares it or no file does, so an interface
+// is updated wherever it is declared in the package, the type's file included with an output
+// file of the package. Only a single interface is looked for, and only in the files mentioning
+// its name. Files that can't be read or parsed are passed over, they have nothing to do with
+// the interface as far as the command is concerned
+func interfaceFile(c config, fset *token.FileSet, file *ast.File) (*ast.File, error) {
+	if c.filename == "-" || c.pkg != "" || len(c.pairs) > 0 || c.groups || c.cluster {
+		return nil, nil
+	}
+
+	// an output file of another package, test packages included, is looked in on its own
+	if c.outputFilename != "" && (!sameDir(filepath.Dir(c.outputFilename), filepath.Dir(c.filename)) || strings.HasSuffix(c.outputFilename, "_test.go")) {
+		return nil, nil
+	}
+
+	if file.Scope.Lookup(c.interfaceName) != nil {
+		if _, err := generator.FindInterface(file, c.interfaceName); err == nil && !generator.NameTaken(file, c.interfaceName) && c.outputFilename != "" {
+			return file, nil
 		}
 
-		names[field.Names[0].Name] = true
-		new.List = append(new.List, field)
+		return nil, nil
+	}
+
+	dir := filepath.Dir(c.filename)
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		// a file outside of a package only has itself to look in
+		return nil, nil
 	}
 
-	for _, field := range left.List {
-		if len(field.Names) == 0 { // shouldn't happen
+	for _, name := range append(pkg.GoFiles, pkg.CgoFiles...) {
+		if name == filepath.Base(c.filename) || c.outputFilename != "" && name == filepath.Base(c.outputFilename) {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		srcBytes, err := c.overlay.readFile(path)
+		if err != nil || !bytes.Contains(srcBytes, []byte(c.interfaceName)) {
+			continue
+		}
+
+		f, err := cached.parse(fset, path, srcBytes)
+		if err != nil {
+			logger.Debug("passing over a file that doesn't parse", "file", path, "error", err)
 			continue
 		}
 
-		if names[field.Names[0].Name] == false {
-			new.List = append(new.List, field)
+		// a type other than an interface taking the name is a conflict, not a declaration to update
+		if _, err := generator.FindInterface(f, c.interfaceName); err == nil && !generator.NameTaken(f, c.interfaceName) {
+			return f, nil
 		}
 	}
 
-	return new
+	return nil, nil
 }
 
-func newInterface(name string, methods *ast.FieldList) (*ast.GenDecl, *ast.TypeSpec) {
+// packageFiles returns the other files of the package of the file generated into, whose
+// declarations share its package scope. Test files are only included for a test file of
+// the package. Like with interfaceFile, files that can't be read or parsed are passed over
+func packageFiles(c config, fset *token.FileSet, file *ast.File, filename string) []*ast.File {
+	if filename == "-" {
+		return nil
+	}
+
+	dir := filepath.Dir(filename)
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil
+	}
 
-	// given:
-	//
-	// type someInterface interface {
-	//     MethodOne()
-	//     MethodTwo()
-	// }
-	//
+	names := append(pkg.GoFiles, pkg.CgoFiles...)
+	if strings.HasSuffix(filename, "_test.go") {
+		names = append(names, pkg.TestGoFiles...)
+	}
 
-	// type
-	decl := &ast.GenDecl{Tok: token.TYPE}
+	files := []*ast.File{}
+	for _, name := range names {
+		if name == filepath.Base(filename) {
+			continue
+		}
 
-	//  someInterface
-	tSpec := &ast.TypeSpec{}
-	tSpec.Name = &ast.Ident{Name: name}
-	tSpec.Name.Obj = ast.NewObj(ast.Typ, name)
-	tSpec.Name.Obj.Decl = tSpec
+		path := filepath.Join(dir, name)
+		srcBytes, err := c.overlay.readFile(path)
+		if err != nil {
+			continue
+		}
 
-	decl.Specs = []ast.Spec{tSpec}
+		f, err := cached.parse(fset, path, srcBytes)
+		if err != nil {
+			logger.Debug("passing over a file that doesn't parse", "file", path, "error", err)
+			continue
+		}
 
-	// interface {
-	//     MethodOne()
-	//     MethodTwo()
-	// }
-	iType := &ast.InterfaceType{
-		Methods: methods,
+		if f.Name.Name == file.Name.Name {
+			files = append(files, f)
+		}
 	}
 
-	tSpec.Type = iType
-
-	return decl, tSpec
+	return files
 }
 
-func dupFuncType(old *ast.FuncType) *ast.FuncType {
-	if old == nil {
-		return nil
+// targetFilename returns the file the interface of c is written to: the output file, the
+// source file or the file of the package already declaring the interface. A source file
+// that can't be read or parsed is returned as it is, generating reports what's wrong with it
+func targetFilename(c config) string {
+	target := c.filename
+	if c.outputFilename != "" {
+		target = c.outputFilename
+	}
+
+	if c.pkg != "" || c.filename == "-" {
+		return target
+	}
+
+	fset := cached.fileSet()
+	srcBytes, err := readSource(c.filename, c.overlay)
+	if err != nil {
+		return target
+	}
+
+	file, err := cached.parse(fset, sourceName(c.filename), srcBytes)
+	if err != nil {
+		return target
 	}
 
-	new := &ast.FuncType{}
-	new.Params = dupFieldList(old.Params)
-	new.Results = dupFieldList(old.Results)
+	if declaring, err := interfaceFile(c, fset, file); err == nil && declaring != nil {
+		return fset.Position(declaring.Package).Filename
+	}
 
-	return new
+	return target
 }
 
-func dupFieldList(old *ast.FieldList) *ast.FieldList {
-	if old == nil {
-		return nil
+// outputPackageName returns the name of the package the interface is generated into,
+// which differs from srcPkgName when the output file belongs to another package
+func outputPackageName(c config, srcPkgName string) (string, error) {
+	if c.outputFilename == "" {
+		return srcPkgName, nil
+	}
+
+	srcBytes, err := readOutputFile(c.outputFilename, c.overlay)
+	if err != nil {
+		return "", err
 	}
 
-	new := &ast.FieldList{}
+	if srcBytes != nil {
+		file, err := parser.ParseFile(token.NewFileSet(), c.outputFilename, srcBytes, parser.PackageClauseOnly)
+		if err != nil {
+			return "", err
+		}
+
+		if c.outPackage != "" && file.Name.Name != c.outPackage {
+			return "", fmt.Errorf("%s belongs to package %s, not %s", c.outputFilename, file.Name.Name, c.outPackage)
+		}
 
-	for _, oldField := range old.List {
-		new.List = append(new.List, dupField(oldField))
+		return file.Name.Name, nil
 	}
 
-	return new
-}
+	if c.outPackage != "" {
+		return c.outPackage, nil
+	}
 
-// dupField duplicates an ast.Field ignoring position information.
-// this is written specifically for copying fields that are
-// a part of an ast.InterfaceType's Method list or a
-// ast.FuncType's Params and Results
-func dupField(old *ast.Field) *ast.Field {
-	if old == nil {
-		return nil
+	if c.externalTest {
+		return srcPkgName + "_test", nil
+	}
+
+	// a new file belongs to the package in its directory
+	srcDir, err := filepath.Abs(filepath.Dir(c.filename))
+	if err != nil {
+		return "", err
 	}
 
-	new := &ast.Field{}
+	outDir, err := filepath.Abs(filepath.Dir(c.outputFilename))
+	if err != nil {
+		return "", err
+	}
 
-	switch t := old.Type.(type) {
-	case *ast.Ident:
-		new.Type = dupIdent(t)
-	case *ast.FuncType:
-		new.Type = dupFuncType(t)
-	default:
-		fmt.Println("unsuporrted field type")
+	if outDir == srcDir && c.pkg == "" {
+		return srcPkgName, nil
 	}
 
-	for _, oldName := range old.Names {
-		newName := dupIdent(oldName)
-		newName.Obj.Decl = new
-		new.Names = append(new.Names, newName)
+	if pkg, err := build.ImportDir(outDir, 0); err == nil {
+		return pkg.Name, nil
 	}
 
-	return new
+	// or to a new package named after the directory
+	return filepath.Base(outDir), nil
 }
 
-// dupIdent duplicates an ast.Ident ignoring position information
-func dupIdent(old *ast.Ident) *ast.Ident {
-	if old == nil {
-		return nil
+// readOutputFile reads the output file. A file that does not exist yet is read as nil
+func readOutputFile(filename string, o overlay) ([]byte, error) {
+	srcBytes, err := o.readFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
 	}
 
-	new := ast.NewIdent(old.Name)
-	new.Obj = dupObject(old.Obj)
-
-	return new
+	return srcBytes, err
 }
 
-func dupObject(old *ast.Object) *ast.Object {
-	if old == nil {
-		return nil
+// typeDeclaredAfterLine returns the name of the first type declared after the line.
+// The interface is skipped since it is inserted above the type once generated
+func typeDeclaredAfterLine(fset *token.FileSet, file *ast.File, line int, interfaceName string) (string, error) {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			name := spec.(*ast.TypeSpec).Name.Name
+			if name != interfaceName && fset.Position(spec.Pos()).Line > line {
+				return name, nil
+			}
+		}
 	}
 
-	return ast.NewObj(old.Kind, old.Name)
+	return "", fmt.Errorf("no type declared after line %d", line)
 }
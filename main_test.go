@@ -1 +1,523 @@
 package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// writeFiles writes the files, keyed by their paths relative to a new temporary directory,
+// and returns the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeFilesIn(t, dir, files)
+	return dir
+}
+
+// writeFilesIn writes the files, keyed by their paths relative to dir
+func writeFilesIn(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFile returns the contents of the file
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+// captureStdout returns what f prints to standard out along with its error
+func captureStdout(t *testing.T, f func() error) (string, error) {
+	t.Helper()
+	return capture(t, &os.Stdout, f)
+}
+
+// capture returns what f writes to the file, standard out or error, while it runs
+func capture(t *testing.T, file **os.File, f func() error) (string, error) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orig := *file
+	*file = w
+	defer func() { *file = orig }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+
+	err = f()
+	w.Close()
+
+	return <-out, err
+}
+
+// writeConfig returns the configuration writing the interface of the type in the file
+// back to it with the command's defaults
+func writeConfig(typeName, interfaceName, filename string) config {
+	return config{
+		typeNames:     strings.Split(typeName, ","),
+		interfaceName: interfaceName,
+		filename:      filename,
+		ignoreTag:     generator.DefaultIgnoreTag,
+		docs:          true,
+		writeToFile:   true,
+	}
+}
+
+func TestRunInterfaceInOtherFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		want     map[string]string // contents of the files after the run
+		contains map[string]string // or text the files contain
+	}{
+		{
+			name: "updated where declared",
+			files: map[string]string{
+				"store.go":  "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\nfunc (s *Store) Put(v int) {}\n",
+				"ifaces.go": "package p\n\n// Storer stores.\ntype Storer interface {\n\tGet() int\n}\n",
+			},
+			want: map[string]string{
+				"store.go":  "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\nfunc (s *Store) Put(v int) {}\n",
+				"ifaces.go": "package p\n\n// Storer stores.\ntype Storer interface {\n\tGet() int\n\tPut(v int)\n}\n",
+			},
+		},
+		{
+			name: "a broken file mentioning it is passed over",
+			files: map[string]string{
+				"store.go":  "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+				"broken.go": "package p\n\n// Storer\nfunc oops( {\n",
+			},
+			contains: map[string]string{
+				"store.go":  "type Storer interface {\n\tGet() int\n}",
+				"broken.go": "func oops( {",
+			},
+		},
+		{
+			name: "an unrelated broken file doesn't matter",
+			files: map[string]string{
+				"store.go":  "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+				"broken.go": "package p\n\nfunc oops( {\n",
+			},
+			contains: map[string]string{
+				"store.go": "type Storer interface {\n\tGet() int\n}",
+			},
+		},
+		{
+			name: "a method of the same name isn't the interface",
+			files: map[string]string{
+				"store.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+				"other.go": "package p\n\ntype T struct{}\n\nfunc (T) Storer() {}\n",
+			},
+			contains: map[string]string{
+				"store.go": "type Storer interface {\n\tGet() int\n}",
+				"other.go": "func (T) Storer() {}",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, test.files)
+			if _, err := run(writeConfig("Store", "Storer", filepath.Join(dir, "store.go"))); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range test.want {
+				if got := readFile(t, filepath.Join(dir, name)); got != want {
+					t.Errorf("%s:\n%s\nwant:\n%s", name, got, want)
+				}
+			}
+
+			for name, want := range test.contains {
+				if got := readFile(t, filepath.Join(dir, name)); !strings.Contains(got, want) {
+					t.Errorf("%s:\n%s\nwant it to contain:\n%s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPackagePatterns(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"store/store.go": "package store\n"})
+	t.Chdir(dir)
+
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"./..."}, true},
+		{[]string{"./store", "../other"}, true},
+		{[]string{dir}, true},
+		{[]string{"example.com/store/..."}, true},
+		{[]string{"store"}, true},
+		{[]string{"."}, true},
+		{[]string{"Store", "Storer"}, false},
+		{[]string{"Store"}, false},
+		{[]string{"./store", "Storer"}, false},
+	}
+
+	for _, test := range tests {
+		if got := packagePatterns(test.args); got != test.want {
+			t.Errorf("packagePatterns(%q) = %v, want %v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestRunOptions(t *testing.T) {
+	year := strconv.Itoa(time.Now().Year())
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		config   func(dir string) config
+		contains map[string]string // text the files contain after the run
+		absent   map[string]string // or text they don't
+	}{
+		{
+			name: "gen pairs",
+			files: map[string]string{
+				"store.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n\ntype Cache struct{}\n\nfunc (c *Cache) Put(v int) {}\n",
+			},
+			config: func(dir string) config {
+				c := writeConfig("", "", filepath.Join(dir, "store.go"))
+				c.typeNames = nil
+				c.pairs = []pair{{[]string{"Store"}, "Storer"}, {[]string{"Store", "Cache"}, "StoreCache"}}
+				return c
+			},
+			contains: map[string]string{
+				"store.go": "type Storer interface {\n\tGet() int\n}\n\n// StoreCache is the interface implemented by Store and Cache.\ntype StoreCache interface {\n\tGet() int\n\tPut(v int)\n}",
+			},
+		},
+		{
+			name: "several files of the package",
+			files: map[string]string{
+				"store.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+				"more.go":  "package p\n\nfunc (s *Store) Put(v int) {}\n",
+				"other.go": "package p\n\nfunc (s *Store) Delete() {}\n",
+			},
+			config: func(dir string) config {
+				c := writeConfig("Store", "Storer", filepath.Join(dir, "store.go"))
+				c.files = []string{filepath.Join(dir, "more.go")}
+				return c
+			},
+			contains: map[string]string{
+				"store.go": "type Storer interface {\n\tGet() int\n\tPut(v int)\n}",
+			},
+			absent: map[string]string{"store.go": "Delete"},
+		},
+		{
+			name: "type of another package",
+			files: map[string]string{
+				"go.mod": "module example.com/p\n",
+				"p.go":   "package p\n",
+			},
+			config: func(dir string) config {
+				c := writeConfig("Reader", "StringReader", "")
+				c.pkg = "strings"
+				c.outputFilename = filepath.Join(dir, "iface.go")
+				return c
+			},
+			contains: map[string]string{
+				"iface.go": "package p\n\nimport \"io\"\n\n// StringReader is the interface implemented by strings.Reader.\ntype StringReader interface {",
+			},
+		},
+		{
+			name: "postprocess",
+			files: map[string]string{
+				"store.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+			},
+			config: func(dir string) config {
+				c := writeConfig("Store", "Storer", filepath.Join(dir, "store.go"))
+				c.postprocess = "sed -e s/Storer/Keeper/g"
+				return c
+			},
+			contains: map[string]string{
+				"store.go": "type Keeper interface {\n\tGet() int\n}",
+			},
+			absent: map[string]string{"store.go": "Storer"},
+		},
+		{
+			name: "plain text header",
+			files: map[string]string{
+				"store.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+			},
+			config: func(dir string) config {
+				c := writeConfig("Store", "Storer", filepath.Join(dir, "store.go"))
+				c.outputFilename = filepath.Join(dir, "iface.go")
+				c.assertTestFile = filepath.Join(dir, "iface_test.go")
+				c.headerTemplate = "Copyright {{.Year}} Acme\n\nLicensed under the MIT license.\n"
+				return c
+			},
+			contains: map[string]string{
+				"iface.go":      "// Copyright " + year + " Acme\n//\n// Licensed under the MIT license.\n\npackage p\n",
+				"iface_test.go": "// Copyright " + year + " Acme\n//\n// Licensed under the MIT license.\n\npackage p\n",
+			},
+		},
+		{
+			name: "commented header",
+			files: map[string]string{
+				"store.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+			},
+			config: func(dir string) config {
+				c := writeConfig("Store", "Storer", filepath.Join(dir, "store.go"))
+				c.outputFilename = filepath.Join(dir, "iface.go")
+				c.headerTemplate = "/* {{.Interface}} of {{.Types}} in {{.Package}} */\n"
+				return c
+			},
+			contains: map[string]string{
+				"iface.go": "/* Storer of Store in p */\n\npackage p\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, test.files)
+			t.Chdir(dir)
+
+			if _, err := run(test.config(dir)); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range test.contains {
+				if got := readFile(t, filepath.Join(dir, name)); !strings.Contains(got, want) {
+					t.Errorf("%s:\n%s\nwant it to contain:\n%s", name, got, want)
+				}
+			}
+
+			for name, unwanted := range test.absent {
+				if got := readFile(t, filepath.Join(dir, name)); strings.Contains(got, unwanted) {
+					t.Errorf("%s:\n%s\nwant it not to contain:\n%s", name, got, unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestQualifyingPackage(t *testing.T) {
+	tests := []struct {
+		pkg      string
+		pairs    []pair
+		args     []string
+		want     string
+		wantArgs []string
+		wantErr  string
+	}{
+		{args: []string{"Store", "Storer", "store.go"}, wantArgs: []string{"Store", "Storer", "store.go"}},
+		{args: []string{"example.com/p/db.Store", "Storer"}, want: "example.com/p/db", wantArgs: []string{"Store", "Storer"}},
+		{args: []string{"example.com/p/db.Store,example.com/p/db.Cache", "Storer"}, want: "example.com/p/db", wantArgs: []string{"Store,Cache", "Storer"}},
+		{pkg: "database/sql", args: []string{"DB", "DBIface"}, want: "database/sql", wantArgs: []string{"DB", "DBIface"}},
+		{pairs: []pair{{[]string{"example.com/p/db.Store"}, "Storer"}}, args: []string{}, want: "example.com/p/db", wantArgs: []string{}},
+		{args: []string{"example.com/p/db.Store", "Storer", "store.go"}, wantErr: "Generate it into a file with -o"},
+		{args: []string{"example.com/p/db.Store,Cache", "Storer"}, wantErr: "qualified by the same package or none of them"},
+		{pkg: "database/sql", args: []string{"example.com/p/db.Store", "Storer"}, wantErr: "declared in the same package"},
+	}
+
+	for _, test := range tests {
+		got, err := qualifyingPackage(test.pkg, test.pairs, test.args)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("qualifyingPackage(%q, %q): error %v, want %s", test.pkg, test.args, err, test.wantErr)
+			}
+			continue
+		}
+
+		if err != nil || got != test.want || !reflect.DeepEqual(test.args, test.wantArgs) {
+			t.Errorf("qualifyingPackage(%q, %q) = %q, %v, want %q with args %q", test.pkg, test.args, got, err, test.want, test.wantArgs)
+		}
+
+		for _, p := range test.pairs {
+			if strings.Contains(strings.Join(p.typeNames, ","), ".") {
+				t.Errorf("pair %v left qualified", p)
+			}
+		}
+	}
+}
+
+func TestRunUnexportedIntoAnotherPackage(t *testing.T) {
+	files := map[string]string{
+		"go.mod":     "module example.com/p\n",
+		"a/store.go": "package a\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n\nfunc (s *Store) reset() {}\n",
+		"b/b.go":     "package b\n",
+	}
+
+	tests := []struct {
+		name           string
+		skipUnexported bool
+		wantErr        string
+		want           string
+	}{
+		{name: "error", wantErr: "unexported methods and methods referencing unexported identifiers can't be declared in package b:\n\treset is unexported"},
+		{name: "skip", skipUnexported: true, want: "type Storer interface {\n\tGet() int\n}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, files)
+			t.Chdir(dir)
+
+			c := writeConfig("Store", "Storer", filepath.Join(dir, "a", "store.go"))
+			c.outputFilename = filepath.Join(dir, "b", "iface.go")
+			c.skipUnexported = test.skipUnexported
+
+			_, err := capture(t, &os.Stderr, func() error { _, err := run(c); return err })
+			if test.wantErr != "" {
+				var d *generator.Diagnostic
+				if !errors.As(err, &d) || d.Code != generator.CodeUnexported || d.Ident != "reset" || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error %v, want %s", err, test.wantErr)
+				}
+
+				if _, err := os.Stat(c.outputFilename); !os.IsNotExist(err) {
+					t.Errorf("wrote %s, want nothing written", c.outputFilename)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readFile(t, c.outputFilename); !strings.Contains(got, test.want) || strings.Contains(got, "reset") {
+				t.Errorf("b/iface.go:\n%s\nwant it to contain only:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRunNameTakenInAnotherFile(t *testing.T) {
+	files := map[string]string{
+		"a.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+		"b.go": "package p\n\ntype Storer struct{}\n\ntype Storer2 int\n",
+	}
+
+	tests := []struct {
+		name           string
+		renameConflict bool
+		wantErr        string
+		want           string
+	}{
+		{name: "error", wantErr: "Storer is already declared in b.go of the same package"},
+		{name: "rename", renameConflict: true, want: "type Storer3 interface {\n\tGet() int\n}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, files)
+
+			c := writeConfig("Store", "Storer", filepath.Join(dir, "a.go"))
+			c.renameConflict = test.renameConflict
+
+			_, err := capture(t, &os.Stderr, func() error { _, err := run(c); return err })
+			if test.wantErr != "" {
+				var d *generator.Diagnostic
+				if !errors.As(err, &d) || d.Code != generator.CodeNameTaken || filepath.Base(d.Pos.Filename) != "b.go" || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error %v, want %s reported in b.go", err, test.wantErr)
+				}
+
+				if got := readFile(t, c.filename); strings.Contains(got, "interface") {
+					t.Errorf("a.go:\n%s\nwant nothing written", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readFile(t, c.filename); !strings.Contains(got, test.want) {
+				t.Errorf("a.go:\n%s\nwant it to contain:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRunOutputFileOfThePackage(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		contains map[string]string // text the files contain after the run
+		absent   []string          // files that aren't written
+	}{
+		{
+			name: "declared in another file",
+			files: map[string]string{
+				"a.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+				"b.go": "package p\n\n// Storer stores.\ntype Storer interface {\n}\n",
+			},
+			contains: map[string]string{"b.go": "type Storer interface {\n\tGet() int\n}"},
+			absent:   []string{"new.go"},
+		},
+		{
+			name: "declared in the type's file",
+			files: map[string]string{
+				"a.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n\n// Storer stores.\ntype Storer interface {\n}\n",
+			},
+			contains: map[string]string{"a.go": "type Storer interface {\n\tGet() int\n}"},
+			absent:   []string{"new.go"},
+		},
+		{
+			name: "declared nowhere",
+			files: map[string]string{
+				"a.go": "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+			},
+			contains: map[string]string{"new.go": "type Storer interface {\n\tGet() int\n}"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, test.files)
+
+			c := writeConfig("Store", "Storer", filepath.Join(dir, "a.go"))
+			c.outputFilename = filepath.Join(dir, "new.go")
+			if _, err := run(c); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range test.contains {
+				if got := readFile(t, filepath.Join(dir, name)); !strings.Contains(got, want) {
+					t.Errorf("%s:\n%s\nwant it to contain:\n%s", name, got, want)
+				}
+			}
+
+			for _, name := range test.absent {
+				if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+					t.Errorf("wrote %s, want the interface declared once", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// runMarkers generates the interface described by every type marker found in the packages
+// matching the patterns, such as ./...
+//
+//	//gointerfacegen:interface=UserStore output=store_iface.go
+//	type UserStore struct{}
+//
+// A marker's options are the keys of a config entry of the generate subcommand
+// and its output is relative to the directory of the type
+func runMarkers(patterns []string, check, dryRun bool, jobs int, report string) error {
+	dirs, err := listPackages(patterns)
+	if err != nil {
+		return err
+	}
+
+	// the packages of several interfaces are parsed and type checked once
+	cached = newFileCache(true)
+
+	// keep going so every invalid marker is reported
+	failed := false
+	configs := []config{}
+	for _, path := range sortedKeys(dirs) {
+		dir := dirs[path]
+
+		fset := cached.fileSet()
+		files, err := parseDir(fset, dir, nil, "")
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			filename := fset.Position(file.Package).Filename
+			for _, marker := range generator.TypeMarkers(file) {
+				c, err := markerConfig(marker, dir, filepath.Base(filename))
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+					failed = true
+					continue
+				}
+
+				configs = append(configs, c)
+			}
+		}
+	}
+
+	if len(configs) == 0 && !failed {
+		return fmt.Errorf("no gointerfacegen markers found in %v", patterns)
+	}
+
+	return runConfigs(strings.Join(patterns, " "), configs, failed, check, dryRun, jobs, report)
+}
+
+// markerConfig returns the configuration generating the marker's interface
+// for the type declared in the file in dir
+func markerConfig(marker generator.TypeMarker, dir, filename string) (config, error) {
+	entry, err := markerEntry(marker.Options)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid marker on %s: %v", marker.TypeName, err)
+	}
+
+	entry.Type = marker.TypeName
+	entry.File = filename
+
+	return entry.config(dir)
+}
+
+// markerEntry returns the config entry with the options of a marker
+func markerEntry(markerOptions map[string]string) (batchEntry, error) {
+	// round trip the options through json so they decode exactly like a config entry
+	// only the values of boolean options are booleans, type=T names a type, and likewise for numbers
+	boolOptions := make(map[string]bool)
+	intOptions := make(map[string]bool)
+	entryType := reflect.TypeOf(batchEntry{})
+	for i := 0; i < entryType.NumField(); i++ {
+		field := entryType.Field(i)
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		switch {
+		case field.Type.Kind() == reflect.Bool || field.Type == reflect.TypeOf((*bool)(nil)):
+			boolOptions[key] = true
+		case field.Type.Kind() == reflect.Int || field.Type == reflect.TypeOf((*int)(nil)):
+			intOptions[key] = true
+		}
+	}
+
+	options := make(map[string]interface{})
+	for key, value := range markerOptions {
+		if b, err := strconv.ParseBool(value); err == nil && boolOptions[key] {
+			options[key] = b
+		} else if n, err := strconv.Atoi(value); err == nil && intOptions[key] {
+			options[key] = n
+		} else {
+			options[key] = value
+		}
+	}
+
+	data, err := json.Marshal(options)
+	if err != nil {
+		return batchEntry{}, err
+	}
+
+	entry := batchEntry{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&entry); err != nil {
+		return batchEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// runSync runs the sync subcommand, regenerating every interface whose
+// doc comment records its source in a marker found in the packages
+//
+//	//gointerfacegen:source file=store.go type=UserStore
+//	type Store interface {
+func runSync(args []string) error {
+	flags := newFlagSet("sync")
+	checkFlag := flags.Bool("check", false, "Check that the interfaces on disk are up to date and exit non-zero if any is not. Nothing is written")
+	dryRunFlag := flags.Bool("dry-run", false, "Print whether each interface would be created, updated, with the methods added, changed and removed, or left unchanged. Nothing is written")
+	sinceFlag := flags.String("since", "", "Only regenerate the interfaces whose source or own file changed since this git ref, such as origin/main, uncommitted changes included")
+	jobsFlag := flags.Int("j", runtime.NumCPU(), "Number of interfaces to generate at once. Interfaces written to the same file are generated one at a time")
+	reportFlag := flags.String("report", "text", "With -check, how to report the interfaces out of date: text|json|sarif. json and sarif are printed to standard out for CI systems and code review bots")
+	colorFlag := flags.String("color", "auto", "When to color -check output: auto|always|never. auto colors a terminal unless NO_COLOR is set")
+	verboseFlag := flags.Bool("v", false, "Log what's decided for each interface to standard error")
+	veryVerboseFlag := flags.Bool("vv", false, "Log how it's decided too")
+	parseFlags(flags, args)
+	setVerbosity(*verboseFlag, *veryVerboseFlag)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: gointerfacegen sync [-check] [-dry-run] [-since ref] [-j n] <packages>")
+	}
+
+	if err := validReport(*reportFlag); err != nil {
+		return err
+	}
+
+	if err := validColor(*colorFlag); err != nil {
+		return err
+	}
+	colorMode = *colorFlag
+
+	dirs, err := listPackages(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	// the packages of several interfaces are parsed and type checked once
+	cached = newFileCache(true)
+
+	// keep going so every invalid marker is reported
+	failed := false
+	configs := []config{}
+	for _, path := range sortedKeys(dirs) {
+		dir := dirs[path]
+
+		fset := cached.fileSet()
+		files, err := parseDir(fset, dir, nil, "")
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			filename := fset.Position(file.Package).Filename
+			for _, marker := range generator.InterfaceMarkers(file) {
+				c, err := syncConfig(marker, dir, filename)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+					failed = true
+					continue
+				}
+
+				configs = append(configs, c)
+			}
+		}
+	}
+
+	if len(configs) == 0 && !failed {
+		return fmt.Errorf("no interfaces with gointerfacegen source markers found in %v", flags.Args())
+	}
+
+	// only the interfaces that what changed affects are regenerated
+	if *sinceFlag != "" {
+		changed, err := changedFiles(*sinceFlag)
+		if err != nil {
+			return err
+		}
+
+		affectedConfigs := []config{}
+		for _, c := range configs {
+			ok, err := affected(c, changed)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				affectedConfigs = append(affectedConfigs, c)
+			} else {
+				logger.Info("unaffected by the changes", "interface", c.interfaceName, "since", *sinceFlag)
+			}
+		}
+
+		if len(affectedConfigs) == 0 && !failed {
+			return nil
+		}
+		configs = affectedConfigs
+	}
+
+	return runConfigs(strings.Join(flags.Args(), " "), configs, failed, *checkFlag, *dryRunFlag, *jobsFlag, *reportFlag)
+}
+
+// syncConfig returns the configuration regenerating the marked interface declared
+// in the file in dir from the source recorded by its marker
+func syncConfig(marker generator.InterfaceMarker, dir, filename string) (config, error) {
+	options := make(map[string]string)
+	for key, value := range marker.Options {
+		options[key] = value
+	}
+
+	// a type of another package is looked up by its import path
+	pkg := options["pkg"]
+	delete(options, "pkg")
+	if pkg != "" {
+		options["file"] = filepath.Base(filename)
+	}
+
+	entry, err := markerEntry(options)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid source marker on %s: %v", marker.InterfaceName, err)
+	}
+
+	entry.Interface = marker.InterfaceName
+	entry.File = filepath.FromSlash(entry.File)
+	if filepath.Join(dir, entry.File) != filename {
+		entry.Output = filepath.Base(filename)
+	}
+
+	c, err := entry.config(dir)
+	if err != nil {
+		return config{}, err
+	}
+
+	if pkg != "" {
+		c.pkg, c.filename, c.outputFilename = pkg, "", filename
+	}
+	c.mark = true
+
+	return c, nil
+}
+
+// sourceOptions returns the options of the marker recording the source of the interfaces
+// generated into the target file with c, which are those of a config entry
+func sourceOptions(c config, targetFilename string) (map[string]string, error) {
+	// the type's file and the consuming package are found from the interface's directory
+	target, err := filepath.Abs(filepath.Dir(targetFilename))
+	if err != nil {
+		return nil, err
+	}
+
+	relative := func(path string) (string, error) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+
+		rel, err := filepath.Rel(target, abs)
+		if err != nil {
+			return "", err
+		}
+
+		return filepath.ToSlash(rel), nil
+	}
+
+	options := map[string]string{"type": strings.Join(c.typeNames, ",")}
+	if c.pkg != "" {
+		options["pkg"] = c.pkg
+	} else {
+		options["file"], err = relative(c.filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.usedBy != "" {
+		options["usedBy"], err = relative(c.usedBy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.usedIn != "" {
+		options["usedIn"] = c.usedIn
+	}
+
+	flags := map[string]bool{
+		"exported":        c.exportedOnly,
+		"prune":           c.prune,
+		"types":           c.typeCheck && !c.promoted && !c.valueMethodSet,
+		"promoted":        c.promoted,
+		"common":          c.common,
+		"assert":          c.assert,
+		"declGroup":       c.declGroup,
+		"keepResultNames": c.keepResultNames,
+		"skipDeprecated":  c.skipDeprecated,
+		"embedStd":        c.embedStd,
+		"skipUnexported":  c.skipUnexported,
+	}
+	for key, set := range flags {
+		if set {
+			options[key] = "true"
+		}
+	}
+
+	if !c.docs {
+		options["doc"] = "false"
+	}
+
+	if c.include != "" {
+		options["include"] = c.include
+	}
+
+	if c.exclude != "" {
+		options["exclude"] = c.exclude
+	}
+
+	// types are recorded as the filters compare them, without spaces ending the option
+	if c.returning != "" {
+		typeExpr, err := generator.NormalizeType(c.returning)
+		if err != nil {
+			return nil, err
+		}
+		options["onlyReturning"] = typeExpr
+	}
+
+	if c.firstParam != "" {
+		typeExpr, err := generator.NormalizeType(c.firstParam)
+		if err != nil {
+			return nil, err
+		}
+		options["firstParam"] = typeExpr
+	}
+
+	if c.maxParams != nil {
+		options["maxParams"] = strconv.Itoa(*c.maxParams)
+	}
+
+	if c.ignoreTag != generator.DefaultIgnoreTag {
+		options["ignoreTag"] = c.ignoreTag
+	}
+
+	switch c.order {
+	case generator.OrderSource:
+		options["sort"] = "source"
+	case generator.OrderAlpha:
+		options["sort"] = "alpha"
+	}
+
+	if c.valueMethodSet {
+		options["methodset"] = "value"
+	}
+
+	if c.stripParamNames {
+		options["paramNames"] = "strip"
+	}
+
+	if c.importAlias != "" {
+		options["importAlias"] = c.importAlias
+	}
+
+	// a marker's options end at a space
+	for key, value := range options {
+		if strings.ContainsAny(value, " \t\n") {
+			return nil, fmt.Errorf("-mark cannot record %s %q, which has spaces", key, value)
+		}
+	}
+
+	return options, nil
+}
+
+// changedFiles returns the absolute paths of the files git finds changed since ref, the changes
+// of the working tree and untracked files included, as they may have been added since
+func changedFiles(ref string) (map[string]bool, error) {
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Stderr = os.Stderr
+
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %v", strings.Join(args, " "), err)
+		}
+
+		return string(out), nil
+	}
+
+	// file names are listed separated by NUL, as they are, so names with spaces or quotes come through
+	names := func(out string) []string {
+		return strings.FieldsFunc(out, func(r rune) bool { return r == 0 })
+	}
+
+	top, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+
+	top = strings.TrimSuffix(top, "\n")
+	if top == "" {
+		return nil, fmt.Errorf("the repository's directory isn't known")
+	}
+
+	diff, err := git("diff", "-z", "--name-only", "--no-renames", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := git("ls-files", "-z", "--others", "--exclude-standard", "--full-name", top)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool)
+	for _, name := range append(names(diff), names(untracked)...) {
+		changed[filepath.Join(top, filepath.FromSlash(name))] = true
+	}
+
+	return changed, nil
+}
+
+// affected reports whether the changed files affect the interface generated with c: its file or its
+// type's file changed, any go file of the type's package when the package is type checked or any go
+// file of the -used-by package, whose calls pick the methods
+func affected(c config, changed map[string]bool) (bool, error) {
+	target := c.outputFilename
+	if target == "" {
+		target = c.filename
+	}
+
+	files := []string{target}
+	typeDir := filepath.Dir(c.filename)
+	if c.pkg != "" {
+		// the package is looked up like its type is, outside of the repository too
+		dirs, err := listPackages([]string{c.pkg})
+		if err != nil {
+			return false, err
+		}
+		typeDir = dirs[c.pkg]
+	} else {
+		files = append(files, c.filename)
+	}
+
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil && changed[abs] {
+			return true, nil
+		}
+	}
+
+	dirs := []string{}
+	if c.typeCheck || c.pkg != "" {
+		dirs = append(dirs, typeDir)
+	}
+
+	if c.usedBy != "" {
+		dirs = append(dirs, c.usedBy)
+	}
+
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+
+		for file := range changed {
+			if filepath.Dir(file) == abs && strings.HasSuffix(file, ".go") {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// storeFiles is a package with a type whose interface every option of a source marker changes
+var storeFiles = map[string]string{
+	"go.mod": "module example.com/p\n",
+	"store.go": `package p
+
+import "io"
+
+type Store struct{}
+
+// Get gets the value.
+//
+// Deprecated: use Fetch.
+func (s *Store) Get(id string) (n int, err error) { return 0, nil }
+
+// Fetch fetches the value.
+func (s *Store) Fetch(id string) (n int, err error) { return 0, nil }
+
+func (s Store) Read(p []byte) (n int, err error) { return 0, nil }
+
+func (s Store) Close() error { return nil }
+
+func (s *Store) Writer() io.Writer { return nil }
+`,
+	"consumer/consumer.go": `package consumer
+
+import "example.com/p"
+
+func Load(s *p.Store) {
+	s.Fetch("id")
+}
+
+func Close(s *p.Store) {
+	s.Close()
+}
+`,
+}
+
+func TestMarkThenSyncCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		option func(c *config, dir string)
+	}{
+		{"defaults", func(c *config, dir string) {}},
+		{"keep result names", func(c *config, dir string) { c.keepResultNames = true }},
+		{"skip deprecated", func(c *config, dir string) { c.skipDeprecated = true }},
+		{"embed std", func(c *config, dir string) { c.embedStd = true }},
+		{"strip param names", func(c *config, dir string) { c.stripParamNames = true }},
+		{"value method set", func(c *config, dir string) { c.valueMethodSet, c.typeCheck = true, true }},
+		{"used by", func(c *config, dir string) { c.usedBy = filepath.Join(dir, "consumer") }},
+		{"used in", func(c *config, dir string) { c.usedBy, c.usedIn = filepath.Join(dir, "consumer"), "Load" }},
+		{"no docs", func(c *config, dir string) { c.docs = false }},
+		{"exported", func(c *config, dir string) { c.exportedOnly, c.exclude = true, "Writer" }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, storeFiles)
+			t.Chdir(dir)
+
+			c := writeConfig("Store", "Storer", filepath.Join(dir, "store.go"))
+			c.mark = true
+			test.option(&c, dir)
+			if _, err := run(c); err != nil {
+				t.Fatal(err)
+			}
+
+			generated := readFile(t, filepath.Join(dir, "store.go"))
+			if err := runSync([]string{"-check", "."}); err != nil {
+				t.Errorf("sync -check of\n%s\n%v", generated, err)
+			}
+		})
+	}
+}
+
+func TestSyncSince(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go.mod":     "module example.com/p\n",
+		"a/store.go": "package a\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+		"b/queue.go": "package b\n\ntype Queue struct{}\n\nfunc (q *Queue) Push(v int) {}\n",
+	})
+	t.Chdir(dir)
+	t.Cleanup(func() { cached = nil })
+
+	for _, c := range []config{
+		writeConfig("Store", "Storer", filepath.Join(dir, "a", "store.go")),
+		writeConfig("Queue", "Queuer", filepath.Join(dir, "b", "queue.go")),
+	} {
+		c.mark = true
+		if _, err := run(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// b's interface is committed out of date, then a's type changes
+	appendFile(t, filepath.Join(dir, "b", "queue.go"), "\nfunc (q *Queue) Pop() int { return 0 }\n")
+	gitCommand(t, "init", "-q")
+	gitCommand(t, "add", "-A")
+	gitCommand(t, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+	appendFile(t, filepath.Join(dir, "a", "store.go"), "\nfunc (s *Store) Put(v int) {}\n")
+
+	if err := runSync([]string{"-since", "HEAD", "./..."}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, filepath.Join(dir, "a", "store.go")); !strings.Contains(got, "\tPut(v int)\n}") {
+		t.Errorf("a/store.go:\n%s\nwant the changed interface regenerated", got)
+	}
+
+	if got := readFile(t, filepath.Join(dir, "b", "queue.go")); strings.Contains(got, "\tPop() int\n") {
+		t.Errorf("b/queue.go:\n%s\nwant the unchanged interface left as it was", got)
+	}
+
+	// b is out of date but unaffected by the changes since HEAD
+	if err := runSync([]string{"-since", "HEAD", "-check", "./..."}); err != nil {
+		t.Errorf("check of the interfaces affected since HEAD: %v", err)
+	}
+
+	if err := runSync([]string{"-check", "./..."}); err == nil {
+		t.Error("check of every interface succeeded, want b's out of date")
+	}
+
+	// untracked files count as changed, as they may have been added since, whatever their name
+	untracked := filepath.Join(dir, "b", "new file.go")
+	if err := os.WriteFile(untracked, []byte("package b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := changedFiles("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !changed[untracked] || !changed[filepath.Join(dir, "a", "store.go")] || changed[filepath.Join(dir, "b", "queue.go")] {
+		t.Errorf("changed files %v, want a/store.go and b/new file.go", sortedKeys(changed))
+	}
+}
+
+func TestAffected(t *testing.T) {
+	dir := t.TempDir()
+	storeFile := filepath.Join(dir, "store", "store.go")
+	ifaceFile := filepath.Join(dir, "iface", "iface.go")
+
+	typeChecked := writeConfig("Store", "Storer", storeFile)
+	typeChecked.typeCheck = true
+
+	output := writeConfig("Store", "Storer", storeFile)
+	output.outputFilename = ifaceFile
+
+	usedBy := writeConfig("Store", "Storer", storeFile)
+	usedBy.usedBy = filepath.Join(dir, "consumer")
+
+	tests := []struct {
+		name    string
+		c       config
+		changed string
+		want    bool
+	}{
+		{"its file", writeConfig("Store", "Storer", storeFile), storeFile, true},
+		{"another file of the package", writeConfig("Store", "Storer", storeFile), filepath.Join(dir, "store", "user.go"), false},
+		{"another file of a type checked package", typeChecked, filepath.Join(dir, "store", "user.go"), true},
+		{"a file of another package", typeChecked, filepath.Join(dir, "other", "other.go"), false},
+		{"its output file", output, ifaceFile, true},
+		{"its type's file", output, storeFile, true},
+		{"a file of the package using it", usedBy, filepath.Join(dir, "consumer", "handler.go"), true},
+		{"a file of another package than the one using it", usedBy, filepath.Join(dir, "other", "other.go"), false},
+	}
+
+	for _, test := range tests {
+		got, err := affected(test.c, map[string]bool{test.changed: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != test.want {
+			t.Errorf("%s: affected = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// appendFile appends the text to the file
+func appendFile(t *testing.T, path, text string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// gitCommand runs git with the arguments in the current directory
+func gitCommand(t *testing.T, args ...string) {
+	t.Helper()
+
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// overlay maps absolute file paths to files holding their replacement contents.
+// It uses the format of go build -overlay so editors can supply unsaved buffers:
+//
+//	{"Replace": {"/path/to/src.go": "/tmp/unsaved-src.go"}}
+//
+// An empty replacement path means the file is treated as deleted
+type overlay map[string]string
+
+// loadOverlay reads the overlay from the json file
+func loadOverlay(filename string) (overlay, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents struct {
+		Replace map[string]string
+	}
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return nil, fmt.Errorf("invalid overlay %s: %v", filename, err)
+	}
+
+	o := overlay{}
+	for path, replacement := range contents.Replace {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+
+		o[abs] = replacement
+	}
+
+	return o, nil
+}
+
+// readFile reads the file's replacement contents if it is in the overlay and
+// the file on disk otherwise
+func (o overlay) readFile(filename string) ([]byte, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	replacement, ok := o[abs]
+	if !ok {
+		return ioutil.ReadFile(filename)
+	}
+
+	if replacement == "" {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+	}
+
+	return ioutil.ReadFile(replacement)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"os"
+	"path/filepath"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+	"github.com/hankjacobs/gointerfacegen/internal/diff"
+)
+
+// runRename runs the rename subcommand, renaming an interface declared in the package of
+// the file and every reference to it in the package, its test files included
+func runRename(args []string) error {
+	flags := newFlagSet("rename")
+	diffFlag := flags.Bool("d", false, "Print a unified diff of the changes instead of writing the files")
+	backupFlag := flags.Bool("backup", false, "Keep the previous contents of a written file in a copy with the .orig extension")
+	verboseFlag := flags.Bool("v", false, "Log the files written to standard error")
+	parseFlags(flags, args)
+	setVerbosity(*verboseFlag, false)
+
+	if flags.NArg() != 3 {
+		return fmt.Errorf("usage: gointerfacegen rename [-d] <interface> <newName> <file>")
+	}
+	interfaceName, newName, filename := flags.Arg(0), flags.Arg(1), flags.Arg(2)
+
+	// tests in the package refer to the interface too
+	dir := filepath.Dir(filename)
+	fset := token.NewFileSet()
+	files, srcs, err := parseWithTests(fset, dir)
+	if err != nil {
+		return err
+	}
+
+	renamed, err := generator.Rename(fset, files, interfaceName, newName)
+	if err != nil {
+		return generator.Place(err, fset, nil, dir)
+	}
+
+	for _, file := range renamed {
+		path := fset.Position(file.Package).Filename
+		newSrc, err := newSource(fset, file, srcs[path])
+		if err != nil {
+			return err
+		}
+
+		if *diffFlag {
+			name := filepath.ToSlash(path)
+			unified := diff.Unified("a/"+name, "b/"+name, srcs[path], newSrc)
+			if colored(os.Stdout) {
+				unified = diff.Colorize(unified)
+			}
+			os.Stdout.Write(unified)
+			continue
+		}
+
+		if err := writeFile(path, newSrc, *backupFlag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceType switches the declarations of the package of the interface, written to the file, from
+// the type of c to the interface wherever the interface will do, its tests included. The interface
+// must be written to the package of the type
+func replaceType(c config, filename, interfaceName string) error {
+	dir := filepath.Dir(filename)
+	if !sameDir(dir, filepath.Dir(c.filename)) {
+		return fmt.Errorf("-replace requires the interface in the package of %s, not %s", c.typeNames[0], filename)
+	}
+
+	fset := token.NewFileSet()
+	files, srcs, err := parseWithTests(fset, dir)
+	if err != nil {
+		return err
+	}
+
+	switched, err := generator.ReplaceType(fset, files, c.typeNames[0], interfaceName)
+	if err != nil {
+		return generator.Place(err, fset, nil, dir)
+	}
+
+	paths := []string{}
+	newSrcs := make(map[string][]byte)
+	for _, file := range switched {
+		path := fset.Position(file.Package).Filename
+		newSrc, err := newSource(fset, file, srcs[path])
+		if err != nil {
+			return err
+		}
+
+		paths = append(paths, path)
+		newSrcs[path] = newSrc
+	}
+
+	// the files are only written if none of them breaks the package
+	if c.validate {
+		for _, path := range paths {
+			if err := validate(c, path, newSrcs[path], newSrcs); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, path := range paths {
+		logger.Info("switched declarations to the interface", "file", path, "interface", interfaceName)
+		if err := writeFile(path, newSrcs[path], c.backup); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseWithTests parses the files of the package in dir that match the current build
+// context, the tests of the package included, and returns them along with their sources
+func parseWithTests(fset *token.FileSet, dir string) ([]*ast.File, map[string][]byte, error) {
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := []*ast.File{}
+	srcs := make(map[string][]byte)
+	for _, name := range append(append(pkg.GoFiles, pkg.CgoFiles...), pkg.TestGoFiles...) {
+		path := filepath.Join(dir, name)
+		srcBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		file, err := generator.ParseFile(fset, path, srcBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		files = append(files, file)
+		srcs[path] = srcBytes
+	}
+
+	return files, srcs, nil
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+	"github.com/hankjacobs/gointerfacegen/internal/diff"
+)
+
+// staleError is the error of a check finding interfaces out of date on disk
+type staleError struct {
+	filename       string
+	interfaceNames []string
+	noFile         bool // the file the interfaces are generated into doesn't exist
+	methods        []staleMethod
+}
+
+// staleMethod is a method that differs between an interface on disk and the interface generated
+type staleMethod struct {
+	interfaceName string
+	problem       string // missing, outdated or extra
+	have          string // the method on disk, empty when missing
+	want          string // the method generated, empty when extra
+	line          int    // of the method on disk or, when missing, of its interface. 0 when there is none
+}
+
+func (e *staleError) Error() string {
+	return e.text(false)
+}
+
+// text returns the error's message with the methods colored for a terminal when color is set
+func (e *staleError) text(color bool) string {
+	msg := fmt.Sprintf("%s: %s out of date", e.filename, outOfDate(e.interfaceNames))
+	if e.noFile {
+		return msg + ": file does not exist"
+	}
+
+	for _, m := range e.methods {
+		prefix := ""
+		if len(e.interfaceNames) > 1 {
+			prefix = m.interfaceName + "."
+		}
+
+		line := ""
+		switch m.problem {
+		case "missing":
+			line = fmt.Sprintf("missing method %s%s", prefix, m.want)
+		case "outdated":
+			line = fmt.Sprintf("outdated method %s%s, want %s", prefix, m.have, m.want)
+		case "extra":
+			line = fmt.Sprintf("extra method %s%s", prefix, m.have)
+		}
+
+		if color {
+			line = diff.Color(m.kind(), line)
+		}
+		msg += "\n\t" + line
+	}
+
+	return msg
+}
+
+// kind returns the kind of change to the method for diff.Color
+func (m staleMethod) kind() byte {
+	switch m.problem {
+	case "missing":
+		return '+'
+	case "extra":
+		return '-'
+	}
+
+	return '~'
+}
+
+// finding is a problem found by a check as reported by -report json
+type finding struct {
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Interface string `json:"interface,omitempty"`
+
+	// missing method, outdated method, extra method, missing file, out of date
+	// when only formatting differs, or error when the check itself failed
+	Problem string `json:"problem"`
+
+	Have    string `json:"have,omitempty"`
+	Want    string `json:"want,omitempty"`
+	Code    string `json:"code,omitempty"` // of the diagnostic of an error
+	Message string `json:"message"`
+}
+
+// findings returns the findings of the errors of failed checks ordered by file and line
+func findings(errs []error) []finding {
+	found := []finding{}
+	for _, err := range errs {
+		var stale *staleError
+		if !errors.As(err, &stale) {
+			f := finding{Problem: "error", Message: err.Error()}
+			var d *generator.Diagnostic
+			if errors.As(err, &d) {
+				f.File, f.Line, f.Code, f.Message = d.Pos.Filename, d.Pos.Line, string(d.Code), d.Msg
+			}
+
+			found = append(found, f)
+			continue
+		}
+
+		if stale.noFile || len(stale.methods) == 0 {
+			problem := "out of date"
+			if stale.noFile {
+				problem = "missing file"
+			}
+
+			for _, interfaceName := range stale.interfaceNames {
+				found = append(found, finding{
+					File:      stale.filename,
+					Interface: interfaceName,
+					Problem:   problem,
+					Message:   (&staleError{filename: stale.filename, interfaceNames: []string{interfaceName}, noFile: stale.noFile}).Error(),
+				})
+			}
+			continue
+		}
+
+		for _, m := range stale.methods {
+			found = append(found, finding{
+				File:      stale.filename,
+				Line:      m.line,
+				Interface: m.interfaceName,
+				Problem:   m.problem + " method",
+				Have:      m.have,
+				Want:      m.want,
+				Message:   (&staleError{filename: stale.filename, interfaceNames: []string{m.interfaceName}, methods: []staleMethod{m}}).Error(),
+			})
+		}
+	}
+
+	// checks run concurrently
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].File != found[j].File {
+			return found[i].File < found[j].File
+		}
+
+		return found[i].Line < found[j].Line
+	})
+
+	return found
+}
+
+// validReport returns an error unless format is a format of a check's report
+func validReport(format string) error {
+	switch format {
+	case "text", "json", "sarif":
+		return nil
+	}
+
+	return fmt.Errorf("invalid -report %q: must be text, json or sarif", format)
+}
+
+// printReport prints the errors of failed checks in the format, json or sarif
+func printReport(format string, errs []error) error {
+	found := findings(errs)
+
+	var report interface{} = found
+	if format == "sarif" {
+		report = sarifReport(found)
+	}
+
+	out, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+// diagnostic is an error as printed by -diagnostics json. The position and code
+// are those of a generator.Diagnostic and are left out for other errors
+type diagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Ident   string `json:"ident,omitempty"`
+	Message string `json:"message"`
+}
+
+// printError prints the error to standard error in the format, text or json
+func printError(format string, err error) {
+	if format != "json" {
+		fmt.Fprintln(os.Stderr, errorText(err))
+		return
+	}
+
+	out := diagnostic{Message: err.Error()}
+	var d *generator.Diagnostic
+	if errors.As(err, &d) {
+		out = diagnostic{
+			File:    d.Pos.Filename,
+			Line:    d.Pos.Line,
+			Column:  d.Pos.Column,
+			Code:    string(d.Code),
+			Ident:   d.Ident,
+			Message: d.Msg,
+		}
+	}
+
+	b, _ := json.Marshal(out)
+	fmt.Fprintln(os.Stderr, string(b))
+}
+
+// sarifLog is the subset of a SARIF 2.1.0 log (see https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html)
+// that code scanning services read
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifRules describes the problems a check finds, keyed by the ids of the rules
+var sarifRules = map[string]string{
+	"missing-method":  "The interface is missing a method of the type",
+	"outdated-method": "The method's signature differs from the type's",
+	"extra-method":    "The interface has a method the type doesn't",
+	"missing-file":    "The file the interface is generated into doesn't exist",
+	"out-of-date":     "The interface differs from the generated interface",
+	"error":           "The interface could not be checked",
+}
+
+// sarifReport returns the findings as a SARIF log with a result for each
+func sarifReport(found []finding) sarifLog {
+	driver := sarifDriver{Name: "gointerfacegen", InformationURI: "https://github.com/hankjacobs/gointerfacegen"}
+	for _, id := range sortedKeys(sarifRules) {
+		driver.Rules = append(driver.Rules, sarifRule{ID: id, ShortDescription: sarifMessage{Text: sarifRules[id]}})
+	}
+
+	results := []sarifResult{}
+	for _, f := range found {
+		result := sarifResult{
+			RuleID:  strings.Replace(f.Problem, " ", "-", -1),
+			Level:   "error",
+			Message: sarifMessage{Text: f.Message},
+		}
+
+		if f.File != "" {
+			line := f.Line
+			if line == 0 {
+				line = 1
+			}
+
+			result.Locations = []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: sarifURI(f.File)},
+				Region:           sarifRegion{StartLine: line},
+			}}}
+		}
+
+		results = append(results, result)
+	}
+
+	return sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+}
+
+// sarifURI returns the file relative to the current directory, where
+// checks run from the root of the repository, with forward slashes
+func sarifURI(filename string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return filepath.ToSlash(filename)
+	}
+
+	if abs, err := filepath.Abs(filename); err == nil {
+		if rel, err := filepath.Rel(wd, abs); err == nil {
+			filename = rel
+		}
+	}
+
+	return filepath.ToSlash(filename)
+}
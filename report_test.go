@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the reports in testdata")
+
+// staleFiles is a package whose interfaces a check finds every problem with
+var staleFiles = map[string]string{
+	"go.mod": "module example.com/p\n",
+	"store.go": `package p
+
+type Store struct{}
+
+func (s *Store) Get(id string) (int, error) { return 0, nil }
+
+func (s *Store) Put(id string, v int) error { return nil }
+
+// Storer is the interface implemented by Store.
+type Storer interface {
+	Get(id string) int
+	Delete(id string) error
+}
+`,
+	"queue.go": "package p\n\ntype Queue struct{}\n\nfunc (q *Queue) Push(v int) {}\n",
+}
+
+func TestCheckReport(t *testing.T) {
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"json", "sarif"} {
+		t.Run(format, func(t *testing.T) {
+			t.Chdir(writeFiles(t, staleFiles))
+
+			queue := writeConfig("Queue", "Queuer", "queue.go")
+			queue.outputFilename = "queuer.go"
+			store := writeConfig("Store", "Storer", "store.go")
+			store.prune = true
+			configs := []config{
+				store,
+				queue,
+				writeConfig("Log", "Logger", "log.go"),
+			}
+
+			got, err := captureStdout(t, func() error {
+				return runConfigs("test", configs, false, true, false, 2, format)
+			})
+			if err == nil {
+				t.Fatal("check of stale interfaces succeeded")
+			}
+
+			golden := filepath.Join(testdata, "report."+format)
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if want := readFile(t, golden); got != want {
+				t.Errorf("-report %s:\n%s\nwant:\n%s", format, got, want)
+			}
+
+			if format == "sarif" {
+				checkSarif(t, got)
+			}
+		})
+	}
+}
+
+// checkSarif checks that every result of the SARIF log is of one of its rules
+// and that every location names a file and a line
+func checkSarif(t *testing.T, out string) {
+	t.Helper()
+
+	var log sarifLog
+	if err := json.Unmarshal([]byte(out), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	if log.Version != "2.1.0" || log.Schema == "" || len(log.Runs) != 1 {
+		t.Fatalf("version %q, $schema %q and %d runs, want 2.1.0, a schema and 1 run", log.Version, log.Schema, len(log.Runs))
+	}
+
+	rules := make(map[string]bool)
+	for _, rule := range log.Runs[0].Tool.Driver.Rules {
+		rules[rule.ID] = true
+	}
+
+	for _, result := range log.Runs[0].Results {
+		if !rules[result.RuleID] {
+			t.Errorf("result %q isn't of one of the rules %v", result.Message.Text, sortedKeys(rules))
+		}
+
+		for _, location := range result.Locations {
+			if location.PhysicalLocation.ArtifactLocation.URI == "" || location.PhysicalLocation.Region.StartLine < 1 {
+				t.Errorf("result %q has location %+v, want a file and a line", result.Message.Text, location)
+			}
+		}
+	}
+}
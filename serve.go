@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/sha256"
+	"go/ast"
+	"go/token"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// runServe runs the serve subcommand, answering JSON-RPC requests over standard in and
+// out or a unix socket. Parsed files are cached between requests until they change
+//
+//	{"method": "gointerfacegen.Generate", "params": [{"type": "Store", "interface": "Iface", "file": "store.go"}], "id": 1}
+//	{"method": "gointerfacegen.Check", "params": [{"type": "Store", "interface": "Iface", "file": "store.go"}], "id": 2}
+//	{"method": "gointerfacegen.List", "params": [{"dir": "."}], "id": 3}
+func runServe(args []string) error {
+	flags := newFlagSet("serve")
+	socketFlag := flags.String("socket", "", "Listen on this unix socket instead of standard in and out")
+	parseFlags(flags, args)
+
+	cached = newFileCache(false)
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("gointerfacegen", &Server{}); err != nil {
+		return err
+	}
+
+	if *socketFlag == "" {
+		// anything else printed to standard out would corrupt the responses
+		conn := stdio{Reader: os.Stdin, Writer: os.Stdout}
+		os.Stdout = os.Stderr
+
+		server.ServeCodec(jsonrpc.NewServerCodec(conn))
+		return nil
+	}
+
+	listener, err := net.Listen("unix", *socketFlag)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// stdio is a connection over standard in and out
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+// Close does nothing, standard in and out are left open
+func (stdio) Close() error {
+	return nil
+}
+
+// Server answers the requests of the serve subcommand. Requests are
+// handled one at a time as they may write to the same files
+type Server struct {
+	mu sync.Mutex
+}
+
+// GenerateArgs describes the interface to generate like an entry of the generate
+// subcommand's config. Relative files are relative to the server's directory
+type GenerateArgs struct {
+	batchEntry
+}
+
+// GenerateReply lists the file the interface was written to
+type GenerateReply struct {
+	File string `json:"file"`
+}
+
+// Generate generates the interface and writes it to its output or source file
+func (s *Server) Generate(args GenerateArgs, reply *GenerateReply) error {
+	c, err := args.config("")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cached.newRun()
+
+	target, err := run(c)
+	if err != nil {
+		return err
+	}
+
+	reply.File = target
+	return nil
+}
+
+// CheckReply reports whether the interface on disk is up to date and, when not, what's out of date
+type CheckReply struct {
+	UpToDate bool   `json:"upToDate"`
+	Problem  string `json:"problem,omitempty"`
+}
+
+// Check checks that the interface on disk is up to date without writing anything
+func (s *Server) Check(args GenerateArgs, reply *CheckReply) error {
+	c, err := args.config("")
+	if err != nil {
+		return err
+	}
+	c.check = true
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cached.newRun()
+
+	if _, err := run(c); err != nil {
+		reply.Problem = err.Error()
+		return nil
+	}
+
+	reply.UpToDate = true
+	return nil
+}
+
+// ListArgs names the directory of the package to list
+type ListArgs struct {
+	Dir string `json:"dir"`
+}
+
+// ListReply is the named types of the package with their methods
+type ListReply struct {
+	Types []listedType `json:"types"`
+}
+
+// List lists the named types of the package with their methods like the list subcommand
+func (s *Server) List(args ListArgs, reply *ListReply) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cached.newRun()
+
+	fset := cached.fileSet()
+	files, err := parseDir(fset, args.Dir, nil, "")
+	if err != nil {
+		return err
+	}
+
+	reply.Types, err = listTypes(fset, files)
+	return err
+}
+
+// cached holds the files parsed while serving, generating a batch or watching and is nil otherwise
+var cached *fileCache
+
+// fileCache keeps parsed files, all parsed into the same file set, until their contents change.
+// It may be used by several goroutines at once
+type fileCache struct {
+	mu    sync.Mutex
+	fset  *token.FileSet
+	files map[string]cachedFile // keyed by path
+
+	// replaced are the files whose contents changed since the run began, dropped
+	// from fset when the next run begins as the last may still refer to them
+	replaced []*token.File
+
+	// type checks the packages of a run, nil when serving since the
+	// packages imported may change between requests
+	checker *generator.Checker
+}
+
+// cachedFile is a parsed file along with the hash of the contents it was parsed from
+type cachedFile struct {
+	file *ast.File
+	hash [sha256.Size]byte
+}
+
+// newFileCache returns an empty cache, which type checks packages for a run unless serving
+func newFileCache(typeCheck bool) *fileCache {
+	fc := &fileCache{fset: token.NewFileSet(), files: make(map[string]cachedFile)}
+	if typeCheck {
+		fc.checker = generator.NewChecker(fc.fset)
+	}
+
+	return fc
+}
+
+// fileSet returns the file set files are parsed into, shared by every request when serving
+func (fc *fileCache) fileSet() *token.FileSet {
+	if fc == nil {
+		return token.NewFileSet()
+	}
+
+	return fc.fset
+}
+
+// typeChecker returns the checker type checking the packages of a run or nil without one
+func (fc *fileCache) typeChecker() *generator.Checker {
+	if fc == nil {
+		return nil
+	}
+
+	return fc.checker
+}
+
+// newRun starts type checking the packages afresh, for a run after they may have changed.
+// The files replaced during the last run are dropped so the file set doesn't keep growing
+func (fc *fileCache) newRun() {
+	if fc == nil {
+		return
+	}
+
+	fc.mu.Lock()
+	for _, f := range fc.replaced {
+		fc.fset.RemoveFile(f)
+	}
+	fc.replaced = nil
+	fc.mu.Unlock()
+
+	if fc.checker != nil {
+		fc.checker = generator.NewChecker(fc.fset)
+	}
+}
+
+// parse returns the file parsed from src, the contents of path, into fset. The file
+// is only parsed when it isn't cached with the same contents
+func (fc *fileCache) parse(fset *token.FileSet, path string, src []byte) (*ast.File, error) {
+	if fc == nil || fset != fc.fset {
+		return generator.ParseFile(fset, path, src)
+	}
+
+	hash := sha256.Sum256(src)
+
+	fc.mu.Lock()
+	entry, ok := fc.files[path]
+	fc.mu.Unlock()
+	if ok && entry.hash == hash {
+		return entry.file, nil
+	}
+
+	file, err := generator.ParseFile(fset, path, src)
+	if err != nil {
+		return nil, err
+	}
+
+	fc.mu.Lock()
+	if old, ok := fc.files[path]; ok {
+		fc.replaced = append(fc.replaced, fset.File(old.file.FileStart))
+	}
+	fc.files[path] = cachedFile{file: file, hash: hash}
+	fc.mu.Unlock()
+
+	return file, nil
+}
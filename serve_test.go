@@ -0,0 +1,56 @@
+package main
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileCacheDropsReplacedFiles(t *testing.T) {
+	fc := newFileCache(false)
+	fset := fc.fileSet()
+
+	for i, src := range []string{"package p\n", "package p\n\nvar v int\n", "package p\n\nvar v int\n", "package p\n"} {
+		fc.newRun()
+		if _, err := fc.parse(fset, "p.go", []byte(src)); err != nil {
+			t.Fatal(err)
+		}
+
+		files := 0
+		fset.Iterate(func(*token.File) bool {
+			files++
+			return true
+		})
+
+		// the file replaced in this run is only dropped when the next run begins
+		if want := 1 + min(i, 1); files > want {
+			t.Errorf("run %d: %d files in the file set, want at most %d", i, files, want)
+		}
+	}
+}
+
+func TestServerGenerateReportsTheFileWritten(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"store.go":  "package p\n\ntype Store struct{}\n\nfunc (s *Store) Get() int { return 0 }\n",
+		"ifaces.go": "package p\n\ntype Storer interface {\n}\n",
+	})
+	t.Chdir(dir)
+	cached = newFileCache(true)
+	t.Cleanup(func() { cached = nil })
+
+	var reply GenerateReply
+	args := GenerateArgs{batchEntry{Type: "Store", Interface: "Storer", File: "store.go"}}
+	if _, err := capture(t, &os.Stderr, func() error { return new(Server).Generate(args, &reply) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Base(reply.File) != "ifaces.go" {
+		t.Errorf("reply file %s, want ifaces.go declaring the interface", reply.File)
+	}
+
+	if got := readFile(t, filepath.Join(dir, "ifaces.go")); !strings.Contains(got, "\tGet() int\n") {
+		t.Errorf("ifaces.go:\n%s\nwant the interface updated", got)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+	"text/template"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// templateData is what a -template is executed with. The interface, or the first
+// of several, is embedded so the template of one interface uses .Name and .Methods
+//
+//	// Code generated by gointerfacegen. DO NOT EDIT.
+//
+//	//go:build !production
+//
+//	package {{.Package}}
+//
+//	import (
+//	{{range .Imports}}	{{.Name}} "{{.Path}}"
+//	{{end}})
+//
+//	type {{.Name}} interface {
+//	{{range .Methods}}	{{.Name}}{{.Signature}}
+//	{{end}}}
+type templateData struct {
+	templateInterface
+	Package    string
+	Imports    []generator.Import // those the interfaces refer to
+	Interfaces []templateInterface
+}
+
+// templateInterface is a generated interface
+type templateInterface struct {
+	Name       string
+	Doc        string // the doc comment as written, // included
+	TypeParams string // such as [K comparable, V any]
+	Embeds     []string
+	Methods    []templateMethod
+	Decl       string // the declaration as generated, doc comment included
+}
+
+// templateMethod is a method of a generated interface
+type templateMethod struct {
+	Name      string
+	Doc       string
+	Signature string // such as (ctx context.Context, id string) (*User, error)
+}
+
+// parseTemplate parses the text/template of a -template file
+func parseTemplate(filename string, text []byte) (*template.Template, error) {
+	tmpl, err := template.New(filename).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid -template: %v", err)
+	}
+
+	return tmpl, nil
+}
+
+// applyTemplate executes the template with the interfaces generated into the file and returns
+// its output, which replaces the file, parsed into fset
+func applyTemplate(tmpl *template.Template, fset *token.FileSet, file *ast.File, filename string, interfaceNames []string) (*ast.File, error) {
+	// the declarations are taken from the source of the file as it would be written
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, err
+	}
+	src := buf.Bytes()
+
+	srcFset := token.NewFileSet()
+	srcFile, err := parser.ParseFile(srcFset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	text := func(from, to token.Pos) string {
+		return string(src[srcFset.Position(from).Offset:srcFset.Position(to).Offset])
+	}
+
+	data := templateData{Package: srcFile.Name.Name}
+	decls := []ast.Node{}
+	for _, interfaceName := range interfaceNames {
+		decl, err := generator.FindInterface(srcFile, interfaceName)
+		if err != nil {
+			return nil, err
+		}
+		decls = append(decls, decl)
+
+		tSpec := decl.Specs[0].(*ast.TypeSpec)
+		iface := templateInterface{Name: interfaceName, Doc: commentText(decl.Doc)}
+
+		start := decl.Pos()
+		if decl.Doc != nil {
+			start = decl.Doc.Pos()
+		}
+		iface.Decl = text(start, decl.End())
+
+		if tSpec.TypeParams != nil {
+			iface.TypeParams = text(tSpec.TypeParams.Pos(), tSpec.TypeParams.End())
+		}
+
+		for _, field := range tSpec.Type.(*ast.InterfaceType).Methods.List {
+			if len(field.Names) == 0 {
+				iface.Embeds = append(iface.Embeds, text(field.Type.Pos(), field.Type.End()))
+				continue
+			}
+
+			iface.Methods = append(iface.Methods, templateMethod{
+				Name:      field.Names[0].Name,
+				Doc:       commentText(field.Doc),
+				Signature: text(field.Type.Pos(), field.Type.End()),
+			})
+		}
+
+		data.Interfaces = append(data.Interfaces, iface)
+	}
+
+	if len(data.Interfaces) > 0 {
+		data.templateInterface = data.Interfaces[0]
+	}
+	data.Imports = generator.UsedImports(srcFset, srcFile, decls...)
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("executing -template: %v", err)
+	}
+
+	newFile, err := generator.ParseFile(fset, filename, out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("executing -template: the output isn't valid go: %v", err)
+	}
+
+	return newFile, nil
+}
+
+// commentText returns the comments as written, one per line
+func commentText(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+
+	lines := []string{}
+	for _, c := range cg.List {
+		lines = append(lines, c.Text)
+	}
+
+	return strings.Join(lines, "\n")
+}
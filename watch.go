@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watch runs c and runs it again whenever a go file in the directory of its file changes
+// until interrupted. The directory is checked every interval, polling rather than relying
+// on file system notifications to stay portable and free of dependencies. Failures, such
+// as the file not compiling halfway through an edit, are reported and watching carries on
+func watch(c config, interval time.Duration) error {
+	dir := filepath.Dir(c.filename)
+	var last map[string]time.Time
+	for {
+		modified, err := modTimes(dir)
+		if err != nil {
+			return err
+		}
+
+		if !sameModTimes(last, modified) {
+			cached.newRun()
+			target, err := run(c)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s generated\n", c.interfaceName)
+			}
+
+			// the files written by run aren't changes to react to, unlike
+			// those saved while it ran, which are still found modified next
+			written, err := modTimes(dir)
+			if err != nil {
+				return err
+			}
+
+			for _, name := range writtenFiles(c, dir, target) {
+				if t, ok := written[name]; ok {
+					modified[name] = t
+				}
+			}
+		}
+
+		last = modified
+		time.Sleep(interval)
+	}
+}
+
+// writtenFiles returns the names of the files of dir that run writes with c, given the file
+// it wrote the interface to: that file and the no-op implementation and -assert-test files
+func writtenFiles(c config, dir, target string) []string {
+	names := []string{}
+	if target == "" {
+		// run failed, whatever it wrote is reacted to like any other change
+		return names
+	}
+
+	for _, filename := range []string{target, c.noopFilename, c.assertTestFile} {
+		fileDir := filepath.Dir(filename)
+		if filename == c.assertTestFile && fileDir == "." {
+			// a file name alone is placed alongside the interface
+			fileDir = filepath.Dir(target)
+		}
+
+		if filename != "" && sameDir(fileDir, dir) {
+			names = append(names, filepath.Base(filename))
+		}
+	}
+
+	return names
+}
+
+// modTimes returns the modification times of the go files in dir keyed by name
+func modTimes(dir string) (map[string]time.Time, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	modified := make(map[string]time.Time)
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".go") {
+			modified[info.Name()] = info.ModTime()
+		}
+	}
+
+	return modified, nil
+}
+
+// sameModTimes reports whether no file was added, removed or modified between a and b
+func sameModTimes(a, b map[string]time.Time) bool {
+	if a == nil || len(a) != len(b) {
+		return false
+	}
+
+	for name, t := range a {
+		if other, ok := b[name]; !ok || !other.Equal(t) {
+			return false
+		}
+	}
+
+	return true
+}
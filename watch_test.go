@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestModTimes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"store.go":      "package p\n",
+		"README.md":     "# p\n",
+		"sub/nested.go": "package sub\n",
+	})
+
+	before, err := modTimes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(before) != 1 || before["store.go"].IsZero() {
+		t.Fatalf("mod times %v, want those of store.go alone", before)
+	}
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T)
+		same   bool
+	}{
+		{"nothing", func(t *testing.T) {}, true},
+		{"another kind of file", func(t *testing.T) {
+			writeFilesIn(t, dir, map[string]string{"README.md": "# q\n"})
+		}, true},
+		{"a file of a subdirectory", func(t *testing.T) {
+			writeFilesIn(t, dir, map[string]string{"sub/nested.go": "package nested\n"})
+		}, true},
+		{"modified", func(t *testing.T) {
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(dir, "store.go"), later, later); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"added", func(t *testing.T) {
+			writeFilesIn(t, dir, map[string]string{"user.go": "package p\n"})
+		}, false},
+		{"removed", func(t *testing.T) {
+			if err := os.Remove(filepath.Join(dir, "user.go")); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+	}
+
+	for _, test := range tests {
+		test.change(t)
+
+		after, err := modTimes(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if same := sameModTimes(before, after); same != test.same {
+			t.Errorf("%s: sameModTimes = %v, want %v", test.name, same, test.same)
+		}
+		before = after
+	}
+
+	// nothing has been generated before the first check
+	if sameModTimes(nil, before) {
+		t.Error("sameModTimes(nil, ...) = true, want false")
+	}
+}
+
+func TestWrittenFiles(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+
+	c := writeConfig("Store", "Storer", filepath.Join(dir, "store.go"))
+	c.noopFilename = filepath.Join(other, "noop.go")
+	c.assertTestFile = "store_test.go"
+
+	got := writtenFiles(c, dir, filepath.Join(dir, "ifaces.go"))
+	if want := []string{"ifaces.go", "store_test.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("written files %v, want %v", got, want)
+	}
+
+	if got := writtenFiles(c, dir, ""); len(got) != 0 {
+		t.Errorf("written files %v after a failed run, want none", got)
+	}
+}
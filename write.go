@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hankjacobs/gointerfacegen/generator"
+)
+
+// writeFile replaces the file with data atomically by writing a temporary file in the same
+// directory and renaming it over the file. An existing file keeps its mode while a new file
+// is created with mode 0644, along with its directory. With backup, the file's previous contents are kept in filename.orig.
+// A file that already holds data is left untouched, so regenerating it doesn't invalidate build and test caches.
+// Whether the file was created, updated or left unchanged is printed to standard error
+func writeFile(filename string, data []byte, backup bool) error {
+	mode := os.FileMode(0644)
+	status := "created"
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+		status = "updated"
+
+		orig, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(orig, data) {
+			fmt.Fprintf(os.Stderr, "%s: unchanged\n", filename)
+			return nil
+		}
+
+		if backup {
+			if err := ioutil.WriteFile(filename+".orig", orig, mode); err != nil {
+				return err
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	} else if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	// the temporary file is gone once renamed so removing it only cleans up after a failure
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: %s\n", filename, status)
+	return nil
+}
+
+// postprocess returns the output of the command, the name of a program and its arguments
+// separated by spaces, run in the directory of the file with the file's source as its input
+func postprocess(command, filename string, src []byte) ([]byte, error) {
+	args, err := splitCommand(command)
+	if err != nil {
+		return nil, fmt.Errorf("-postprocess %s: %v", command, err)
+	}
+
+	if len(args) == 0 {
+		return src, nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = filepath.Dir(filename)
+	cmd.Stdin = bytes.NewReader(src)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+		return nil, fmt.Errorf("-postprocess %s: %v\n%s", command, err, msg)
+	} else if err != nil {
+		return nil, fmt.Errorf("-postprocess %s: %v", command, err)
+	}
+
+	return out, nil
+}
+
+// splitCommand splits the command into words separated by spaces. A word quoted
+// in single or double quotes, such as "// License: MIT", may contain spaces
+func splitCommand(command string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// validate type checks src, the source about to be written to the file, along with the rest of the
+// file's package and returns an error listing what doesn't compile in it. An external test is
+// type checked on its own, importing the package it tests
+func validate(c config, filename string, src []byte, others map[string][]byte) error {
+	fset := token.NewFileSet()
+	file, err := generator.ParseFile(fset, filename, src)
+	if err != nil {
+		return err
+	}
+
+	// a new file may be the first of its package, in a new directory
+	files := []*ast.File{}
+	if _, err := os.Stat(filepath.Dir(filename)); err == nil && !c.externalTest {
+		files, err = parseDir(fset, filepath.Dir(filename), c.overlay, filepath.Base(filename))
+
+		var noGo *build.NoGoError
+		if errors.As(err, &noGo) {
+			files, err = nil, nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// the files switched along with it by -replace
+	for i, other := range files {
+		path := fset.Position(other.Package).Filename
+		if otherSrc, ok := others[path]; ok {
+			if files[i], err = generator.ParseFile(fset, path, otherSrc); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := generator.Validate(fset, append(files, file), filename); err != nil {
+		return fmt.Errorf("-validate: %s would not compile, nothing was written:\n%v", sourceName(filename), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileReportsOutcome(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dir", "iface.go")
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"created", "package p\n", filename + ": created\n"},
+		{"unchanged", "package p\n", filename + ": unchanged\n"},
+		{"updated", "package p\n\ntype I interface{}\n", filename + ": updated\n"},
+	}
+
+	for _, test := range tests {
+		stderr, err := capture(t, &os.Stderr, func() error {
+			return writeFile(filename, []byte(test.data), false)
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if stderr != test.want {
+			t.Errorf("%s: printed %q, want %q", test.name, stderr, test.want)
+		}
+
+		if got := readFile(t, filename); got != test.data {
+			t.Errorf("%s: file holds %q, want %q", test.name, got, test.data)
+		}
+	}
+}